@@ -0,0 +1,28 @@
+// Package sqlquery adapts SQL statements to the placeholder syntax of the database.
+package sqlquery
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Rewrite replaces the ? placeholders in the statement with numbered ones like $1,
+// if numbered is set. Otherwise, the statement is returned unchanged.
+func Rewrite(statement string, numbered bool) string {
+	if !numbered {
+		return statement
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, r := range statement {
+		if r == '?' {
+			n += 1
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
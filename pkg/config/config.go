@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"net/url"
 	"regexp"
 	"time"
@@ -45,6 +46,8 @@ type Config struct {
 	Cors *CorsConfig
 	// NotifyCompleteUploads indicates whether sending notifications about
 	// completed uploads using the CompleteUploads channel should be enabled.
+	// The notifications are also available from the handler's event bus, which
+	// does not need this setting.
 	NotifyCompleteUploads bool
 	// NotifyTerminatedUploads indicates whether sending notifications about
 	// terminated uploads using the TerminatedUploads channel should be enabled.
@@ -55,8 +58,18 @@ type Config struct {
 	// NotifyCreatedUploads indicates whether sending notifications about
 	// the upload having been created using the CreatedUploads channel should be enabled.
 	NotifyCreatedUploads bool
+	// NotificationBufferSize is the number of notifications buffered for each of the
+	// notification channels, such as CompleteUploads. Defaults to 100.
+	NotificationBufferSize int
+	// DropOnFullNotifications discards notifications, if the buffer of a notification
+	// channel is full, so that requests are not blocked if the channel is not consumed
+	// quickly enough. The dropped notifications are counted in Metrics.EventsDropped.
+	// By default, requests wait until there is room in the buffer, so that every
+	// notification is delivered.
+	DropOnFullNotifications bool
 	// UploadProgressInterval specifies the interval at which the upload progress
-	// notifications are sent to the UploadProgress channel, if enabled.
+	// notifications are sent to the UploadProgress channel and the event bus, if
+	// anybody subscribed to them.
 	// Defaults to 1s.
 	UploadProgressInterval time.Duration
 	// Logger is the logger to use internally, mostly for printing requests.
@@ -81,6 +94,27 @@ type Config struct {
 	// If the error is non-nil, the error will be forwarded to the client. Furthermore,
 	// HTTPResponse will be ignored and the error value can contain values for the HTTP response.
 	PreFinishResponseCallback func(hook models.HookEvent) (models.HTTPResponse, error)
+	// PreDownloadPresignCallback will be invoked before a download is redirected to a
+	// presigned URL, if the property is supplied. If the callback returns false, the
+	// download is served by tusd itself instead. If the error is non-nil, it is logged and
+	// the download is also served by tusd.
+	PreDownloadPresignCallback func(hook models.HookEvent) (bool, error)
+	// PreGetCallback will be invoked before an upload is downloaded using a GET request, if the
+	// property is supplied. If the error is non-nil, the download is rejected and the error will be
+	// forwarded to the client. Otherwise, optional values from HTTPResponse will be contained in the
+	// HTTP response. This can be used to authorize downloads.
+	PreGetCallback func(hook models.HookEvent) (models.HTTPResponse, error)
+	// PreHeadCallback will be invoked before the information about an upload is returned for a
+	// HEAD request, if the property is supplied. It behaves like PreGetCallback.
+	PreHeadCallback func(hook models.HookEvent) (models.HTTPResponse, error)
+	// PrePatchCallback will be invoked before data is written to an upload in a PATCH request, if
+	// the property is supplied. It behaves like PreGetCallback and can be used to prevent uploads
+	// from being resumed, e.g. after a policy change.
+	PrePatchCallback func(hook models.HookEvent) (models.HTTPResponse, error)
+	// PreTerminateCallback will be invoked before an upload is terminated using a DELETE request,
+	// if the property is supplied. It behaves like PreGetCallback and can be used to prevent the
+	// deletion of uploads, e.g. of files under legal hold.
+	PreTerminateCallback func(hook models.HookEvent) (models.HTTPResponse, error)
 	// GracefulRequestCompletionTimeout is the timeout for operations to complete after an HTTP
 	// request has ended (successfully or by error). For example, if an HTTP request is interrupted,
 	// instead of stopping immediately, the handler and data store will be given some additional
@@ -102,6 +136,51 @@ type Config struct {
 	// Under the hood, this is passed to ResponseController.SetReadDeadline
 	// Defaults to 60s
 	NetworkTimeout time.Duration
+	// ChecksumAlgorithms contains the algorithms which are accepted in the Upload-Checksum
+	// header, if the data store supports the checksum extension. Additional algorithms can
+	// be added by providing a constructor for the corresponding hash.Hash.
+	// Defaults to models.DefaultChecksumAlgorithms.
+	ChecksumAlgorithms models.ChecksumAlgorithms
+	// UploadDigests contains the algorithms (see models.DigestAlgorithms) which are used
+	// to calculate digests of the entire content of each upload, while it is being uploaded.
+	// The final digests are included in FileInfo.Digests, in the Repr-Digest header
+	// for HEAD and GET requests and in the post-finish hook. Digests are only calculated
+	// if the data store implements the DigesterDataStore interface.
+	// Defaults to no digests.
+	UploadDigests []string
+	// UploadExpiration is the duration after which an unfinished upload expires, counted from
+	// its creation. Expired uploads are rejected and can be removed using ReapExpiredUploads.
+	// The expiration can be overwritten per bucket in BucketProfiles and per upload by the
+	// PreUploadCreateCallback. A value of 0 or less means that uploads do not expire.
+	UploadExpiration time.Duration
+	// PresignDownloads instructs the handler to answer GET requests for finished uploads
+	// with a redirect to a presigned URL, so that clients download the content directly
+	// from the storage backend instead of through tusd. This requires the upload to
+	// implement models.PresignableUpload. It can also be enabled per bucket in BucketProfiles.
+	PresignDownloads bool
+	// PresignExpiration is the duration for which presigned download and part upload
+	// URLs are valid. Defaults to 5 minutes.
+	PresignExpiration time.Duration
+	// EnableDirectUploads mounts the endpoints, which allow clients to upload the data
+	// of an upload directly to the storage backend using presigned part URLs. See
+	// UnroutedHandler.PostDirectUploadParts for details. This requires the data store
+	// to implement models.DirectUploaderDataStore.
+	EnableDirectUploads bool
+	// BucketProfiles contains settings which apply to uploads in a specific bucket, as selected
+	// by the bucket-name request header. The keys are the bucket names.
+	BucketProfiles map[string]BucketProfile
+}
+
+// BucketProfile contains settings which only apply to uploads stored in a specific bucket.
+// Zero values mean that the corresponding setting from Config is used.
+type BucketProfile struct {
+	// Endpoint is the S3 endpoint under which the bucket is available. It is used if the
+	// bucket has to be accessed outside of a request, e.g. when reaping expired uploads.
+	Endpoint string
+	// UploadExpiration overwrites Config.UploadExpiration for this bucket.
+	UploadExpiration time.Duration
+	// PresignDownloads enables Config.PresignDownloads for this bucket.
+	PresignDownloads bool
 }
 
 // CorsConfig provides a way to customize the the handling of Cross-Origin Resource Sharing (CORS).
@@ -141,9 +220,9 @@ var DefaultCorsConfig = CorsConfig{
 	AllowOrigin:      regexp.MustCompile(".*"),
 	AllowCredentials: false,
 	AllowMethods:     "POST, HEAD, PATCH, OPTIONS, GET, DELETE",
-	AllowHeaders:     "Authorization, Origin, X-Requested-With, X-Request-ID, X-HTTP-Method-Override, Content-Type, Upload-Length, Upload-Offset, Tus-Resumable, Upload-Metadata, Upload-Defer-Length, Upload-Concat, Upload-Complete, Upload-Draft-Interop-Version",
+	AllowHeaders:     "Authorization, Origin, X-Requested-With, X-Request-ID, X-HTTP-Method-Override, Content-Type, Upload-Length, Upload-Offset, Tus-Resumable, Upload-Metadata, Upload-Defer-Length, Upload-Concat, Upload-Complete, Upload-Draft-Interop-Version, Upload-Checksum, Range, If-Range, If-None-Match, If-Modified-Since",
 	MaxAge:           "86400",
-	ExposeHeaders:    "Upload-Offset, Location, Upload-Length, Tus-Version, Tus-Resumable, Tus-Max-Size, Tus-Extension, Upload-Metadata, Upload-Defer-Length, Upload-Concat, Upload-Complete, Upload-Draft-Interop-Version",
+	ExposeHeaders:    "Upload-Offset, Location, Upload-Length, Tus-Version, Tus-Resumable, Tus-Max-Size, Tus-Extension, Upload-Metadata, Upload-Defer-Length, Upload-Concat, Upload-Complete, Upload-Draft-Interop-Version, Tus-Checksum-Algorithm, Repr-Digest, Upload-Expires, ETag, Content-Range, Accept-Ranges",
 }
 
 func (config *Config) Validate() error {
@@ -181,6 +260,10 @@ func (config *Config) Validate() error {
 		config.UploadProgressInterval = 1 * time.Second
 	}
 
+	if config.NotificationBufferSize <= 0 {
+		config.NotificationBufferSize = models.DefaultEventBufferSize
+	}
+
 	if config.GracefulRequestCompletionTimeout <= 0 {
 		config.GracefulRequestCompletionTimeout = 10 * time.Second
 	}
@@ -193,6 +276,20 @@ func (config *Config) Validate() error {
 		config.NetworkTimeout = 60 * time.Second
 	}
 
+	if config.PresignExpiration <= 0 {
+		config.PresignExpiration = 5 * time.Minute
+	}
+
+	for _, algorithm := range config.UploadDigests {
+		if _, ok := models.DigestAlgorithms[algorithm]; !ok {
+			return fmt.Errorf("tusd: unsupported upload digest algorithm: %s", algorithm)
+		}
+	}
+
+	if config.ChecksumAlgorithms == nil {
+		config.ChecksumAlgorithms = models.DefaultChecksumAlgorithms
+	}
+
 	if config.Cors == nil {
 		config.Cors = &DefaultCorsConfig
 	}
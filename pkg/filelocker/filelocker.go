@@ -18,6 +18,8 @@ package filelocker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
@@ -77,6 +79,70 @@ func (locker FileLocker) NewLock(id string) (models.Lock, error) {
 	}, nil
 }
 
+// InspectLock returns information about the lock for the given upload. The holder is
+// identified by the host name of this machine and the process ID from the lock file.
+// Lock files of processes, which are not alive anymore, are not considered.
+func (locker FileLocker) InspectLock(ctx context.Context, id string) (models.LockInfo, error) {
+	path, err := filepath.Abs(filepath.Join(locker.Path, id+".lock"))
+	if err != nil {
+		return models.LockInfo{}, err
+	}
+
+	proc, err := lockfile.Lockfile(path).GetOwner()
+	if err != nil {
+		if os.IsNotExist(err) || errors.Is(err, lockfile.ErrDeadOwner) || errors.Is(err, lockfile.ErrInvalidPid) {
+			return models.LockInfo{}, models.ErrLockNotHeld
+		}
+		return models.LockInfo{}, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	info := models.LockInfo{
+		Holder: fmt.Sprintf("%s/%d", hostname, proc.Pid),
+	}
+	if stat, err := os.Stat(path); err == nil {
+		info.AcquiredAt = stat.ModTime()
+	}
+
+	return info, nil
+}
+
+// ForceUnlock requests the holder to release the lock by creating the `.stop` file
+// and waits for HolderPollInterval, so that the holder can notice it. Afterwards,
+// the lock file is removed, even if the holder has not released it.
+func (locker FileLocker) ForceUnlock(ctx context.Context, id string) error {
+	if _, err := locker.InspectLock(ctx, id); err != nil {
+		return err
+	}
+
+	requestReleaseFile := filepath.Join(locker.Path, id+".stop")
+	file, err := os.Create(requestReleaseFile)
+	if err != nil {
+		return err
+	}
+	file.Close()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(locker.HolderPollInterval):
+	}
+
+	// Remove the lock file before the .stop file. Otherwise, a new holder might see
+	// the .stop file and release its lock right away.
+	if err := os.Remove(filepath.Join(locker.Path, id+".lock")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(requestReleaseFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
 type fileUploadLock struct {
 	file lockfile.Lockfile
 
@@ -6,15 +6,28 @@
 // `[id]` files without an extension contain the raw binary data uploaded.
 // No cleanup is performed so you may want to run a cronjob to ensure your disk
 // is not filled up with old and finished uploads.
+//
+// Alternatively, the information can be stored in an extended attribute of the `[id]`
+// file (see InfoLocationMetadata) or in any other models.InfoStore, such as a database
+// (see FileStore.InfoStore). Existing uploads can be moved between these using
+// models.MigrateInfos.
+//
+// The `[id].info` files are replaced atomically by renaming a temporary file. If
+// another process or request has modified the information since it was read, the write
+// is rejected with models.ErrInfoConflict instead of overwriting these changes.
+// While the information is written, a `[id].info.lock` file exists.
 package filestore
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/susufqx/dynamic-bucket-tusd/internal/uid"
 	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
@@ -28,6 +41,13 @@ type FileStore struct {
 	// Relative or absolute path to store files in. FileStore does not check
 	// whether the path exists, use os.MkdirAll in this case on your own.
 	Path string
+	// InfoLocation controls where the information about uploads is stored in Path.
+	// Defaults to InfoLocationSidecar, which creates an `[id].info` file next to each
+	// upload.
+	InfoLocation InfoLocation
+	// InfoStore, if set, stores the information about uploads instead of Path, for
+	// example in a database. It takes precedence over InfoLocation.
+	InfoStore models.InfoStore
 }
 
 // New creates a new file based storage backend. The directory specified will
@@ -35,7 +55,7 @@ type FileStore struct {
 // whether the path exists, use os.MkdirAll to ensure.
 // In addition, a locking mechanism is provided.
 func New(path string) FileStore {
-	return FileStore{path}
+	return FileStore{Path: path}
 }
 
 // UseIn sets this store as the core data store in the passed composer and adds
@@ -45,6 +65,10 @@ func (store FileStore) UseIn(composer *models.StoreComposer) {
 	composer.UseTerminater(store)
 	composer.UseConcater(store)
 	composer.UseLengthDeferrer(store)
+	composer.UseChecksum(store)
+	composer.UseDigester(store)
+	composer.UseExpirer(store)
+	composer.UseLister(store)
 }
 
 func (store FileStore) NewUpload(ctx context.Context, info models.FileInfo) (models.Upload, error) {
@@ -71,13 +95,13 @@ func (store FileStore) NewUpload(ctx context.Context, info models.FileInfo) (mod
 	}
 
 	upload := &fileUpload{
-		info:     info,
-		infoPath: store.infoPath(info.ID),
-		binPath:  binPath,
+		info:      info,
+		binPath:   binPath,
+		infoStore: store.infoStore(),
 	}
 
 	// writeInfo creates the file by itself if necessary
-	err = upload.writeInfo()
+	err = upload.writeInfo(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -86,21 +110,13 @@ func (store FileStore) NewUpload(ctx context.Context, info models.FileInfo) (mod
 }
 
 func (store FileStore) GetUpload(ctx context.Context, id string) (models.Upload, error) {
-	info := models.FileInfo{}
-	data, err := os.ReadFile(store.infoPath(id))
+	infoStore := store.infoStore()
+	info, infoVersion, err := infoStore.GetInfo(ctx, id)
 	if err != nil {
-		if os.IsNotExist(err) {
-			// Interpret os.ErrNotExist as 404 Not Found
-			err = models.ErrNotFound
-		}
-		return nil, err
-	}
-	if err := json.Unmarshal(data, &info); err != nil {
 		return nil, err
 	}
 
 	binPath := store.binPath(id)
-	infoPath := store.infoPath(id)
 	stat, err := os.Stat(binPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -111,11 +127,13 @@ func (store FileStore) GetUpload(ctx context.Context, id string) (models.Upload,
 	}
 
 	info.Offset = stat.Size()
+	info.ModifiedAt = stat.ModTime()
 
 	return &fileUpload{
-		info:     info,
-		binPath:  binPath,
-		infoPath: infoPath,
+		info:        info,
+		binPath:     binPath,
+		infoStore:   infoStore,
+		infoVersion: infoVersion,
 	}, nil
 }
 
@@ -131,6 +149,100 @@ func (store FileStore) AsConcatableUpload(upload models.Upload) models.Concatabl
 	return upload.(*fileUpload)
 }
 
+func (store FileStore) AsRollbackableUpload(upload models.Upload) models.RollbackableUpload {
+	return upload.(*fileUpload)
+}
+
+func (store FileStore) AsDigestableUpload(upload models.Upload) models.DigestableUpload {
+	return upload.(*fileUpload)
+}
+
+// ListExpiredUploads lists the information about all uploads and returns the IDs of all
+// unfinished uploads, which expired before the given time.
+func (store FileStore) ListExpiredUploads(ctx context.Context, before time.Time) ([]string, error) {
+	infoIds, err := store.infoStore().ListInfos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	for _, id := range infoIds {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		upload, err := store.GetUpload(ctx, id)
+		if err != nil {
+			// The upload might have been removed in the meantime.
+			if errors.Is(err, models.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+
+		info, err := upload.GetInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if info.IsExpired(before) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// ListUploads lists the information about all uploads and returns the ones matching
+// the options. Since every upload is inspected, this is slow for large directories.
+func (store FileStore) ListUploads(ctx context.Context, options models.ListUploadsOptions) (models.ListUploadsResult, error) {
+	ids, err := store.infoStore().ListInfos(ctx)
+	if err != nil {
+		return models.ListUploadsResult{}, err
+	}
+	sort.Strings(ids)
+
+	now := time.Now()
+	limit := options.PageLimit()
+	result := models.ListUploadsResult{
+		Uploads: make([]models.FileInfo, 0),
+	}
+	for _, id := range ids {
+		if (options.Cursor != "" && id <= options.Cursor) || !strings.HasPrefix(id, options.Prefix) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		upload, err := store.GetUpload(ctx, id)
+		if err != nil {
+			// The upload might have been removed in the meantime.
+			if errors.Is(err, models.ErrNotFound) {
+				continue
+			}
+			return result, err
+		}
+
+		info, err := upload.GetInfo(ctx)
+		if err != nil {
+			return result, err
+		}
+
+		if !options.Matches(info, now) {
+			continue
+		}
+
+		if len(result.Uploads) == limit {
+			result.NextCursor = result.Uploads[limit-1].ID
+			break
+		}
+		result.Uploads = append(result.Uploads, info)
+	}
+
+	return result, nil
+}
+
 // binPath returns the path to the file storing the binary data.
 func (store FileStore) binPath(id string) string {
 	return filepath.Join(store.Path, id)
@@ -144,10 +256,13 @@ func (store FileStore) infoPath(id string) string {
 type fileUpload struct {
 	// info stores the current information about the upload
 	info models.FileInfo
-	// infoPath is the path to the .info file
-	infoPath string
 	// binPath is the path to the binary file (which has no extension)
 	binPath string
+	// infoStore stores the information about the upload
+	infoStore models.InfoStore
+	// infoVersion is the version of the stored information, as it was last read or
+	// written. It is empty if the information has not been written yet.
+	infoVersion string
 }
 
 func (upload *fileUpload) GetInfo(ctx context.Context) (models.FileInfo, error) {
@@ -164,6 +279,9 @@ func (upload *fileUpload) WriteChunk(ctx context.Context, offset int64, src io.R
 
 	n, err := io.Copy(file, src)
 	upload.info.Offset += n
+	if n > 0 {
+		upload.info.ModifiedAt = time.Now()
+	}
 	if err != nil {
 		file.Close()
 		return n, err
@@ -172,12 +290,40 @@ func (upload *fileUpload) WriteChunk(ctx context.Context, offset int64, src io.R
 	return n, file.Close()
 }
 
+// RollbackChunk truncates the binary file to the given offset, so that all data
+// from the last WriteChunk call is discarded.
+func (upload *fileUpload) RollbackChunk(ctx context.Context, offset int64) error {
+	if err := os.Truncate(upload.binPath, offset); err != nil {
+		return err
+	}
+
+	upload.info.Offset = offset
+	return nil
+}
+
 func (upload *fileUpload) GetReader(ctx context.Context) (io.ReadCloser, error) {
 	return os.Open(upload.binPath)
 }
 
+// GetRangeReader returns a reader for a section of the binary file, which is read
+// using ReadAt without consuming the preceding bytes.
+func (upload *fileUpload) GetRangeReader(ctx context.Context, offset int64, length int64) (io.ReadCloser, error) {
+	file, err := os.Open(upload.binPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return sectionReadCloser{io.NewSectionReader(file, offset, length), file}, nil
+}
+
+// sectionReadCloser reads from a section of a file and closes the file afterwards.
+type sectionReadCloser struct {
+	*io.SectionReader
+	io.Closer
+}
+
 func (upload *fileUpload) Terminate(ctx context.Context) error {
-	if err := os.Remove(upload.infoPath); err != nil {
+	if err := upload.infoStore.DeleteInfo(ctx, upload.info.ID); err != nil {
 		return err
 	}
 	if err := os.Remove(upload.binPath); err != nil {
@@ -219,16 +365,26 @@ func (upload *fileUpload) ConcatUploads(ctx context.Context, uploads []models.Up
 func (upload *fileUpload) DeclareLength(ctx context.Context, length int64) error {
 	upload.info.Size = length
 	upload.info.SizeIsDeferred = false
-	return upload.writeInfo()
+	return upload.writeInfo(ctx)
 }
 
-// writeInfo updates the entire information. Everything will be overwritten.
-func (upload *fileUpload) writeInfo() error {
-	data, err := json.Marshal(upload.info)
+func (upload *fileUpload) UpdateDigests(ctx context.Context, state *models.DigestState, digests map[string]string) error {
+	upload.info.DigestState = state
+	upload.info.Digests = digests
+	return upload.writeInfo(ctx)
+}
+
+// writeInfo updates the entire information. Everything will be overwritten, unless the
+// information has been modified since it was last read or written by this upload, in
+// which case models.ErrInfoConflict is returned.
+func (upload *fileUpload) writeInfo(ctx context.Context) error {
+	version, err := upload.infoStore.PutInfo(ctx, upload.info.ID, upload.info, upload.infoVersion)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(upload.infoPath, data, defaultFilePerm)
+
+	upload.infoVersion = version
+	return nil
 }
 
 func (upload *fileUpload) FinishUpload(ctx context.Context) error {
@@ -0,0 +1,276 @@
+package filestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+	"github.com/tus/lockfile"
+)
+
+// InfoLocation controls where FileStore keeps the information about uploads, unless
+// FileStore.InfoStore is set.
+type InfoLocation int
+
+const (
+	// InfoLocationSidecar stores the information as JSON in the `[id].info` file next
+	// to the upload's data.
+	InfoLocationSidecar InfoLocation = iota
+	// InfoLocationMetadata stores the information as JSON in the extended attribute
+	// user.tusd.info of the `[id]` file containing the upload's data, so no additional
+	// files are kept. This is only supported on Linux and requires a file system with
+	// support for user extended attributes.
+	InfoLocationMetadata
+)
+
+// NewInfoStore returns an InfoStore, which keeps the information about uploads in this
+// store's directory at the given location. It can be used with models.MigrateInfos to
+// move the information of existing uploads between locations or to FileStore.InfoStore.
+func (store FileStore) NewInfoStore(location InfoLocation) models.InfoStore {
+	if location == InfoLocationMetadata {
+		return metadataInfoStore{store}
+	}
+	return sidecarInfoStore{store}
+}
+
+// infoStore returns the InfoStore used for the uploads of this store.
+func (store FileStore) infoStore() models.InfoStore {
+	if store.InfoStore != nil {
+		return store.InfoStore
+	}
+	return store.NewInfoStore(store.InfoLocation)
+}
+
+// infoVersion returns the version of the stored information, which is empty if no
+// information is stored.
+func infoVersion(data []byte) string {
+	if data == nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// replaceInfo stores the data using write, if the information returned by read still
+// has the given version. read returns nil if no information is stored. The lock ensures
+// that no other writer replaces the information between the comparison and the write.
+func (store FileStore) replaceInfo(id string, version string, data []byte, read func() ([]byte, error), write func() error) (newVersion string, err error) {
+	lockPath, err := filepath.Abs(store.infoPath(id) + ".lock")
+	if err != nil {
+		return "", err
+	}
+	lock := lockfile.Lockfile(lockPath)
+	if err := lock.TryLock(); err != nil {
+		if err == lockfile.ErrBusy || err == lockfile.ErrNotExist {
+			return "", models.ErrInfoConflict
+		}
+		return "", err
+	}
+	defer func() {
+		uerr := lock.Unlock()
+		if err == nil {
+			err = uerr
+		}
+	}()
+
+	current, err := read()
+	if err != nil {
+		return "", err
+	}
+	if infoVersion(current) != version {
+		return "", models.ErrInfoConflict
+	}
+
+	if err := write(); err != nil {
+		return "", err
+	}
+
+	return infoVersion(data), nil
+}
+
+// sidecarInfoStore implements InfoLocationSidecar.
+type sidecarInfoStore struct {
+	store FileStore
+}
+
+func (infoStore sidecarInfoStore) GetInfo(ctx context.Context, id string) (info models.FileInfo, version string, err error) {
+	infoPath := infoStore.store.infoPath(id)
+	data, err := os.ReadFile(infoPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Interpret os.ErrNotExist as 404 Not Found
+			err = models.ErrNotFound
+		}
+		return info, "", err
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, "", err
+	}
+
+	stat, err := os.Stat(infoPath)
+	if err == nil {
+		info.ModifiedAt = stat.ModTime()
+	}
+
+	return info, infoVersion(data), nil
+}
+
+// PutInfo writes the information to a temporary file first, which then replaces the
+// .info file at once, so that readers never see a partially written file.
+func (infoStore sidecarInfoStore) PutInfo(ctx context.Context, id string, info models.FileInfo, version string) (string, error) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+
+	infoPath := infoStore.store.infoPath(id)
+	read := func() ([]byte, error) {
+		current, err := os.ReadFile(infoPath)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return current, err
+	}
+	write := func() (err error) {
+		file, err := os.CreateTemp(filepath.Dir(infoPath), filepath.Base(infoPath)+".*.tmp")
+		if err != nil {
+			return err
+		}
+		tmpPath := file.Name()
+		defer func() {
+			if err != nil {
+				os.Remove(tmpPath)
+			}
+		}()
+
+		if _, err := file.Write(data); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		if err := os.Chmod(tmpPath, defaultFilePerm); err != nil {
+			return err
+		}
+		return os.Rename(tmpPath, infoPath)
+	}
+
+	return infoStore.store.replaceInfo(id, version, data, read, write)
+}
+
+func (infoStore sidecarInfoStore) DeleteInfo(ctx context.Context, id string) error {
+	if err := os.Remove(infoStore.store.infoPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (infoStore sidecarInfoStore) ListInfos(ctx context.Context) ([]string, error) {
+	infoPaths, err := filepath.Glob(filepath.Join(infoStore.store.Path, "*.info"))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(infoPaths))
+	for _, infoPath := range infoPaths {
+		ids = append(ids, strings.TrimSuffix(filepath.Base(infoPath), ".info"))
+	}
+
+	return ids, nil
+}
+
+// metadataInfoStore implements InfoLocationMetadata.
+type metadataInfoStore struct {
+	store FileStore
+}
+
+func (infoStore metadataInfoStore) GetInfo(ctx context.Context, id string) (info models.FileInfo, version string, err error) {
+	binPath := infoStore.store.binPath(id)
+	data, err := getInfoAttr(binPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = models.ErrNotFound
+		}
+		return info, "", err
+	}
+	if data == nil {
+		return info, "", models.ErrNotFound
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, "", err
+	}
+
+	stat, err := os.Stat(binPath)
+	if err == nil {
+		info.ModifiedAt = stat.ModTime()
+	}
+
+	return info, infoVersion(data), nil
+}
+
+// PutInfo replaces the extended attribute, which is done atomically by the file system.
+// The file containing the upload's data must exist.
+func (infoStore metadataInfoStore) PutInfo(ctx context.Context, id string, info models.FileInfo, version string) (string, error) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+
+	binPath := infoStore.store.binPath(id)
+	read := func() ([]byte, error) {
+		current, err := getInfoAttr(binPath)
+		if errors.Is(err, os.ErrNotExist) {
+			err = models.ErrNotFound
+		}
+		return current, err
+	}
+	write := func() error {
+		return setInfoAttr(binPath, data)
+	}
+
+	return infoStore.store.replaceInfo(id, version, data, read, write)
+}
+
+func (infoStore metadataInfoStore) DeleteInfo(ctx context.Context, id string) error {
+	if err := removeInfoAttr(infoStore.store.binPath(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ListInfos inspects the extended attributes of all files in the directory, which may
+// contain the data of an upload.
+func (infoStore metadataInfoStore) ListInfos(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(infoStore.store.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || filepath.Ext(entry.Name()) != "" {
+			continue
+		}
+
+		data, err := getInfoAttr(filepath.Join(infoStore.store.Path, entry.Name()))
+		if err != nil {
+			// The upload might have been removed in the meantime.
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if data != nil {
+			ids = append(ids, entry.Name())
+		}
+	}
+
+	return ids, nil
+}
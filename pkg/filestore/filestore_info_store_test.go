@@ -0,0 +1,176 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// newMetadataTestStore creates a store using InfoLocationMetadata, or skips the test if
+// the file system does not support user extended attributes.
+func newMetadataTestStore(t *testing.T) FileStore {
+	store := New(t.TempDir())
+	store.InfoLocation = InfoLocationMetadata
+
+	probe := filepath.Join(store.Path, "probe")
+	if err := os.WriteFile(probe, nil, defaultFilePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := setInfoAttr(probe, []byte("{}")); err != nil {
+		t.Skipf("extended attributes are not supported: %s", err)
+	}
+	if err := os.Remove(probe); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+func TestInfoStores(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	for _, location := range []InfoLocation{InfoLocationSidecar, InfoLocationMetadata} {
+		store := New(t.TempDir())
+		if location == InfoLocationMetadata {
+			store = newMetadataTestStore(t)
+		}
+		infoStore := store.NewInfoStore(location)
+
+		_, _, err := infoStore.GetInfo(ctx, "a")
+		assert.ErrorIs(err, models.ErrNotFound, "location %d", location)
+
+		// The metadata is attached to the file containing the upload's data.
+		for _, id := range []string{"a", "b"} {
+			assert.NoError(os.WriteFile(store.binPath(id), nil, defaultFilePerm))
+		}
+
+		version, err := infoStore.PutInfo(ctx, "a", models.FileInfo{ID: "a", Size: 10}, "")
+		assert.NoError(err)
+		assert.NotEmpty(version)
+		_, err = infoStore.PutInfo(ctx, "a", models.FileInfo{ID: "a", Size: 20}, "")
+		assert.ErrorIs(err, models.ErrInfoConflict, "location %d", location)
+
+		info, current, err := infoStore.GetInfo(ctx, "a")
+		assert.NoError(err)
+		assert.Equal(version, current)
+		assert.EqualValues(10, info.Size)
+		assert.False(info.ModifiedAt.IsZero())
+
+		newVersion, err := infoStore.PutInfo(ctx, "a", models.FileInfo{ID: "a", Size: 20}, version)
+		assert.NoError(err)
+		assert.NotEqual(version, newVersion)
+		_, err = infoStore.PutInfo(ctx, "a", models.FileInfo{ID: "a", Size: 30}, version)
+		assert.ErrorIs(err, models.ErrInfoConflict, "location %d", location)
+
+		_, err = infoStore.PutInfo(ctx, "b", models.FileInfo{ID: "b"}, "")
+		assert.NoError(err)
+		ids, err := infoStore.ListInfos(ctx)
+		assert.NoError(err)
+		sort.Strings(ids)
+		assert.Equal([]string{"a", "b"}, ids)
+
+		assert.NoError(infoStore.DeleteInfo(ctx, "b"))
+		assert.NoError(infoStore.DeleteInfo(ctx, "b"))
+		_, _, err = infoStore.GetInfo(ctx, "b")
+		assert.ErrorIs(err, models.ErrNotFound, "location %d", location)
+		ids, err = infoStore.ListInfos(ctx)
+		assert.NoError(err)
+		assert.Equal([]string{"a"}, ids)
+	}
+}
+
+func TestMetadataInfoLocation(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store := newMetadataTestStore(t)
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: 5, MetaData: models.MetaData{"filename": "hello.txt"}})
+	assert.NoError(err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(err)
+
+	_, err = upload.WriteChunk(ctx, 0, strings.NewReader("hello"))
+	assert.NoError(err)
+
+	// No .info file is created next to the upload.
+	_, err = os.Stat(store.infoPath(info.ID))
+	assert.True(os.IsNotExist(err))
+
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	info, err = upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.EqualValues(5, info.Offset)
+	assert.Equal("hello.txt", info.MetaData["filename"])
+
+	reader, err := upload.GetReader(ctx)
+	assert.NoError(err)
+	content, err := io.ReadAll(reader)
+	assert.NoError(err)
+	reader.Close()
+	assert.Equal("hello", string(content))
+}
+
+func TestMigrateToMetadataInfoLocation(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store := newMetadataTestStore(t)
+	store.InfoLocation = InfoLocationSidecar
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: 5})
+	assert.NoError(err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(err)
+	_, err = upload.WriteChunk(ctx, 0, strings.NewReader("hel"))
+	assert.NoError(err)
+
+	report, err := models.MigrateInfos(ctx, store.NewInfoStore(InfoLocationSidecar), store.NewInfoStore(InfoLocationMetadata), models.InfoMigrationOptions{DeleteSource: true})
+	assert.NoError(err)
+	assert.Equal([]string{info.ID}, report.Migrated)
+	assert.Empty(report.Skipped)
+	_, err = os.Stat(store.infoPath(info.ID))
+	assert.True(os.IsNotExist(err))
+
+	// The upload can be resumed using the new location.
+	store.InfoLocation = InfoLocationMetadata
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	info, err = upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.EqualValues(3, info.Offset)
+	_, err = upload.WriteChunk(ctx, 3, strings.NewReader("lo"))
+	assert.NoError(err)
+	assert.NoError(upload.FinishUpload(ctx))
+
+	// Repeating the migration does not change anything.
+	report, err = models.MigrateInfos(ctx, store.NewInfoStore(InfoLocationSidecar), store.NewInfoStore(InfoLocationMetadata), models.InfoMigrationOptions{DeleteSource: true})
+	assert.NoError(err)
+	assert.Empty(report.Migrated)
+	assert.Empty(report.Skipped)
+}
+
+func ExampleFileStore_NewInfoStore() {
+	store := New("./uploads")
+
+	// Move the information of existing uploads from the .info files into extended
+	// attributes. Afterwards, the store is configured to use the new location.
+	report, err := models.MigrateInfos(context.Background(), store.NewInfoStore(InfoLocationSidecar), store.NewInfoStore(InfoLocationMetadata), models.InfoMigrationOptions{
+		DeleteSource: true,
+	})
+	if err != nil {
+		fmt.Printf("Migration failed: %s\n", err)
+		return
+	}
+	fmt.Printf("Migrated %d uploads, skipped %d\n", len(report.Migrated), len(report.Skipped))
+
+	store.InfoLocation = InfoLocationMetadata
+}
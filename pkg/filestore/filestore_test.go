@@ -0,0 +1,51 @@
+package filestore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestConcurrentInfoWrites(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store := New(t.TempDir())
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{SizeIsDeferred: true})
+	assert.NoError(err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(err)
+
+	// Both writers read the same version of the information.
+	first, err := store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	second, err := store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+
+	assert.NoError(store.AsLengthDeclarableUpload(first).DeclareLength(ctx, 10))
+	err = store.AsLengthDeclarableUpload(second).DeclareLength(ctx, 20)
+	assert.True(errors.Is(err, models.ErrInfoConflict))
+
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	info, err = upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.Equal(int64(10), info.Size)
+	assert.False(info.SizeIsDeferred)
+}
+
+func TestNewUploadWithExistingID(t *testing.T) {
+	ctx := context.Background()
+	store := New(t.TempDir())
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: 10})
+	assert.NoError(t, err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(t, err)
+
+	_, err = store.NewUpload(ctx, models.FileInfo{ID: info.ID, Size: 10})
+	assert.True(t, errors.Is(err, models.ErrInfoConflict), err)
+}
@@ -0,0 +1,60 @@
+//go:build linux
+
+package filestore
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// infoAttr is the extended attribute, in which InfoLocationMetadata stores the
+// information about an upload.
+const infoAttr = "user.tusd.info"
+
+// getInfoAttr returns the value of the extended attribute containing the information
+// about the upload, or nil if the attribute does not exist.
+func getInfoAttr(path string) ([]byte, error) {
+	for {
+		size, err := syscall.Getxattr(path, infoAttr, nil)
+		if err != nil {
+			if errors.Is(err, syscall.ENODATA) {
+				return nil, nil
+			}
+			return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+
+		data := make([]byte, size)
+		n, err := syscall.Getxattr(path, infoAttr, data)
+		if err != nil {
+			// The attribute has grown in the meantime.
+			if errors.Is(err, syscall.ERANGE) {
+				continue
+			}
+			if errors.Is(err, syscall.ENODATA) {
+				return nil, nil
+			}
+			return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+		}
+
+		return data[:n], nil
+	}
+}
+
+// setInfoAttr replaces the value of the extended attribute containing the information
+// about the upload.
+func setInfoAttr(path string, data []byte) error {
+	if err := syscall.Setxattr(path, infoAttr, data, 0); err != nil {
+		return &os.PathError{Op: "setxattr", Path: path, Err: err}
+	}
+	return nil
+}
+
+// removeInfoAttr removes the extended attribute containing the information about the
+// upload, if it exists.
+func removeInfoAttr(path string) error {
+	if err := syscall.Removexattr(path, infoAttr); err != nil && !errors.Is(err, syscall.ENODATA) {
+		return &os.PathError{Op: "removexattr", Path: path, Err: err}
+	}
+	return nil
+}
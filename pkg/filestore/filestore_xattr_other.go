@@ -0,0 +1,23 @@
+//go:build !linux
+
+package filestore
+
+import "errors"
+
+// errInfoAttrUnsupported is returned by InfoLocationMetadata on this platform.
+var errInfoAttrUnsupported = errors.New("filestore: storing upload information in extended attributes is only supported on Linux")
+
+// getInfoAttr is not supported on this platform.
+func getInfoAttr(path string) ([]byte, error) {
+	return nil, errInfoAttrUnsupported
+}
+
+// setInfoAttr is not supported on this platform.
+func setInfoAttr(path string, data []byte) error {
+	return errInfoAttrUnsupported
+}
+
+// removeInfoAttr is not supported on this platform.
+func removeInfoAttr(path string) error {
+	return errInfoAttrUnsupported
+}
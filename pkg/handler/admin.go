@@ -0,0 +1,346 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bmizerany/pat"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+	"golang.org/x/exp/slog"
+)
+
+// AdminConfig provides the configuration for NewAdminHandler.
+type AdminConfig struct {
+	// Authenticate is called for every request to the admin API and returns the
+	// name of the operator, which is recorded in the audit log. If it returns an
+	// error, the request is rejected with ErrAdminUnauthorized. It must be set.
+	Authenticate func(r *http.Request) (operator string, err error)
+	// AuditLogger receives an entry for every admin action, including rejected
+	// ones. Defaults to the handler's logger.
+	AuditLogger *slog.Logger
+}
+
+// AdminTokenAuthenticator returns a function for AdminConfig.Authenticate, which accepts
+// requests carrying one of the given tokens in the Authorization header using the Bearer
+// scheme. The map's keys are the tokens and its values the names of the operators.
+func AdminTokenAuthenticator(tokens map[string]string) func(r *http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			return "", models.ErrAdminUnauthorized
+		}
+
+		// Compare against all tokens to not leak which one matched through timing.
+		operator := ""
+		for candidate, name := range tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+				operator = name
+			}
+		}
+		if operator == "" {
+			return "", models.ErrAdminUnauthorized
+		}
+
+		return operator, nil
+	}
+}
+
+type adminHandler struct {
+	handler *UnroutedHandler
+	config  AdminConfig
+}
+
+// NewAdminHandler creates a router for operators to inspect and intervene in uploads
+// handled by the given handler. It is separate from NewHandler, so that it can be
+// mounted under a private path or on a different listener, e.g.:
+//
+//	mux.Handle("/admin/", http.StripPrefix("/admin/", adminHandler))
+//
+// The following routes are provided:
+//
+//	GET    uploads/:id       the full FileInfo, the lock holder and whether the upload is active
+//	DELETE uploads/:id       terminate the upload
+//	GET    uploads/:id/lock  the instance holding the lock
+//	DELETE uploads/:id/lock  forcefully release the lock, interrupting its holder
+//	POST   uploads/:id/stop  stop the active PATCH request via FileInfo.StopUpload
+//
+// Like for the tus endpoints, the bucket-name and endpoint headers select the bucket.
+// The locks are always taken from Config.StoreComposer. Stopping only works on the
+// instance receiving the PATCH request, which can be found using the lock's holder.
+// Every request is authenticated using AdminConfig.Authenticate and recorded in the
+// audit log.
+func NewAdminHandler(handler *UnroutedHandler, config AdminConfig) (http.Handler, error) {
+	if config.Authenticate == nil {
+		return nil, errors.New("tusd: AdminConfig.Authenticate must be set")
+	}
+	if config.AuditLogger == nil {
+		config.AuditLogger = handler.logger
+	}
+
+	admin := &adminHandler{
+		handler: handler,
+		config:  config,
+	}
+
+	mux := pat.New()
+	mux.Get("uploads/:id", admin.action("InspectUpload", admin.inspectUpload))
+	mux.Del("uploads/:id", admin.action("TerminateUpload", admin.terminateUpload))
+	mux.Get("uploads/:id/lock", admin.action("InspectLock", admin.inspectLock))
+	mux.Del("uploads/:id/lock", admin.action("ReleaseLock", admin.releaseLock))
+	mux.Post("uploads/:id/stop", admin.action("StopUpload", admin.stopUpload))
+
+	return mux, nil
+}
+
+// adminLock is the JSON representation of models.LockInfo.
+type adminLock struct {
+	Holder     string     `json:"holder"`
+	AcquiredAt *time.Time `json:"acquiredAt,omitempty"`
+}
+
+// adminUpload is the response body for inspecting an upload.
+type adminUpload struct {
+	Upload models.FileInfo `json:"upload"`
+	Lock   *adminLock      `json:"lock"`
+	Active bool            `json:"active"`
+}
+
+type adminActionFunc func(c *models.HttpContext, composer *models.StoreComposer, id string) (models.HTTPResponse, error)
+
+// action authenticates the request, runs the action in the selected bucket and records
+// the outcome in the audit log.
+func (admin *adminHandler) action(name string, fn adminActionFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		c := admin.handler.getContext(rec, r)
+		id := r.URL.Query().Get(":id")
+		bucketName := r.Header.Get("bucket-name")
+		endpoint := r.Header.Get("endpoint")
+		c.Log = c.Log.With("id", id)
+
+		var resp models.HTTPResponse
+		operator, err := admin.config.Authenticate(r)
+		if err != nil {
+			c.Log.Warn("AdminAuthenticationFailed", "error", err.Error())
+			err = models.ErrAdminUnauthorized
+		} else {
+			composer := admin.handler.config.StoreComposer
+			if bucketName != "" {
+				composer = admin.handler.newBucketComposer(bucketName, endpoint)
+			}
+			resp, err = fn(c, composer, id)
+		}
+
+		if err != nil {
+			admin.handler.sendError(c, err)
+		} else {
+			admin.handler.sendResp(c, resp)
+		}
+
+		// The status is taken from the written response, so that the audit log records
+		// what the operator has actually received.
+		attrs := []any{
+			"action", name,
+			"operator", operator,
+			"id", id,
+			"bucket", bucketName,
+			"endpoint", endpoint,
+			"remoteAddr", r.RemoteAddr,
+			"requestId", getRequestId(r),
+			"status", rec.status,
+		}
+		if err != nil {
+			admin.config.AuditLogger.Warn("AdminAction", append(attrs, "error", err.Error())...)
+			return
+		}
+
+		admin.config.AuditLogger.Info("AdminAction", attrs...)
+	})
+}
+
+// statusRecorder remembers the status code of the response written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap allows http.ResponseController to access the underlying ResponseWriter.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (admin *adminHandler) inspectUpload(c *models.HttpContext, composer *models.StoreComposer, id string) (models.HTTPResponse, error) {
+	upload, err := composer.Core.GetUpload(c, id)
+	if err != nil {
+		return models.HTTPResponse{}, err
+	}
+	info, err := upload.GetInfo(c)
+	if err != nil {
+		return models.HTTPResponse{}, err
+	}
+
+	res := adminUpload{
+		Upload: info,
+	}
+
+	if inspector, ok := admin.lockInspector(); ok {
+		lockInfo, err := inspector.InspectLock(c, id)
+		if err != nil && !errors.Is(err, models.ErrLockNotHeld) {
+			return models.HTTPResponse{}, err
+		}
+		if err == nil {
+			res.Lock = &adminLock{
+				Holder:     lockInfo.Holder,
+				AcquiredAt: optionalTime(lockInfo.AcquiredAt),
+			}
+		}
+	}
+
+	admin.handler.activeUploadsLock.Lock()
+	_, res.Active = admin.handler.activeUploads[id]
+	admin.handler.activeUploadsLock.Unlock()
+
+	return jsonResponse(res)
+}
+
+func (admin *adminHandler) terminateUpload(c *models.HttpContext, composer *models.StoreComposer, id string) (models.HTTPResponse, error) {
+	if !composer.UsesTerminater {
+		return models.HTTPResponse{}, models.ErrNotImplemented
+	}
+
+	// Acquiring the lock interrupts a running PATCH request for the upload.
+	if locker := admin.handler.config.StoreComposer.Locker; admin.handler.config.StoreComposer.UsesLocker {
+		lock, err := locker.NewLock(id)
+		if err != nil {
+			return models.HTTPResponse{}, err
+		}
+
+		lockCtx, cancelLock := context.WithTimeout(c, admin.handler.config.AcquireLockTimeout)
+		defer cancelLock()
+
+		if err := lock.Lock(lockCtx, func() {}); err != nil {
+			return models.HTTPResponse{}, err
+		}
+		defer lock.Unlock()
+	}
+
+	upload, err := composer.Core.GetUpload(c, id)
+	if err != nil {
+		return models.HTTPResponse{}, err
+	}
+	info, err := upload.GetInfo(c)
+	if err != nil {
+		return models.HTTPResponse{}, err
+	}
+
+	if err := composer.Terminater.AsTerminatableUpload(upload).Terminate(c); err != nil {
+		return models.HTTPResponse{}, err
+	}
+
+	c.Log.Info("UploadTerminated")
+	admin.handler.Metrics.IncUploadsTerminated()
+
+	if admin.handler.Events.HasSubscribers(models.EventUploadTerminated) {
+		admin.handler.Events.Publish(models.EventUploadTerminated, models.NewHookEvent(c, info))
+	}
+
+	return models.HTTPResponse{
+		StatusCode: http.StatusNoContent,
+	}, nil
+}
+
+func (admin *adminHandler) inspectLock(c *models.HttpContext, composer *models.StoreComposer, id string) (models.HTTPResponse, error) {
+	inspector, ok := admin.lockInspector()
+	if !ok {
+		return models.HTTPResponse{}, models.ErrNotImplemented
+	}
+
+	lockInfo, err := inspector.InspectLock(c, id)
+	if err != nil {
+		return models.HTTPResponse{}, err
+	}
+
+	return jsonResponse(adminLock{
+		Holder:     lockInfo.Holder,
+		AcquiredAt: optionalTime(lockInfo.AcquiredAt),
+	})
+}
+
+func (admin *adminHandler) releaseLock(c *models.HttpContext, composer *models.StoreComposer, id string) (models.HTTPResponse, error) {
+	inspector, ok := admin.lockInspector()
+	if !ok {
+		return models.HTTPResponse{}, models.ErrNotImplemented
+	}
+
+	if err := inspector.ForceUnlock(c, id); err != nil {
+		return models.HTTPResponse{}, err
+	}
+
+	c.Log.Info("LockReleased")
+
+	return models.HTTPResponse{
+		StatusCode: http.StatusNoContent,
+	}, nil
+}
+
+func (admin *adminHandler) stopUpload(c *models.HttpContext, composer *models.StoreComposer, id string) (models.HTTPResponse, error) {
+	admin.handler.activeUploadsLock.Lock()
+	info, ok := admin.handler.activeUploads[id]
+	admin.handler.activeUploadsLock.Unlock()
+
+	if !ok {
+		return models.HTTPResponse{}, models.ErrUploadNotActive
+	}
+
+	info.StopUpload(models.HTTPResponse{})
+
+	return models.HTTPResponse{
+		StatusCode: http.StatusNoContent,
+	}, nil
+}
+
+// lockInspector returns the locker from Config.StoreComposer, if it can be inspected.
+func (admin *adminHandler) lockInspector() (models.LockInspector, bool) {
+	if !admin.handler.config.StoreComposer.UsesLocker {
+		return nil, false
+	}
+
+	inspector, ok := admin.handler.config.StoreComposer.Locker.(models.LockInspector)
+	return inspector, ok
+}
+
+// jsonResponse creates a response with the given value encoded as JSON.
+func jsonResponse(v any) (models.HTTPResponse, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return models.HTTPResponse{}, err
+	}
+
+	return models.HTTPResponse{
+		StatusCode: http.StatusOK,
+		Header: models.HTTPHeader{
+			"Content-Type":  "application/json",
+			"Cache-Control": "no-store",
+		},
+		Body: string(body),
+	}, nil
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/config"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/filelocker"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+	"golang.org/x/exp/slog"
+)
+
+// syncBuffer is a bytes.Buffer, which can be written to concurrently.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// newTestAdminHandler creates a handler using newTestHandler and serves its admin API
+// under /admin/. The audit log is written to the returned buffer as JSON.
+func newTestAdminHandler(t *testing.T, modify func(*config.Config)) (files string, admin string, auditLog *syncBuffer) {
+	handler, server := newTestHandler(t, modify)
+
+	auditLog = &syncBuffer{}
+	adminHandler, err := NewAdminHandler(handler.UnroutedHandler, AdminConfig{
+		Authenticate: AdminTokenAuthenticator(map[string]string{"secret": "alice"}),
+		AuditLogger:  slog.New(slog.NewJSONHandler(auditLog, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminServer := httptest.NewServer(http.StripPrefix("/admin/", adminHandler))
+	t.Cleanup(adminServer.Close)
+
+	return server.URL + "/files/", adminServer.URL + "/admin/uploads/", auditLog
+}
+
+// startSlowUpload starts a PATCH request, which writes three bytes and then waits
+// until the returned writer is closed. The request's result is sent to the channel.
+func startSlowUpload(t *testing.T, url string) (<-chan string, *io.PipeWriter) {
+	body, writer := io.Pipe()
+	done := make(chan string, 1)
+	go func() {
+		req, _ := http.NewRequest("PATCH", url, body)
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Upload-Offset", "0")
+		req.Header.Set("Content-Type", "application/offset+octet-stream")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			done <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		resBody, _ := io.ReadAll(res.Body)
+		done <- string(resBody)
+	}()
+
+	if _, err := writer.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	// Wait until the data has been written to the store.
+	time.Sleep(100 * time.Millisecond)
+
+	return done, writer
+}
+
+func TestAdminHandler(t *testing.T) {
+	lockers := map[string]func(*config.Config){
+		"memorylocker": nil,
+		"filelocker": func(cfg *config.Config) {
+			locker := filelocker.New(t.TempDir())
+			locker.HolderPollInterval = 50 * time.Millisecond
+			locker.UseIn(cfg.StoreComposer)
+		},
+	}
+
+	for name, modify := range lockers {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			files, admin, _ := newTestAdminHandler(t, modify)
+			auth := map[string]string{"Authorization": "Bearer secret"}
+			create := func() string {
+				res, _ := sendRequest(t, "POST", files, "", map[string]string{"Upload-Length": "100"})
+				location := res.Header.Get("Location")
+				return location[strings.LastIndex(location, "/")+1:]
+			}
+
+			id := create()
+			res, _ := sendRequest(t, "GET", admin+id, "", nil)
+			assert.Equal(http.StatusUnauthorized, res.StatusCode)
+			res, _ = sendRequest(t, "GET", admin+id, "", map[string]string{"Authorization": "Bearer wrong"})
+			assert.Equal(http.StatusUnauthorized, res.StatusCode)
+			res, _ = sendRequest(t, "GET", admin+id+"/lock", "", auth)
+			assert.Equal(http.StatusNotFound, res.StatusCode)
+			res, _ = sendRequest(t, "GET", admin+"unknown", "", auth)
+			assert.Equal(http.StatusNotFound, res.StatusCode)
+
+			// Inspect and stop an active upload.
+			done, writer := startSlowUpload(t, files+id)
+			res, body := sendRequest(t, "GET", admin+id, "", auth)
+			assert.Equal(http.StatusOK, res.StatusCode)
+			var inspected adminUpload
+			assert.NoError(json.Unmarshal([]byte(body), &inspected))
+			assert.Equal(id, inspected.Upload.ID)
+			assert.EqualValues(3, inspected.Upload.Offset)
+			assert.True(inspected.Active)
+			assert.NotNil(inspected.Lock)
+
+			res, body = sendRequest(t, "GET", admin+id+"/lock", "", auth)
+			assert.Equal(http.StatusOK, res.StatusCode)
+			var lock adminLock
+			assert.NoError(json.Unmarshal([]byte(body), &lock))
+			assert.Equal(inspected.Lock.Holder, lock.Holder)
+			assert.NotEmpty(lock.Holder)
+
+			res, _ = sendRequest(t, "POST", admin+id+"/stop", "", auth)
+			assert.Equal(http.StatusNoContent, res.StatusCode)
+			writer.Close()
+			assert.Contains(<-done, "ERR_UPLOAD_STOPPED")
+			res, _ = sendRequest(t, "GET", admin+id, "", auth)
+			assert.Equal(http.StatusNotFound, res.StatusCode)
+
+			// Release the lock of an active upload, which can be resumed afterwards.
+			id = create()
+			done, writer = startSlowUpload(t, files+id)
+			res, _ = sendRequest(t, "DELETE", admin+id+"/lock", "", auth)
+			assert.Equal(http.StatusNoContent, res.StatusCode)
+			writer.Close()
+			assert.Contains(<-done, "ERR_UPLOAD_INTERRUPTED")
+			res, _ = sendRequest(t, "HEAD", files+id, "", nil)
+			assert.Equal(http.StatusOK, res.StatusCode)
+			assert.Equal("3", res.Header.Get("Upload-Offset"))
+
+			res, _ = sendRequest(t, "POST", admin+id+"/stop", "", auth)
+			assert.Equal(http.StatusNotFound, res.StatusCode)
+
+			res, _ = sendRequest(t, "DELETE", admin+id, "", auth)
+			assert.Equal(http.StatusNoContent, res.StatusCode)
+			res, _ = sendRequest(t, "HEAD", files+id, "", nil)
+			assert.Equal(http.StatusNotFound, res.StatusCode)
+		})
+	}
+}
+
+func TestAdminAuditLog(t *testing.T) {
+	assert := assert.New(t)
+	files, admin, auditLog := newTestAdminHandler(t, nil)
+
+	res, _ := sendRequest(t, "POST", files, "", map[string]string{"Upload-Length": "100"})
+	location := res.Header.Get("Location")
+	id := location[strings.LastIndex(location, "/")+1:]
+
+	sendRequest(t, "GET", admin+id, "", nil)
+	sendRequest(t, "DELETE", admin+id, "", map[string]string{"Authorization": "Bearer secret"})
+	sendRequest(t, "POST", admin+id+"/stop", "", map[string]string{"Authorization": "Bearer secret"})
+
+	type entry struct {
+		Level    string
+		Msg      string
+		Action   string
+		Operator string
+		ID       string
+		Status   int
+		Error    string
+	}
+	var entries []entry
+	for _, line := range strings.Split(strings.TrimSpace(auditLog.String()), "\n") {
+		var e entry
+		assert.NoError(json.Unmarshal([]byte(line), &e))
+		entries = append(entries, e)
+	}
+
+	assert.Equal([]entry{
+		{Level: "WARN", Msg: "AdminAction", Action: "InspectUpload", ID: id, Status: http.StatusUnauthorized, Error: models.ErrAdminUnauthorized.Error()},
+		{Level: "INFO", Msg: "AdminAction", Action: "TerminateUpload", Operator: "alice", ID: id, Status: http.StatusNoContent},
+		{Level: "WARN", Msg: "AdminAction", Action: "StopUpload", Operator: "alice", ID: id, Status: http.StatusNotFound, Error: models.ErrUploadNotActive.Error()},
+	}, entries)
+}
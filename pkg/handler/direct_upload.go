@@ -0,0 +1,270 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// maxDirectUploadParts is the maximum number of parts, which can be presigned or
+// completed in a single request.
+const maxDirectUploadParts = 1000
+
+// directUploadPartsRequest is the body of requests to PostDirectUploadParts.
+type directUploadPartsRequest struct {
+	PartNumbers []int32 `json:"partNumbers"`
+}
+
+// directUploadPartsResponse is the body of responses from PostDirectUploadParts.
+type directUploadPartsResponse struct {
+	PartSize  int64              `json:"partSize"`
+	PartCount int32              `json:"partCount"`
+	ExpiresAt time.Time          `json:"expiresAt"`
+	Parts     []directUploadPart `json:"parts"`
+	Completed []int32            `json:"completed"`
+	Offset    int64              `json:"offset"`
+}
+
+type directUploadPart struct {
+	PartNumber int32  `json:"partNumber"`
+	Offset     int64  `json:"offset"`
+	Size       int64  `json:"size"`
+	URL        string `json:"url"`
+}
+
+// directUploadPartsCompleteRequest is the body of requests to PostDirectUploadPartsComplete.
+type directUploadPartsCompleteRequest struct {
+	Parts []struct {
+		PartNumber int32  `json:"partNumber"`
+		ETag       string `json:"etag"`
+	} `json:"parts"`
+}
+
+// PostDirectUploadParts hands out presigned URLs, which allow the client to upload parts
+// of an existing upload directly to the storage backend. This is not part of the tus
+// specification. The request is sent to the upload URL with the /parts suffix and contains
+// the requested part numbers as JSON, e.g. {"partNumbers":[1,2,3]}. The response contains
+// the part size, the number of parts and, for each requested part, its offset, size and URL.
+// An empty list of part numbers can be used to learn the part layout.
+//
+// The first request switches the upload to direct uploads, which is only possible while
+// its size is known and no data has been uploaded. Afterwards, PATCH requests are rejected.
+// The client uploads each part using a PUT request to its URL and reports the returned ETag
+// using PostDirectUploadPartsComplete. The URLs expire after Config.PresignExpiration.
+func (handler *UnroutedHandler) PostDirectUploadParts(w http.ResponseWriter, r *http.Request) {
+	if bucketName := r.Header.Get("bucket-name"); bucketName != "" {
+		handler.composer = handler.newBucketComposer(bucketName, r.Header.Get("endpoint"))
+	}
+
+	c := handler.getContext(w, r)
+
+	if !handler.composer.UsesDirectUploader {
+		handler.sendError(c, models.ErrNotImplemented)
+		return
+	}
+
+	var body directUploadPartsRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.PartNumbers) > maxDirectUploadParts {
+		handler.sendError(c, models.ErrInvalidRequestBody)
+		return
+	}
+
+	id, err := extractIDFromPath(strings.TrimSuffix(r.URL.Path, "/parts"))
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+	c.Log = c.Log.With("id", id)
+
+	if handler.composer.UsesLocker {
+		lock, err := handler.lockUpload(c, id)
+		if err != nil {
+			handler.sendError(c, err)
+			return
+		}
+
+		defer lock.Unlock()
+	}
+
+	upload, _, err := handler.getDirectUpload(c, id)
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	directUpload := handler.composer.DirectUploader.AsDirectUploadableUpload(upload)
+	expiresAt := time.Now().Add(handler.config.PresignExpiration)
+	presignedParts, err := directUpload.PresignUploadParts(c, body.PartNumbers, handler.config.PresignExpiration)
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	// Fetch the info again, since the upload might just have been switched to direct uploads.
+	info, err := upload.GetInfo(c)
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	state := info.DirectUpload
+	res := directUploadPartsResponse{
+		PartSize:  state.PartSize,
+		PartCount: state.NumParts(info.Size),
+		ExpiresAt: expiresAt.UTC(),
+		Parts:     make([]directUploadPart, 0, len(presignedParts)),
+		Completed: make([]int32, 0, len(state.Parts)),
+		Offset:    info.Offset,
+	}
+	for _, part := range presignedParts {
+		res.Parts = append(res.Parts, directUploadPart{
+			PartNumber: part.PartNumber,
+			Offset:     part.Offset,
+			Size:       part.Size,
+			URL:        part.URL,
+		})
+	}
+	for number := int32(1); number <= res.PartCount; number++ {
+		if _, ok := state.Parts[number]; ok {
+			res.Completed = append(res.Completed, number)
+		}
+	}
+
+	resBody, err := json.Marshal(res)
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	c.Log.Info("DirectUploadPartsPresigned", "parts", len(presignedParts))
+
+	handler.sendResp(c, models.HTTPResponse{
+		StatusCode: http.StatusOK,
+		Header: models.HTTPHeader{
+			"Content-Type":  "application/json",
+			"Cache-Control": "no-store",
+			"Upload-Offset": strconv.FormatInt(info.Offset, 10),
+		},
+		Body: string(resBody),
+	})
+}
+
+// PostDirectUploadPartsComplete records the parts, which the client has uploaded directly
+// to the storage backend. The request is sent to the upload URL with the /parts/complete
+// suffix and contains the parts' numbers and ETags as JSON, e.g.
+// {"parts":[{"partNumber":1,"etag":"\"abc\""}]}. Parts can be reported in any order and
+// across multiple requests. The upload's offset, as returned in the Upload-Offset header
+// and in HEAD responses, covers all completed parts at the beginning of the upload. Once
+// all parts are completed, the upload is finished like after a PATCH request.
+func (handler *UnroutedHandler) PostDirectUploadPartsComplete(w http.ResponseWriter, r *http.Request) {
+	if bucketName := r.Header.Get("bucket-name"); bucketName != "" {
+		handler.composer = handler.newBucketComposer(bucketName, r.Header.Get("endpoint"))
+	}
+
+	c := handler.getContext(w, r)
+
+	if !handler.composer.UsesDirectUploader {
+		handler.sendError(c, models.ErrNotImplemented)
+		return
+	}
+
+	var body directUploadPartsCompleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Parts) > maxDirectUploadParts {
+		handler.sendError(c, models.ErrInvalidRequestBody)
+		return
+	}
+
+	id, err := extractIDFromPath(strings.TrimSuffix(r.URL.Path, "/parts/complete"))
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+	c.Log = c.Log.With("id", id)
+
+	if handler.composer.UsesLocker {
+		lock, err := handler.lockUpload(c, id)
+		if err != nil {
+			handler.sendError(c, err)
+			return
+		}
+
+		defer lock.Unlock()
+	}
+
+	upload, info, err := handler.getDirectUpload(c, id)
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	resp := models.HTTPResponse{
+		StatusCode: http.StatusNoContent,
+		Header:     make(models.HTTPHeader, 1),
+	}
+
+	// Do not proxy the call to the data store if the upload is already completed
+	if !info.SizeIsDeferred && info.Offset == info.Size {
+		resp.Header["Upload-Offset"] = strconv.FormatInt(info.Offset, 10)
+		handler.sendResp(c, resp)
+		return
+	}
+
+	parts := make([]models.UploadedPart, 0, len(body.Parts))
+	for _, part := range body.Parts {
+		parts = append(parts, models.UploadedPart{
+			PartNumber: part.PartNumber,
+			ETag:       part.ETag,
+		})
+	}
+
+	directUpload := handler.composer.DirectUploader.AsDirectUploadableUpload(upload)
+	if err := directUpload.CompleteUploadParts(c, parts); err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	info, err = upload.GetInfo(c)
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	c.Log.Info("DirectUploadPartsCompleted", "parts", len(parts), "offset", info.Offset)
+	resp.Header["Upload-Offset"] = strconv.FormatInt(info.Offset, 10)
+
+	resp, err = handler.finishUploadIfComplete(c, resp, upload, info)
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	handler.sendResp(c, resp)
+}
+
+// getDirectUpload fetches an upload and ensures that it can receive data directly.
+func (handler *UnroutedHandler) getDirectUpload(c *models.HttpContext, id string) (models.Upload, models.FileInfo, error) {
+	upload, err := handler.composer.Core.GetUpload(c, id)
+	if err != nil {
+		return nil, models.FileInfo{}, err
+	}
+
+	info, err := upload.GetInfo(c)
+	if err != nil {
+		return nil, models.FileInfo{}, err
+	}
+
+	// Modifying a final upload is not allowed
+	if info.IsFinal {
+		return nil, models.FileInfo{}, models.ErrModifyFinal
+	}
+
+	if info.IsExpired(time.Now()) {
+		return nil, models.FileInfo{}, models.ErrUploadExpired
+	}
+
+	return upload, info, nil
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/config"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// directTestStore adds direct uploads to a data store. The parts are uploaded to a
+// test server and written to the underlying upload once they are completed.
+type directTestStore struct {
+	models.DataStore
+	partSize int64
+	server   *httptest.Server
+
+	mutex  sync.Mutex
+	states map[string]*models.DirectUploadState
+	// parts contains the uploaded data keyed by the part's path on the server.
+	parts map[string][]byte
+}
+
+func newDirectTestStore(t *testing.T, core models.DataStore) *directTestStore {
+	store := &directTestStore{
+		DataStore: core,
+		partSize:  10,
+		states:    make(map[string]*models.DirectUploadState),
+		parts:     make(map[string][]byte),
+	}
+	store.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		store.mutex.Lock()
+		store.parts[r.URL.Path] = data
+		store.mutex.Unlock()
+		w.Header().Set("ETag", `"`+r.URL.Path+`"`)
+	}))
+	t.Cleanup(store.server.Close)
+
+	return store
+}
+
+func (store *directTestStore) GetUpload(ctx context.Context, id string) (models.Upload, error) {
+	upload, err := store.DataStore.GetUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &directTestUpload{Upload: upload, store: store, id: id}, nil
+}
+
+func (store *directTestStore) AsDirectUploadableUpload(upload models.Upload) models.DirectUploadableUpload {
+	return upload.(*directTestUpload)
+}
+
+type directTestUpload struct {
+	models.Upload
+	store *directTestStore
+	id    string
+}
+
+func (upload *directTestUpload) GetInfo(ctx context.Context) (models.FileInfo, error) {
+	info, err := upload.Upload.GetInfo(ctx)
+	if err != nil {
+		return info, err
+	}
+
+	upload.store.mutex.Lock()
+	defer upload.store.mutex.Unlock()
+	if state, ok := upload.store.states[upload.id]; ok {
+		info.DirectUpload = state
+	}
+
+	return info, nil
+}
+
+func (upload *directTestUpload) PresignUploadParts(ctx context.Context, partNumbers []int32, expires time.Duration) ([]models.PresignedPart, error) {
+	info, err := upload.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state := info.DirectUpload
+	if state == nil {
+		if info.SizeIsDeferred || info.Offset > 0 {
+			return nil, models.ErrDirectUploadUnavailable
+		}
+		state = &models.DirectUploadState{PartSize: upload.store.partSize, Parts: make(map[int32]string)}
+		upload.store.mutex.Lock()
+		upload.store.states[upload.id] = state
+		upload.store.mutex.Unlock()
+	}
+
+	parts := make([]models.PresignedPart, 0, len(partNumbers))
+	for _, number := range partNumbers {
+		offset, size, ok := state.PartRange(number, info.Size)
+		if !ok {
+			return nil, models.ErrInvalidPartNumber
+		}
+		parts = append(parts, models.PresignedPart{
+			PartNumber: number,
+			Offset:     offset,
+			Size:       size,
+			URL:        upload.store.server.URL + "/" + upload.id + "/" + strconv.Itoa(int(number)),
+		})
+	}
+
+	return parts, nil
+}
+
+func (upload *directTestUpload) CompleteUploadParts(ctx context.Context, parts []models.UploadedPart) error {
+	info, err := upload.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	store := upload.store
+	store.mutex.Lock()
+	for _, part := range parts {
+		path := "/" + upload.id + "/" + strconv.Itoa(int(part.PartNumber))
+		if _, ok := store.parts[path]; !ok || strings.Trim(part.ETag, `"`) != path {
+			store.mutex.Unlock()
+			return models.ErrInvalidUploadedPart
+		}
+		info.DirectUpload.Parts[part.PartNumber] = part.ETag
+	}
+
+	// Write the completed parts at the beginning of the upload.
+	var data []byte
+	for number := int32(info.Offset/store.partSize) + 1; ; number++ {
+		if _, ok := info.DirectUpload.Parts[number]; !ok {
+			break
+		}
+		data = append(data, store.parts["/"+upload.id+"/"+strconv.Itoa(int(number))]...)
+	}
+	store.mutex.Unlock()
+
+	_, err = upload.Upload.WriteChunk(ctx, info.Offset, bytes.NewReader(data))
+	return err
+}
+
+func TestDirectUpload(t *testing.T) {
+	assert := assert.New(t)
+	var store *directTestStore
+	_, server := newTestHandler(t, func(cfg *config.Config) {
+		cfg.EnableDirectUploads = true
+		store = newDirectTestStore(t, cfg.StoreComposer.Core)
+		cfg.StoreComposer.UseCore(store)
+		cfg.StoreComposer.UseDirectUploader(store)
+	})
+	content := "0123456789abcdefghijABCDE"
+
+	res, _ := sendRequest(t, "POST", server.URL+"/files/", "", map[string]string{"Upload-Length": strconv.Itoa(len(content))})
+	location := res.Header.Get("Location")
+	url := server.URL + "/files/" + location[strings.LastIndex(location, "/")+1:]
+
+	res, body := sendRequest(t, "POST", url+"/parts", `{"partNumbers":[3,1,2]}`, nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	var presigned directUploadPartsResponse
+	assert.NoError(json.Unmarshal([]byte(body), &presigned))
+	assert.EqualValues(10, presigned.PartSize)
+	assert.EqualValues(3, presigned.PartCount)
+	assert.Empty(presigned.Completed)
+
+	etags := make(map[int32]string)
+	for _, part := range presigned.Parts {
+		req, _ := http.NewRequest("PUT", part.URL, strings.NewReader(content[part.Offset:part.Offset+part.Size]))
+		partRes, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		partRes.Body.Close()
+		etags[part.PartNumber] = partRes.Header.Get("ETag")
+	}
+
+	// PATCH requests are rejected once the upload has been switched.
+	res, body = sendRequest(t, "PATCH", url, "x", map[string]string{
+		"Upload-Offset": "0",
+		"Content-Type":  "application/offset+octet-stream",
+	})
+	assert.Equal(http.StatusConflict, res.StatusCode)
+	assert.Contains(body, "ERR_DIRECT_UPLOAD_IN_PROGRESS")
+
+	complete := func(number int32, etag string) (*http.Response, string) {
+		parts, _ := json.Marshal(map[string]any{
+			"parts": []map[string]any{{"partNumber": number, "etag": etag}},
+		})
+		return sendRequest(t, "POST", url+"/parts/complete", string(parts), nil)
+	}
+
+	res, body = complete(1, "wrong")
+	assert.Equal(http.StatusBadRequest, res.StatusCode)
+	assert.Contains(body, "ERR_INVALID_UPLOADED_PART")
+
+	// The offset only covers the completed parts at the beginning.
+	res, _ = complete(2, etags[2])
+	assert.Equal(http.StatusNoContent, res.StatusCode)
+	assert.Equal("0", res.Header.Get("Upload-Offset"))
+	res, _ = complete(1, etags[1])
+	assert.Equal(http.StatusNoContent, res.StatusCode)
+	assert.Equal("20", res.Header.Get("Upload-Offset"))
+
+	res, body = sendRequest(t, "POST", url+"/parts", `{"partNumbers":[]}`, nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.NoError(json.Unmarshal([]byte(body), &presigned))
+	assert.Equal([]int32{1, 2}, presigned.Completed)
+	assert.Empty(presigned.Parts)
+
+	res, body = sendRequest(t, "POST", url+"/parts", `{"partNumbers":[4]}`, nil)
+	assert.Equal(http.StatusBadRequest, res.StatusCode)
+	assert.Contains(body, "ERR_INVALID_PART_NUMBER")
+
+	res, _ = complete(3, etags[3])
+	assert.Equal(http.StatusNoContent, res.StatusCode)
+	assert.Equal("25", res.Header.Get("Upload-Offset"))
+
+	res, body = sendRequest(t, "GET", url, "", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal(content, body)
+}
+
+func TestDirectUploadNotImplemented(t *testing.T) {
+	assert := assert.New(t)
+	_, server := newTestHandler(t, func(cfg *config.Config) {
+		cfg.EnableDirectUploads = true
+	})
+	url := createUpload(t, server, "hello")
+
+	res, _ := sendRequest(t, "POST", url+"/parts", `{"partNumbers":[1]}`, nil)
+	assert.Equal(http.StatusNotImplemented, res.StatusCode)
+	res, _ = sendRequest(t, "POST", url+"/parts/complete", `{"parts":[]}`, nil)
+	assert.Equal(http.StatusNotImplemented, res.StatusCode)
+
+	// Without EnableDirectUploads, the endpoints are not mounted.
+	_, server = newTestHandler(t, nil)
+	url = createUpload(t, server, "hello")
+	res, _ = sendRequest(t, "POST", url+"/parts", `{"partNumbers":[1]}`, nil)
+	assert.Equal(http.StatusNotFound, res.StatusCode)
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// ReapExpiredUploads terminates all unfinished uploads whose expiration date has passed.
+// It considers the uploads in the data store from Config.StoreComposer and in all buckets
+// from Config.BucketProfiles. Data stores are only considered if they implement the
+// ExpirerDataStore and TerminaterDataStore interfaces. The removed uploads are reported
+// on the event bus and the TerminatedUploads channel, if enabled.
+func (handler *UnroutedHandler) ReapExpiredUploads(ctx context.Context) error {
+	composers := []*models.StoreComposer{handler.config.StoreComposer}
+	for bucketName, profile := range handler.config.BucketProfiles {
+		composers = append(composers, handler.newBucketComposer(bucketName, profile.Endpoint))
+	}
+
+	var errs []error
+	for _, composer := range composers {
+		if err := handler.reapExpiredUploadsIn(ctx, composer); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// StartExpirationReaper invokes ReapExpiredUploads in the given interval until the
+// context is cancelled. Errors are logged but do not stop the reaper.
+func (handler *UnroutedHandler) StartExpirationReaper(ctx context.Context, interval time.Duration) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+				if err := handler.ReapExpiredUploads(ctx); err != nil {
+					handler.logger.Error("ExpirationReaperError", "error", err.Error())
+				}
+			}
+		}
+	}()
+}
+
+func (handler *UnroutedHandler) reapExpiredUploadsIn(ctx context.Context, composer *models.StoreComposer) error {
+	if !composer.UsesExpirer || !composer.UsesTerminater {
+		return nil
+	}
+
+	now := time.Now()
+	ids, err := composer.Expirer.ListExpiredUploads(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, id := range ids {
+		if err := handler.reapExpiredUpload(ctx, composer, id, now); err != nil {
+			handler.logger.Error("UploadExpirationError", "id", id, "error", err.Error())
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (handler *UnroutedHandler) reapExpiredUpload(ctx context.Context, composer *models.StoreComposer, id string, now time.Time) error {
+	// The lock from the global composer is used since the bucket composers do not have one.
+	if locker := handler.config.StoreComposer.Locker; handler.config.StoreComposer.UsesLocker {
+		lock, err := locker.NewLock(id)
+		if err != nil {
+			return err
+		}
+
+		lockCtx, cancelLock := context.WithTimeout(ctx, handler.config.AcquireLockTimeout)
+		defer cancelLock()
+
+		// The lock is only held briefly, so we do not react to release requests from
+		// other requests. Instead, they will wait until the termination is done.
+		if err := lock.Lock(lockCtx, func() {}); err != nil {
+			return err
+		}
+		defer lock.Unlock()
+	}
+
+	upload, err := composer.Core.GetUpload(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	info, err := upload.GetInfo(ctx)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// The upload might have been finished in the meantime.
+	if !info.IsExpired(now) {
+		return nil
+	}
+
+	if err := composer.Terminater.AsTerminatableUpload(upload).Terminate(ctx); err != nil {
+		return err
+	}
+
+	handler.logger.Info("UploadExpired", "id", id, "expiresAt", info.ExpiresAt)
+	handler.Metrics.IncUploadsTerminated()
+
+	handler.Events.Publish(models.EventUploadTerminated, models.HookEvent{
+		Context: ctx,
+		Upload:  info,
+	})
+
+	return nil
+}
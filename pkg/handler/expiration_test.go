@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/config"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+	"golang.org/x/exp/slices"
+)
+
+func TestExpirationExtension(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*config.Config)
+		enabled bool
+	}{
+		{"disabled", nil, false},
+		{"global", func(c *config.Config) { c.UploadExpiration = time.Hour }, true},
+		{"bucket", func(c *config.Config) {
+			c.BucketProfiles = map[string]config.BucketProfile{"bucket": {UploadExpiration: time.Hour}}
+		}, true},
+		{"callback", func(c *config.Config) {
+			c.PreUploadCreateCallback = func(models.HookEvent) (models.HTTPResponse, models.FileInfoChanges, error) {
+				return models.HTTPResponse{}, models.FileInfoChanges{}, nil
+			}
+		}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, server := newTestHandler(t, test.modify)
+			res, _ := sendRequest(t, "OPTIONS", server.URL+"/files/", "", nil)
+			extensions := strings.Split(res.Header.Get("Tus-Extension"), ",")
+			assert.Equal(t, test.enabled, slices.Contains(extensions, "expiration"))
+		})
+	}
+}
+
+func TestUploadExpiration(t *testing.T) {
+	assert := assert.New(t)
+
+	handler, server := newTestHandler(t, func(c *config.Config) {
+		c.UploadExpiration = 100 * time.Millisecond
+	})
+
+	res, _ := sendRequest(t, "POST", server.URL+"/files/", "", map[string]string{"Upload-Length": "10"})
+	assert.Equal(http.StatusCreated, res.StatusCode)
+	assert.NotEmpty(res.Header.Get("Upload-Expires"))
+	url := server.URL + "/files/" + res.Header.Get("Location")[strings.LastIndex(res.Header.Get("Location"), "/")+1:]
+
+	// Finished uploads do not expire.
+	finished := createUpload(t, server, "hello")
+	res, _ = sendRequest(t, "HEAD", finished, "", nil)
+	assert.Empty(res.Header.Get("Upload-Expires"))
+
+	time.Sleep(200 * time.Millisecond)
+
+	res, _ = sendRequest(t, "HEAD", url, "", nil)
+	assert.Equal(http.StatusGone, res.StatusCode)
+
+	assert.NoError(handler.ReapExpiredUploads(context.Background()))
+	res, _ = sendRequest(t, "HEAD", url, "", nil)
+	assert.Equal(http.StatusNotFound, res.StatusCode)
+	res, _ = sendRequest(t, "HEAD", finished, "", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+}
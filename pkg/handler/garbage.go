@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/s3store"
+)
+
+// CollectS3Garbage removes orphaned multipart uploads and objects from the S3 store in
+// Config.StoreComposer, if it is one, and from all buckets in Config.BucketProfiles.
+// See s3store.S3Store.CollectGarbage for details. A report is returned for every
+// inspected bucket, even if the garbage collection failed for some of them.
+func (handler *UnroutedHandler) CollectS3Garbage(ctx context.Context, options s3store.GarbageCollectionOptions) ([]s3store.GarbageCollectionReport, error) {
+	var stores []s3store.S3Store
+	if store, ok := handler.config.StoreComposer.Core.(s3store.S3Store); ok {
+		stores = append(stores, store)
+	}
+	for bucketName, profile := range handler.config.BucketProfiles {
+		stores = append(stores, handler.newBucketStore(bucketName, profile.Endpoint))
+	}
+
+	reports, err := s3store.CollectGarbageInStores(ctx, stores, options)
+	for _, report := range reports {
+		handler.logger.Info("GarbageCollected", "bucket", report.Bucket, "dryRun", report.DryRun,
+			"multipartUploads", len(report.OrphanedMultipartUploads),
+			"partObjects", len(report.OrphanedPartObjects),
+			"infoObjects", len(report.StaleInfoObjects))
+	}
+
+	return reports, err
+}
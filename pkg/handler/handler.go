@@ -52,6 +52,11 @@ func NewHandler(config config.Config) (*Handler, error) {
 		mux.Get(":id", http.HandlerFunc(handler.GetFile))
 	}
 
+	if config.EnableDirectUploads {
+		mux.Post(":id/parts", http.HandlerFunc(handler.PostDirectUploadParts))
+		mux.Post(":id/parts/complete", http.HandlerFunc(handler.PostDirectUploadPartsComplete))
+	}
+
 	// Only attach the DELETE handler if the Terminate() method is provided
 	if config.StoreComposer.UsesTerminater && !config.DisableTermination {
 		mux.Del(":id", http.HandlerFunc(handler.DelFile))
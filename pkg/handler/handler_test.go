@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/config"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/filestore"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/memorylocker"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// newTestHandler creates a handler, which stores the uploads using a FileStore in a
+// temporary directory, and serves it under /files/. The configuration can be adjusted
+// using modify before the handler is created.
+func newTestHandler(t *testing.T, modify func(*config.Config)) (*Handler, *httptest.Server) {
+	composer := models.NewStoreComposer()
+	filestore.New(t.TempDir()).UseIn(composer)
+	memorylocker.New().UseIn(composer)
+
+	cfg := config.Config{
+		BasePath:      "/files/",
+		StoreComposer: composer,
+	}
+	if modify != nil {
+		modify(&cfg)
+	}
+
+	handler, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.StripPrefix("/files/", handler))
+	t.Cleanup(server.Close)
+
+	return handler, server
+}
+
+// sendRequest sends a tus request and returns the response with its body.
+func sendRequest(t *testing.T, method string, url string, body string, header map[string]string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res, string(resBody)
+}
+
+// createUpload creates an upload with the given content and returns its URL.
+func createUpload(t *testing.T, server *httptest.Server, content string) string {
+	res, _ := sendRequest(t, "POST", server.URL+"/files/", content, map[string]string{
+		"Upload-Length": strconv.Itoa(len(content)),
+		"Content-Type":  "application/offset+octet-stream",
+	})
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected status %d for creating an upload", res.StatusCode)
+	}
+
+	location := res.Header.Get("Location")
+	return server.URL + "/files/" + location[strings.LastIndex(location, "/")+1:]
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// maxListLimit is the maximum number of uploads, which can be requested at once.
+const maxListLimit = 1000
+
+// listUploadsResponse is the body of responses from ListUploads.
+type listUploadsResponse struct {
+	Uploads    []listedUpload `json:"uploads"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+type listedUpload struct {
+	ID             string             `json:"id"`
+	State          models.UploadState `json:"state"`
+	Size           int64              `json:"size"`
+	SizeIsDeferred bool               `json:"sizeIsDeferred"`
+	Offset         int64              `json:"offset"`
+	MetaData       models.MetaData    `json:"metadata"`
+	IsPartial      bool               `json:"isPartial"`
+	IsFinal        bool               `json:"isFinal"`
+	Storage        map[string]string  `json:"storage"`
+	CreatedAt      *time.Time         `json:"createdAt,omitempty"`
+	ExpiresAt      *time.Time         `json:"expiresAt,omitempty"`
+	ModifiedAt     *time.Time         `json:"modifiedAt,omitempty"`
+}
+
+// ListUploads responds with the uploads in the data store as JSON. This is not part of
+// the tus specification and is not mounted by NewHandler, since it exposes information
+// about all uploads. Instead, it can be mounted next to the tus handler, preferably
+// behind an authentication layer, e.g.:
+//
+//	mux.Handle("/uploads", authenticate(http.HandlerFunc(tusHandler.ListUploads)))
+//
+// The uploads can be filtered using the query parameters state (in-progress, finished
+// or expired), prefix, metadataKey, metadataValue and createdBefore (RFC 3339). At most
+// limit uploads are returned at once. If more uploads exist, the response contains a
+// nextCursor, which is passed in the cursor parameter to retrieve them. Like for the
+// other endpoints, the bucket-name and endpoint headers select the bucket to inspect.
+func (handler *UnroutedHandler) ListUploads(w http.ResponseWriter, r *http.Request) {
+	if bucketName := r.Header.Get("bucket-name"); bucketName != "" {
+		handler.composer = handler.newBucketComposer(bucketName, r.Header.Get("endpoint"))
+	}
+
+	c := handler.getContext(w, r)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		handler.sendResp(c, models.HTTPResponse{
+			StatusCode: http.StatusMethodNotAllowed,
+			Header: models.HTTPHeader{
+				"Allow": "GET, HEAD",
+			},
+		})
+		return
+	}
+
+	if !handler.composer.UsesLister {
+		handler.sendError(c, models.ErrNotImplemented)
+		return
+	}
+
+	options, err := parseListQuery(r)
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	result, err := handler.composer.Lister.ListUploads(c, options)
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	now := time.Now()
+	res := listUploadsResponse{
+		Uploads:    make([]listedUpload, 0, len(result.Uploads)),
+		NextCursor: result.NextCursor,
+	}
+	for _, info := range result.Uploads {
+		res.Uploads = append(res.Uploads, listedUpload{
+			ID:             info.ID,
+			State:          info.State(now),
+			Size:           info.Size,
+			SizeIsDeferred: info.SizeIsDeferred,
+			Offset:         info.Offset,
+			MetaData:       info.MetaData,
+			IsPartial:      info.IsPartial,
+			IsFinal:        info.IsFinal,
+			Storage:        info.Storage,
+			CreatedAt:      optionalTime(info.CreatedAt),
+			ExpiresAt:      optionalTime(info.ExpiresAt),
+			ModifiedAt:     optionalTime(info.ModifiedAt),
+		})
+	}
+
+	resBody, err := json.Marshal(res)
+	if err != nil {
+		handler.sendError(c, err)
+		return
+	}
+
+	c.Log.Info("UploadsListed", "uploads", len(res.Uploads))
+
+	handler.sendResp(c, models.HTTPResponse{
+		StatusCode: http.StatusOK,
+		Header: models.HTTPHeader{
+			"Content-Type":  "application/json",
+			"Cache-Control": "no-store",
+		},
+		Body: string(resBody),
+	})
+}
+
+// parseListQuery extracts the options for listing uploads from the query parameters.
+func parseListQuery(r *http.Request) (models.ListUploadsOptions, error) {
+	query := r.URL.Query()
+	options := models.ListUploadsOptions{
+		State:         models.UploadState(query.Get("state")),
+		Prefix:        query.Get("prefix"),
+		MetaDataKey:   query.Get("metadataKey"),
+		MetaDataValue: query.Get("metadataValue"),
+		Cursor:        query.Get("cursor"),
+	}
+
+	switch options.State {
+	case "", models.UploadStateInProgress, models.UploadStateFinished, models.UploadStateExpired:
+	default:
+		return options, models.ErrInvalidListQuery
+	}
+
+	if options.MetaDataValue != "" && options.MetaDataKey == "" {
+		return options, models.ErrInvalidListQuery
+	}
+
+	if value := query.Get("createdBefore"); value != "" {
+		createdBefore, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return options, models.ErrInvalidListQuery
+		}
+		options.CreatedBefore = createdBefore
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return options, models.ErrInvalidListQuery
+		}
+		options.Limit = limit
+	}
+
+	return options, nil
+}
+
+// optionalTime returns nil for the zero time, so that it is omitted in JSON.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListUploads(t *testing.T) {
+	assert := assert.New(t)
+	handler, server := newTestHandler(t, nil)
+	listServer := httptest.NewServer(http.HandlerFunc(handler.ListUploads))
+	t.Cleanup(listServer.Close)
+
+	for _, metadata := range []string{"name ZmlsZQ==,kind YQ==", "kind Yg=="} {
+		res, _ := sendRequest(t, "POST", server.URL+"/files/", "", map[string]string{
+			"Upload-Length":   "5",
+			"Upload-Metadata": metadata,
+		})
+		assert.Equal(http.StatusCreated, res.StatusCode)
+	}
+	createUpload(t, server, "hello")
+
+	list := func(query string) listUploadsResponse {
+		res, body := sendRequest(t, "GET", listServer.URL+query, "", nil)
+		assert.Equal(http.StatusOK, res.StatusCode, "query %s: %s", query, body)
+		assert.Equal("application/json", res.Header.Get("Content-Type"))
+
+		var response listUploadsResponse
+		assert.NoError(json.Unmarshal([]byte(body), &response), "query %s", query)
+		return response
+	}
+
+	response := list("")
+	assert.Len(response.Uploads, 3)
+	assert.Empty(response.NextCursor)
+	for i, upload := range response.Uploads {
+		if i > 0 {
+			assert.Less(response.Uploads[i-1].ID, upload.ID)
+		}
+		assert.NotNil(upload.CreatedAt)
+	}
+
+	response = list("?state=finished")
+	assert.Len(response.Uploads, 1)
+	assert.Equal("finished", string(response.Uploads[0].State))
+	assert.EqualValues(5, response.Uploads[0].Offset)
+
+	response = list("?state=in-progress&metadataKey=kind")
+	assert.Len(response.Uploads, 2)
+	response = list("?metadataKey=kind&metadataValue=a")
+	assert.Len(response.Uploads, 1)
+	assert.Equal("file", response.Uploads[0].MetaData["name"])
+	response = list("?metadataKey=name")
+	assert.Len(response.Uploads, 1)
+	response = list("?createdBefore=2000-01-01T00:00:00Z")
+	assert.Empty(response.Uploads)
+
+	// The uploads can be retrieved page by page.
+	var listed []string
+	cursor := ""
+	for page := 0; page < 3; page++ {
+		response = list("?limit=1&cursor=" + cursor)
+		assert.Len(response.Uploads, 1)
+		listed = append(listed, response.Uploads[0].ID)
+		cursor = response.NextCursor
+	}
+	// No cursor is returned for the last page.
+	assert.Empty(cursor)
+	assert.Len(listed, 3)
+	response = list("?limit=2")
+	assert.Len(response.Uploads, 2)
+	assert.Equal(listed[1], response.NextCursor)
+	assert.Empty(list("?cursor=" + listed[2]).Uploads)
+
+	for _, query := range []string{"?state=bogus", "?limit=0", "?limit=1001", "?limit=a", "?metadataValue=a", "?createdBefore=yesterday"} {
+		res, _ := sendRequest(t, "GET", listServer.URL+query, "", nil)
+		assert.Equal(http.StatusBadRequest, res.StatusCode, "query %s", query)
+	}
+
+	res, _ := sendRequest(t, "POST", listServer.URL, "", nil)
+	assert.Equal(http.StatusMethodNotAllowed, res.StatusCode)
+	assert.Equal("GET, HEAD", res.Header.Get("Allow"))
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/config"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// postUploads creates the given number of uploads in the background. The returned
+// channel is closed once all requests have completed.
+func postUploads(url string, count int) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < count; i++ {
+			req, _ := http.NewRequest("POST", url, nil)
+			req.Header.Set("Tus-Resumable", "1.0.0")
+			req.Header.Set("Upload-Length", "5")
+			if res, err := http.DefaultClient.Do(req); err == nil {
+				res.Body.Close()
+			}
+		}
+	}()
+	return done
+}
+
+func TestNotificationsBlockByDefault(t *testing.T) {
+	handler, server := newTestHandler(t, func(cfg *config.Config) {
+		cfg.NotifyCreatedUploads = true
+		cfg.NotificationBufferSize = 1
+	})
+
+	// One notification is buffered, another one is waiting to be sent on the
+	// channel, so the third request has to wait until the channel is consumed.
+	done := postUploads(server.URL+"/files/", 3)
+	select {
+	case <-done:
+		t.Fatal("requests have not been blocked by the full notification buffer")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	for i := 0; i < 3; i++ {
+		<-handler.CreatedUploads
+	}
+	<-done
+	assert.Empty(t, handler.Metrics.EventsDropped.Load())
+}
+
+func TestDropOnFullNotifications(t *testing.T) {
+	handler, server := newTestHandler(t, func(cfg *config.Config) {
+		cfg.NotifyCreatedUploads = true
+		cfg.NotificationBufferSize = 1
+		cfg.DropOnFullNotifications = true
+	})
+
+	select {
+	case <-postUploads(server.URL+"/files/", 5):
+	case <-time.After(5 * time.Second):
+		t.Fatal("requests have been blocked by the full notification buffer")
+	}
+
+	// Depending on whether the first notification has been taken from the buffer
+	// already, three or four notifications are dropped.
+	dropped := handler.Metrics.EventsDropped.Load()[models.EventsDroppedMapEntry{Subscriber: "CreatedUploads", Type: models.EventUploadCreated}]
+	if assert.NotNil(t, dropped) {
+		assert.GreaterOrEqual(t, *dropped, uint64(3))
+		assert.LessOrEqual(t, *dropped, uint64(4))
+	}
+}
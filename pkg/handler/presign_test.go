@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/config"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// presignTestStore adds presigned downloads to a data store. The presigned URLs point
+// to a test server, which responds with "presigned".
+type presignTestStore struct {
+	models.DataStore
+	url string
+}
+
+func (store presignTestStore) GetUpload(ctx context.Context, id string) (models.Upload, error) {
+	upload, err := store.DataStore.GetUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return presignTestUpload{Upload: upload, url: store.url + "/" + id}, nil
+}
+
+type presignTestUpload struct {
+	models.Upload
+	url string
+}
+
+func (upload presignTestUpload) PresignGetURL(ctx context.Context, options models.PresignOptions) (string, error) {
+	return upload.url, nil
+}
+
+func TestPresignDownloads(t *testing.T) {
+	assert := assert.New(t)
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("presigned"))
+	}))
+	defer storage.Close()
+
+	var mutex sync.Mutex
+	var presign bool
+	var presignErr error
+	setCallback := func(p bool, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		presign, presignErr = p, err
+	}
+
+	_, server := newTestHandler(t, func(cfg *config.Config) {
+		cfg.PresignDownloads = true
+		cfg.StoreComposer.UseCore(presignTestStore{DataStore: cfg.StoreComposer.Core, url: storage.URL})
+		cfg.PreDownloadPresignCallback = func(models.HookEvent) (bool, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return presign, presignErr
+		}
+	})
+	url := createUpload(t, server, "hello")
+
+	setCallback(true, nil)
+	res, body := sendRequest(t, "GET", url, "", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("presigned", body)
+
+	// The callback can serve the download through tusd instead.
+	setCallback(false, nil)
+	res, body = sendRequest(t, "GET", url, "", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("hello", body)
+
+	// A failing callback does not prevent the download.
+	setCallback(true, errors.New("hook failed"))
+	res, body = sendRequest(t, "GET", url, "", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("hello", body)
+}
@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// maxRanges is the maximum number of ranges which are served for a single request.
+// Requests with more ranges are answered with the entire content instead.
+const maxRanges = 100
+
+// byteRange is a range of bytes in an upload's content, as requested in the Range header.
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRangeHeader parses the Range header (RFC 9110, Section 14.2) for content of the
+// given size. If the header is malformed, it must be ignored and nil is returned. If none
+// of the ranges can be satisfied, ErrRangeNotSatisfiable is returned.
+func parseRangeHeader(header string, size int64) ([]byteRange, error) {
+	specs, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, nil
+	}
+
+	var ranges []byteRange
+	for _, spec := range strings.Split(specs, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		startStr, endStr, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, nil
+		}
+
+		var r byteRange
+		if startStr == "" {
+			// A suffix range, e.g. -500, selects the last bytes.
+			suffixLength, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || suffixLength < 0 {
+				return nil, nil
+			}
+			if suffixLength == 0 {
+				continue
+			}
+			if suffixLength > size {
+				suffixLength = size
+			}
+			r = byteRange{start: size - suffixLength, length: suffixLength}
+		} else {
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || start < 0 {
+				return nil, nil
+			}
+
+			end := size - 1
+			if endStr != "" {
+				end, err = strconv.ParseInt(endStr, 10, 64)
+				if err != nil || end < start {
+					return nil, nil
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+
+			if start >= size {
+				continue
+			}
+			r = byteRange{start: start, length: end - start + 1}
+		}
+
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) == 0 {
+		err := models.ErrRangeNotSatisfiable
+		err.HTTPResponse = err.HTTPResponse.MergeWith(models.HTTPResponse{
+			Header: models.HTTPHeader{
+				"Content-Range": fmt.Sprintf("bytes */%d", size),
+			},
+		})
+		return nil, err
+	}
+
+	if len(ranges) > maxRanges {
+		return nil, nil
+	}
+
+	return ranges, nil
+}
+
+// uploadETag derives an entity tag from the upload's ID, offset and modification time.
+// The offset alone does not identify the content, since a chunk can be rolled back, e.g.
+// if its checksum does not match, and a different chunk be written at the same offset.
+// If the data store does not provide the modification time, only ID and offset are used.
+func uploadETag(info models.FileInfo) string {
+	value := info.ID + ":" + strconv.FormatInt(info.Offset, 10)
+	if !info.ModifiedAt.IsZero() {
+		value += ":" + strconv.FormatInt(info.ModifiedAt.UnixNano(), 10)
+	}
+
+	sum := sha256.Sum256([]byte(value))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// isNotModified evaluates the If-None-Match and If-Modified-Since headers (RFC 9110,
+// Section 13.1) and returns true if a 304 Not Modified response should be sent.
+func isNotModified(r *http.Request, etag string, modifiedAt time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, candidate := range strings.Split(header, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !modifiedAt.IsZero() {
+		since, err := http.ParseTime(header)
+		if err == nil && !modifiedAt.Truncate(time.Second).After(since) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifRangeMatches evaluates the If-Range header (RFC 9110, Section 13.1.5) and returns
+// true if the Range header should be respected.
+func ifRangeMatches(r *http.Request, etag string, modifiedAt time.Time) bool {
+	header := r.Header.Get("If-Range")
+	if header == "" {
+		return true
+	}
+
+	if strings.HasPrefix(header, `"`) {
+		return header == etag
+	}
+
+	since, err := http.ParseTime(header)
+	return err == nil && !modifiedAt.IsZero() && modifiedAt.Truncate(time.Second).Equal(since)
+}
+
+// getRangeReader returns a reader for the given range of the upload's content. If the
+// upload does not implement models.RangeReader, the preceding bytes are skipped.
+func getRangeReader(c *models.HttpContext, upload models.Upload, r byteRange) (io.ReadCloser, error) {
+	if rangeReader, ok := upload.(models.RangeReader); ok {
+		return rangeReader.GetRangeReader(c, r.start, r.length)
+	}
+
+	src, err := upload.GetReader(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.CopyN(io.Discard, src, r.start); err != nil {
+		src.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(src, r.length), src}, nil
+}
+
+// sendRanges responds with the requested ranges of the upload's content. A single range
+// is sent directly, while multiple ranges are sent as multipart/byteranges.
+func (handler *UnroutedHandler) sendRanges(c *models.HttpContext, resp models.HTTPResponse, upload models.Upload, info models.FileInfo, ranges []byteRange) {
+	w := c.GetRes()
+	resp.StatusCode = http.StatusPartialContent
+
+	if len(ranges) == 1 {
+		src, err := getRangeReader(c, upload, ranges[0])
+		if err != nil {
+			handler.sendError(c, err)
+			return
+		}
+		defer src.Close()
+
+		resp.Header["Content-Range"] = ranges[0].contentRange(info.Offset)
+		resp.Header["Content-Length"] = strconv.FormatInt(ranges[0].length, 10)
+		handler.sendResp(c, resp)
+		io.Copy(w, src)
+		return
+	}
+
+	mw := multipart.NewWriter(w)
+	contentType := resp.Header["Content-Type"]
+	resp.Header["Content-Type"] = "multipart/byteranges; boundary=" + mw.Boundary()
+	delete(resp.Header, "Content-Length")
+	handler.sendResp(c, resp)
+
+	// Once the response has been started, errors can only be signalled by ending it
+	// without the closing boundary.
+	for _, r := range ranges {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {contentType},
+			"Content-Range": {r.contentRange(info.Offset)},
+		})
+		if err != nil {
+			return
+		}
+
+		src, err := getRangeReader(c, upload, r)
+		if err != nil {
+			c.Log.Error("RangeReadError", "error", err.Error())
+			return
+		}
+		_, err = io.Copy(part, src)
+		src.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	mw.Close()
+}
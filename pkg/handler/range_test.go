@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestParseRangeHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		ranges []byteRange
+	}{
+		{"bytes=0-9", []byteRange{{0, 10}}},
+		{"bytes=10-", []byteRange{{10, 90}}},
+		{"bytes=-20", []byteRange{{80, 20}}},
+		{"bytes=-200", []byteRange{{0, 100}}},
+		{"bytes=90-200", []byteRange{{90, 10}}},
+		{"bytes=0-0, 50-59", []byteRange{{0, 1}, {50, 10}}},
+		{"bytes=0-9,,200-300", []byteRange{{0, 10}}},
+		// Malformed headers are ignored.
+		{"items=0-9", nil},
+		{"bytes=9-0", nil},
+		{"bytes=a-9", nil},
+		{"bytes=-x", nil},
+		{"bytes=5", nil},
+	}
+
+	for _, test := range tests {
+		ranges, err := parseRangeHeader(test.header, 100)
+		assert.NoError(t, err, test.header)
+		assert.Equal(t, test.ranges, ranges, test.header)
+	}
+}
+
+func TestParseRangeHeaderNotSatisfiable(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, header := range []string{"bytes=100-", "bytes=200-300, 150-", "bytes=-0"} {
+		ranges, err := parseRangeHeader(header, 100)
+		assert.Nil(ranges, header)
+		if assert.IsType(models.Error{}, err, header) {
+			assert.Equal(models.ErrRangeNotSatisfiable.ErrorCode, err.(models.Error).ErrorCode)
+			assert.Equal("bytes */100", err.(models.Error).HTTPResponse.Header["Content-Range"])
+		}
+	}
+}
+
+func TestParseRangeHeaderTooManyRanges(t *testing.T) {
+	header := "bytes=0-0"
+	for i := 0; i < maxRanges; i++ {
+		header += ",0-0"
+	}
+
+	ranges, err := parseRangeHeader(header, 100)
+	assert.NoError(t, err)
+	assert.Nil(t, ranges)
+}
+
+func TestUploadETag(t *testing.T) {
+	assert := assert.New(t)
+
+	info := models.FileInfo{ID: "upload", Offset: 100}
+	etag := uploadETag(info)
+	assert.Regexp(`^"[0-9a-f]{32}"$`, etag)
+	assert.Equal(etag, uploadETag(info))
+
+	info.Offset = 50
+	assert.NotEqual(etag, uploadETag(info))
+
+	// A chunk written again at the same offset changes the modification time.
+	info.ModifiedAt = time.Unix(1000, 0)
+	modified := uploadETag(info)
+	info.ModifiedAt = time.Unix(2000, 0)
+	assert.NotEqual(modified, uploadETag(info))
+}
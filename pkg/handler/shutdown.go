@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// shutdownPollInterval is the interval in which Shutdown checks whether all requests
+// have been completed.
+const shutdownPollInterval = 50 * time.Millisecond
+
+// shutdownTimeoutMargin is added to GracefulRequestCompletionTimeout when Shutdown waits
+// for the requests. Once the graceful timeout has passed, the requests still have to
+// save their state and respond, which must happen before their locks are released.
+const shutdownTimeoutMargin = 5 * time.Second
+
+// Shutdown gracefully stops the handler. New POST and PATCH requests are rejected with
+// ErrServerShutdown right away. Running requests are interrupted with ErrServerShutdown,
+// which closes their request bodies. The data stores then have the time configured in
+// Config.GracefulRequestCompletionTimeout to save the received data, after which PATCH
+// requests respond with the new Upload-Offset, so that the clients can resume the
+// uploads later. Shutdown waits for the requests to complete, but at most for
+// GracefulRequestCompletionTimeout plus a margin of five seconds or until the context is
+// done. Finally, the locks of requests, which have not completed in time, are released.
+//
+// Shutdown does not close any listeners, so it should be combined with
+// http.Server.Shutdown, which must be called afterwards since it waits for all
+// requests to complete on its own:
+//
+//	handler.Shutdown(ctx)
+//	server.Shutdown(ctx)
+//
+// If the requests did not complete in time, context.DeadlineExceeded or the context's
+// error is returned. The handler cannot be used anymore after Shutdown has been called.
+func (handler *UnroutedHandler) Shutdown(ctx context.Context) error {
+	handler.requestsLock.Lock()
+	handler.shuttingDown = true
+	contexts := make([]*models.HttpContext, 0, len(handler.requests))
+	for c := range handler.requests {
+		contexts = append(contexts, c)
+	}
+	handler.requestsLock.Unlock()
+
+	handler.logger.Info("ShutdownStarted", "requests", len(contexts))
+
+	for _, c := range contexts {
+		c.GetCancel()(models.ErrServerShutdown)
+	}
+
+	timeout := time.NewTimer(handler.config.GracefulRequestCompletionTimeout + shutdownTimeoutMargin)
+	defer timeout.Stop()
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	var err error
+	for err == nil && handler.numRequests() > 0 {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-timeout.C:
+			err = context.DeadlineExceeded
+		case <-ticker.C:
+		}
+	}
+
+	handler.requestsLock.Lock()
+	locks := make([]*trackedLock, 0, len(handler.locks))
+	for lock := range handler.locks {
+		locks = append(locks, lock)
+	}
+	handler.requestsLock.Unlock()
+
+	errs := []error{err}
+	for _, lock := range locks {
+		if unlockErr := lock.Unlock(); unlockErr != nil {
+			errs = append(errs, unlockErr)
+		}
+	}
+
+	handler.logger.Info("ShutdownCompleted", "pendingRequests", handler.numRequests(), "releasedLocks", len(locks))
+
+	return errors.Join(errs...)
+}
+
+// isShuttingDown returns whether Shutdown has been called.
+func (handler *UnroutedHandler) isShuttingDown() bool {
+	handler.requestsLock.Lock()
+	defer handler.requestsLock.Unlock()
+
+	return handler.shuttingDown
+}
+
+func (handler *UnroutedHandler) numRequests() int {
+	handler.requestsLock.Lock()
+	defer handler.requestsLock.Unlock()
+
+	return len(handler.requests)
+}
+
+func (handler *UnroutedHandler) trackRequest(c *models.HttpContext) {
+	handler.requestsLock.Lock()
+	defer handler.requestsLock.Unlock()
+
+	handler.requests[c] = struct{}{}
+}
+
+func (handler *UnroutedHandler) untrackRequest(c *models.HttpContext) {
+	handler.requestsLock.Lock()
+	defer handler.requestsLock.Unlock()
+
+	delete(handler.requests, c)
+}
+
+// trackLock wraps an acquired lock, so that it can be released by Shutdown.
+func (handler *UnroutedHandler) trackLock(lock models.Lock) models.Lock {
+	tracked := &trackedLock{
+		lock:    lock,
+		handler: handler,
+	}
+
+	handler.requestsLock.Lock()
+	handler.locks[tracked] = struct{}{}
+	handler.requestsLock.Unlock()
+
+	return tracked
+}
+
+// trackedLock is a lock held by a request to the handler. Since it can be released by
+// both the request and Shutdown, releasing it multiple times has no effect.
+type trackedLock struct {
+	lock    models.Lock
+	handler *UnroutedHandler
+
+	unlockOnce sync.Once
+	unlockErr  error
+}
+
+func (lock *trackedLock) Lock(ctx context.Context, requestUnlock func()) error {
+	return lock.lock.Lock(ctx, requestUnlock)
+}
+
+func (lock *trackedLock) Unlock() error {
+	lock.unlockOnce.Do(func() {
+		lock.handler.requestsLock.Lock()
+		delete(lock.handler.locks, lock)
+		lock.handler.requestsLock.Unlock()
+
+		lock.unlockErr = lock.lock.Unlock()
+	})
+
+	return lock.unlockErr
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/config"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestShutdown(t *testing.T) {
+	assert := assert.New(t)
+	handler, server := newTestHandler(t, func(cfg *config.Config) {
+		cfg.GracefulRequestCompletionTimeout = time.Second
+	})
+	files := server.URL + "/files/"
+
+	res, _ := sendRequest(t, "POST", files, "", map[string]string{"Upload-Length": "100"})
+	location := res.Header.Get("Location")
+	id := location[strings.LastIndex(location, "/")+1:]
+
+	done, writer := startSlowUpload(t, files+id)
+	defer writer.Close()
+
+	start := time.Now()
+	assert.NoError(handler.Shutdown(context.Background()))
+	assert.Less(time.Since(start), time.Second)
+
+	// The running request is interrupted and the received data is kept.
+	assert.Contains(<-done, "ERR_SERVER_SHUTDOWN")
+	res, _ = sendRequest(t, "HEAD", files+id, "", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal("3", res.Header.Get("Upload-Offset"))
+
+	// New uploads are rejected.
+	res, body := sendRequest(t, "POST", files, "", map[string]string{"Upload-Length": "100"})
+	assert.Equal(http.StatusServiceUnavailable, res.StatusCode)
+	assert.Contains(body, "ERR_SERVER_SHUTDOWN")
+	res, _ = sendRequest(t, "PATCH", files+id, "abc", map[string]string{
+		"Upload-Offset": "3",
+		"Content-Type":  "application/offset+octet-stream",
+	})
+	assert.Equal(http.StatusServiceUnavailable, res.StatusCode)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	assert := assert.New(t)
+	release := make(chan struct{})
+	handler, server := newTestHandler(t, func(cfg *config.Config) {
+		// The callback ignores the cancellation, so the request does not complete.
+		cfg.PreUploadCreateCallback = func(hook models.HookEvent) (models.HTTPResponse, models.FileInfoChanges, error) {
+			<-release
+			return models.HTTPResponse{}, models.FileInfoChanges{}, nil
+		}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequest("POST", server.URL+"/files/", nil)
+		req.Header.Set("Tus-Resumable", "1.0.0")
+		req.Header.Set("Upload-Length", "100")
+		if res, err := http.DefaultClient.Do(req); err == nil {
+			res.Body.Close()
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(handler.Shutdown(ctx), context.DeadlineExceeded)
+
+	close(release)
+	<-done
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/base64"
 	"errors"
+	"fmt"
+	"hash"
 	"io"
 	"math"
 	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -32,6 +35,29 @@ type UnroutedHandler struct {
 	logger        *slog.Logger
 	extensions    string
 
+	// activeUploads contains the uploads, which are currently written to by PATCH
+	// requests to this handler, so that they can be stopped using the admin API.
+	activeUploads     map[string]models.FileInfo
+	activeUploadsLock sync.Mutex
+
+	// requests contains the contexts of the requests, which are currently handled,
+	// and locks the locks held by them, so that Shutdown can interrupt the requests
+	// and release the locks. Both are protected by requestsLock.
+	requests     map[*models.HttpContext]struct{}
+	locks        map[*trackedLock]struct{}
+	shuttingDown bool
+	requestsLock sync.Mutex
+
+	// Events delivers notifications about created, progressing, finished and terminated
+	// uploads to any number of subscribers, see models.EventBus. Events are always
+	// published to it, regardless of the Notify* settings in the Config.
+	Events *models.EventBus
+
+	// The following notification channels are kept for compatibility and are fed from
+	// Events. Every channel has its own buffer of Config.NotificationBufferSize events.
+	// If the buffer is full, the requests publishing new events wait until there is
+	// room, unless DropOnFullNotifications is set in the Config.
+
 	// CompleteUploads is used to send notifications whenever an upload is
 	// completed by a user. The HookEvent will contain information about this
 	// upload after it is completed. Sending to this channel will only
@@ -84,6 +110,12 @@ func NewUnroutedHandler(config config.Config) (*UnroutedHandler, error) {
 	if config.StoreComposer.UsesLengthDeferrer {
 		extensions += ",creation-defer-length"
 	}
+	if config.StoreComposer.UsesChecksum {
+		extensions += ",checksum"
+	}
+	if expirationEnabled(config) {
+		extensions += ",expiration"
+	}
 
 	handler := &UnroutedHandler{
 		config:            config,
@@ -97,11 +129,92 @@ func NewUnroutedHandler(config config.Config) (*UnroutedHandler, error) {
 		logger:            config.Logger,
 		extensions:        extensions,
 		Metrics:           models.NewMetrics(),
+		activeUploads:     make(map[string]models.FileInfo),
+		requests:          make(map[*models.HttpContext]struct{}),
+		locks:             make(map[*trackedLock]struct{}),
+	}
+	handler.Events = models.NewEventBus(handler.Metrics)
+
+	if config.NotifyCompleteUploads {
+		handler.forwardEvents(models.EventUploadFinished, handler.CompleteUploads, "CompleteUploads")
+	}
+	if config.NotifyTerminatedUploads {
+		handler.forwardEvents(models.EventUploadTerminated, handler.TerminatedUploads, "TerminatedUploads")
+	}
+	if config.NotifyUploadProgress {
+		handler.forwardEvents(models.EventUploadProgress, handler.UploadProgress, "UploadProgress")
+	}
+	if config.NotifyCreatedUploads {
+		handler.forwardEvents(models.EventUploadCreated, handler.CreatedUploads, "CreatedUploads")
 	}
 
 	return handler, nil
 }
 
+// forwardEvents subscribes to the events of the given type and sends them on the
+// notification channel.
+func (handler *UnroutedHandler) forwardEvents(typ models.EventType, ch chan models.HookEvent, name string) {
+	policy := models.DeliveryBlock
+	if handler.config.DropOnFullNotifications {
+		policy = models.DeliveryDrop
+	}
+
+	sub := handler.Events.Subscribe(models.SubscriptionOptions{
+		Name:       name,
+		Types:      []models.EventType{typ},
+		BufferSize: handler.config.NotificationBufferSize,
+		Policy:     policy,
+	})
+
+	go func() {
+		for event := range sub.Events() {
+			ch <- event.HookEvent
+		}
+	}()
+}
+
+// newBucketComposer creates a store composer for uploads in the given bucket.
+func (handler *UnroutedHandler) newBucketComposer(bucketName string, endpoint string) *models.StoreComposer {
+	store := handler.newBucketStore(bucketName, endpoint)
+	composer := models.NewStoreComposer()
+	store.UseIn(composer)
+	return composer
+}
+
+// newBucketStore creates a S3 store for the given bucket. If the endpoint is empty, the
+// S3 service from the configuration is used. Otherwise, a new client for the given
+// endpoint is created. If the configured core data store is a S3 store, its memory
+// arena is shared with the new store, so that the memory usage is bounded globally,
+// and its streaming, download and metadata settings are applied to the new store.
+func (handler *UnroutedHandler) newBucketStore(bucketName string, endpoint string) s3store.S3Store {
+	s3c := handler.config.Service
+	if endpoint != "" {
+		s3c = s3.New(s3.Options{
+			Region: handler.config.Region,
+			Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(
+				handler.config.S3Key,
+				handler.config.S3Secret,
+				"")),
+			BaseEndpoint: &endpoint,
+			UsePathStyle: true,
+		})
+	}
+
+	store := s3store.New(bucketName, s3c)
+	if baseStore, ok := handler.config.StoreComposer.Core.(s3store.S3Store); ok {
+		store.MemoryArena = baseStore.MemoryArena
+		store.StreamPartUploads = baseStore.StreamPartUploads
+		store.StreamingChecksumAlgorithm = baseStore.StreamingChecksumAlgorithm
+		store.DownloadConcurrency = baseStore.DownloadConcurrency
+		store.DownloadPartSize = baseStore.DownloadPartSize
+		store.MetadataEncoding = baseStore.MetadataEncoding
+		store.DisableConditionalInfoWrites = baseStore.DisableConditionalInfoWrites
+		store.InfoLocation = baseStore.InfoLocation
+		store.InfoStore = baseStore.InfoStore
+	}
+	return store
+}
+
 // SupportedExtensions returns a comma-separated list of the supported tus extensions.
 // The availability of an extension usually depends on whether the provided data store
 // implements some additional interfaces.
@@ -193,6 +306,10 @@ func (handler *UnroutedHandler) Middleware(h http.Handler) http.Handler {
 			header.Set("Tus-Version", "1.0.0")
 			header.Set("Tus-Extension", handler.extensions)
 
+			if handler.composer.UsesChecksum {
+				header.Set("Tus-Checksum-Algorithm", handler.config.ChecksumAlgorithms.Names())
+			}
+
 			// Although the 204 No Content status code is a better fit in this case,
 			// since we do not have a response body included, we cannot use it here
 			// as some browsers only accept 200 OK as successful response to a
@@ -206,6 +323,12 @@ func (handler *UnroutedHandler) Middleware(h http.Handler) http.Handler {
 			return
 		}
 
+		// New uploads and chunks are not accepted anymore while the handler is shutting down.
+		if (r.Method == "POST" || r.Method == "PATCH") && handler.isShuttingDown() {
+			handler.sendError(c, models.ErrServerShutdown)
+			return
+		}
+
 		// Test if the version sent by the client is supported
 		// GET and HEAD methods are not checked since a browser may visit this URL and does
 		// not include this header. GET requests are not part of the specification.
@@ -222,26 +345,8 @@ func (handler *UnroutedHandler) Middleware(h http.Handler) http.Handler {
 // PostFile creates a new file upload using the datastore after validating the
 // length and parsing the metadata.
 func (handler *UnroutedHandler) PostFile(w http.ResponseWriter, r *http.Request) {
-	bucketName := r.Header.Get("bucket-name")
-	endpoint := r.Header.Get("endpoint")
-	s3c := handler.config.Service
-	if endpoint != "" {
-		s3c = s3.New(s3.Options{
-			Region: handler.config.Region,
-			Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(
-				handler.config.S3Key,
-				handler.config.S3Secret,
-				"")),
-			BaseEndpoint: &endpoint,
-			UsePathStyle: true,
-		})
-	}
-
-	if bucketName != "" {
-		store := s3store.New(bucketName, s3c)
-		composer := models.NewStoreComposer()
-		store.UseIn(composer)
-		handler.composer = composer
+	if bucketName := r.Header.Get("bucket-name"); bucketName != "" {
+		handler.composer = handler.newBucketComposer(bucketName, r.Header.Get("endpoint"))
 	}
 
 	if handler.isResumableUploadDraftRequest(r) {
@@ -314,6 +419,12 @@ func (handler *UnroutedHandler) PostFile(w http.ResponseWriter, r *http.Request)
 		IsPartial:      isPartial,
 		IsFinal:        isFinal,
 		PartialUploads: partialUploadIDs,
+		CreatedAt:      time.Now(),
+	}
+
+	// Final uploads are finished immediately, so they never expire.
+	if expiration := handler.uploadExpiration(r); expiration > 0 && !isFinal {
+		info.ExpiresAt = time.Now().Add(expiration)
 	}
 
 	resp := models.HTTPResponse{
@@ -341,6 +452,10 @@ func (handler *UnroutedHandler) PostFile(w http.ResponseWriter, r *http.Request)
 		if changes.Storage != nil {
 			info.Storage = changes.Storage
 		}
+
+		if !changes.ExpiresAt.IsZero() {
+			info.ExpiresAt = changes.ExpiresAt
+		}
 	}
 
 	upload, err := handler.composer.Core.NewUpload(c, info)
@@ -361,13 +476,14 @@ func (handler *UnroutedHandler) PostFile(w http.ResponseWriter, r *http.Request)
 	// include it in cases of failure when an error is returned
 	url := handler.absFileURL(r, id)
 	resp.Header["Location"] = url
+	setExpiresHeader(resp, info)
 
 	handler.Metrics.IncUploadsCreated()
 	c.Log = c.Log.With("id", id)
 	c.Log.Info("UploadCreated", "id", id, "size", size, "url", url)
 
-	if handler.config.NotifyCreatedUploads {
-		handler.CreatedUploads <- models.NewHookEvent(c, info)
+	if handler.Events.HasSubscribers(models.EventUploadCreated) {
+		handler.Events.Publish(models.EventUploadCreated, models.NewHookEvent(c, info))
 	}
 
 	if isFinal {
@@ -378,8 +494,8 @@ func (handler *UnroutedHandler) PostFile(w http.ResponseWriter, r *http.Request)
 		}
 		info.Offset = size
 
-		if handler.config.NotifyCompleteUploads {
-			handler.CompleteUploads <- models.NewHookEvent(c, info)
+		if handler.Events.HasSubscribers(models.EventUploadFinished) {
+			handler.Events.Publish(models.EventUploadFinished, models.NewHookEvent(c, info))
 		}
 	}
 
@@ -425,7 +541,11 @@ func (handler *UnroutedHandler) PostFileV2(w http.ResponseWriter, r *http.Reques
 	isComplete := r.Header.Get("Upload-Complete") == "?1"
 
 	info := models.FileInfo{
-		MetaData: make(models.MetaData),
+		MetaData:  make(models.MetaData),
+		CreatedAt: time.Now(),
+	}
+	if expiration := handler.uploadExpiration(r); expiration > 0 {
+		info.ExpiresAt = time.Now().Add(expiration)
 	}
 	if isComplete && r.ContentLength != -1 {
 		// If the client wants to perform the upload in one request with Content-Length, we know the final upload size.
@@ -489,6 +609,10 @@ func (handler *UnroutedHandler) PostFileV2(w http.ResponseWriter, r *http.Reques
 		if changes.Storage != nil {
 			info.Storage = changes.Storage
 		}
+
+		if !changes.ExpiresAt.IsZero() {
+			info.ExpiresAt = changes.ExpiresAt
+		}
 	}
 
 	upload, err := handler.composer.Core.NewUpload(c, info)
@@ -506,6 +630,7 @@ func (handler *UnroutedHandler) PostFileV2(w http.ResponseWriter, r *http.Reques
 	id := info.ID
 	url := handler.absFileURL(r, id)
 	resp.Header["Location"] = url
+	setExpiresHeader(resp, info)
 
 	// Send 104 response
 	w.Header().Set("Location", url)
@@ -516,8 +641,8 @@ func (handler *UnroutedHandler) PostFileV2(w http.ResponseWriter, r *http.Reques
 	c.Log = c.Log.With("id", id)
 	c.Log.Info("UploadCreated", "size", info.Size, "url", url)
 
-	if handler.config.NotifyCreatedUploads {
-		handler.CreatedUploads <- models.NewHookEvent(c, info)
+	if handler.Events.HasSubscribers(models.EventUploadCreated) {
+		handler.Events.Publish(models.EventUploadCreated, models.NewHookEvent(c, info))
 	}
 
 	// 2. Lock upload
@@ -570,26 +695,8 @@ func (handler *UnroutedHandler) PostFileV2(w http.ResponseWriter, r *http.Reques
 
 // HeadFile returns the length and offset for the HEAD request
 func (handler *UnroutedHandler) HeadFile(w http.ResponseWriter, r *http.Request) {
-	bucketName := r.Header.Get("bucket-name")
-	endpoint := r.Header.Get("endpoint")
-	s3c := handler.config.Service
-	if endpoint != "" {
-		s3c = s3.New(s3.Options{
-			Region: handler.config.Region,
-			Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(
-				handler.config.S3Key,
-				handler.config.S3Secret,
-				"")),
-			BaseEndpoint: &endpoint,
-			UsePathStyle: true,
-		})
-	}
-
-	if bucketName != "" {
-		store := s3store.New(bucketName, s3c)
-		composer := models.NewStoreComposer()
-		store.UseIn(composer)
-		handler.composer = composer
+	if bucketName := r.Header.Get("bucket-name"); bucketName != "" {
+		handler.composer = handler.newBucketComposer(bucketName, r.Header.Get("endpoint"))
 	}
 
 	c := handler.getContext(w, r)
@@ -623,12 +730,31 @@ func (handler *UnroutedHandler) HeadFile(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if info.IsExpired(time.Now()) {
+		handler.sendError(c, models.ErrUploadExpired)
+		return
+	}
+
+	var hookResp models.HTTPResponse
+	if handler.config.PreHeadCallback != nil {
+		hookResp, err = handler.config.PreHeadCallback(models.NewHookEvent(c, info))
+		if err != nil {
+			handler.sendError(c, err)
+			return
+		}
+	}
+
 	resp := models.HTTPResponse{
 		Header: models.HTTPHeader{
 			"Cache-Control": "no-store",
 			"Upload-Offset": strconv.FormatInt(info.Offset, 10),
 		},
 	}
+	setExpiresHeader(resp, info)
+
+	if len(info.Digests) > 0 {
+		resp.Header["Repr-Digest"] = models.SerializeReprDigestHeader(info.Digests)
+	}
 
 	if !handler.isResumableUploadDraftRequest(r) {
 		// Add Upload-Concat header if possible
@@ -673,32 +799,14 @@ func (handler *UnroutedHandler) HeadFile(w http.ResponseWriter, r *http.Request)
 		resp.StatusCode = http.StatusNoContent
 	}
 
-	handler.sendResp(c, resp)
+	handler.sendResp(c, resp.MergeWith(hookResp))
 }
 
 // PatchFile adds a chunk to an upload. This operation is only allowed
 // if enough space in the upload is left.
 func (handler *UnroutedHandler) PatchFile(w http.ResponseWriter, r *http.Request) {
-	bucketName := r.Header.Get("bucket-name")
-	endpoint := r.Header.Get("endpoint")
-	s3c := handler.config.Service
-	if endpoint != "" {
-		s3c = s3.New(s3.Options{
-			Region: handler.config.Region,
-			Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(
-				handler.config.S3Key,
-				handler.config.S3Secret,
-				"")),
-			BaseEndpoint: &endpoint,
-			UsePathStyle: true,
-		})
-	}
-
-	if bucketName != "" {
-		store := s3store.New(bucketName, s3c)
-		composer := models.NewStoreComposer()
-		store.UseIn(composer)
-		handler.composer = composer
+	if bucketName := r.Header.Get("bucket-name"); bucketName != "" {
+		handler.composer = handler.newBucketComposer(bucketName, r.Header.Get("endpoint"))
 	}
 
 	c := handler.getContext(w, r)
@@ -753,6 +861,17 @@ func (handler *UnroutedHandler) PatchFile(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if info.IsExpired(time.Now()) {
+		handler.sendError(c, models.ErrUploadExpired)
+		return
+	}
+
+	// The data of direct uploads is only accepted by the storage backend.
+	if info.DirectUpload != nil {
+		handler.sendError(c, models.ErrDirectUploadInProgress)
+		return
+	}
+
 	if offset != info.Offset {
 		handler.sendError(c, models.ErrMismatchOffset)
 		return
@@ -767,6 +886,16 @@ func (handler *UnroutedHandler) PatchFile(w http.ResponseWriter, r *http.Request
 		Header:     make(models.HTTPHeader, 1), // Initialize map, so writeChunk can set the Upload-Offset header.
 	}
 
+	// Allow the hook callback to reject writing to the upload
+	if handler.config.PrePatchCallback != nil {
+		resp2, err := handler.config.PrePatchCallback(models.NewHookEvent(c, info))
+		if err != nil {
+			handler.sendError(c, err)
+			return
+		}
+		resp = resp.MergeWith(resp2)
+	}
+
 	// Do not proxy the call to the data store if the upload is already completed
 	if !info.SizeIsDeferred && info.Offset == info.Size {
 		resp.Header["Upload-Offset"] = strconv.FormatInt(offset, 10)
@@ -865,6 +994,31 @@ func (handler *UnroutedHandler) writeChunk(c *models.HttpContext, resp models.HT
 		maxSize = length
 	}
 
+	// Parse the Upload-Checksum header before consuming the body, so invalid headers
+	// are rejected without touching the upload. The header is only respected if the
+	// data store is able to discard a chunk again.
+	var checksum models.Checksum
+	var checksumHash hash.Hash
+	if header := r.Header.Get("Upload-Checksum"); header != "" && handler.composer.UsesChecksum {
+		var err error
+		checksum, checksumHash, err = models.ParseChecksumHeader(header, handler.config.ChecksumAlgorithms)
+		if err != nil {
+			return resp, err
+		}
+	}
+
+	// Restore the whole-file digests, so they can be continued with this chunk. If their state
+	// does not match the current offset (e.g. because a previous chunk could only be stored
+	// partially), the digests cannot be calculated anymore for this upload.
+	var digestHashes map[string]hash.Hash
+	if handler.composer.UsesDigester && len(handler.config.UploadDigests) > 0 {
+		var err error
+		digestHashes, err = models.RestoreDigestHashes(handler.config.UploadDigests, info.DigestState, offset)
+		if err != nil {
+			return resp, err
+		}
+	}
+
 	c.Log.Info("ChunkWriteStart", "maxSize", maxSize, "offset", offset)
 
 	var bytesWritten int64
@@ -890,6 +1044,13 @@ func (handler *UnroutedHandler) writeChunk(c *models.HttpContext, resp models.HT
 			}
 		})
 
+		if checksumHash != nil {
+			c.Body.AddHash(checksumHash)
+		}
+		for _, h := range digestHashes {
+			c.Body.AddHash(h)
+		}
+
 		// We use a callback to allow the hook system to cancel an upload. The callback
 		// cancels the request context causing the request body to be closed with the
 		// provided error.
@@ -899,12 +1060,20 @@ func (handler *UnroutedHandler) writeChunk(c *models.HttpContext, resp models.HT
 			c.GetCancel()(cause)
 		})
 
-		if handler.config.NotifyUploadProgress {
+		if handler.Events.HasSubscribers(models.EventUploadProgress) {
 			handler.sendProgressMessages(c, info)
 		}
 
+		handler.activeUploadsLock.Lock()
+		handler.activeUploads[info.ID] = info
+		handler.activeUploadsLock.Unlock()
+
 		bytesWritten, err = upload.WriteChunk(c, offset, c.Body)
 
+		handler.activeUploadsLock.Lock()
+		delete(handler.activeUploads, info.ID)
+		handler.activeUploadsLock.Unlock()
+
 		// If we encountered an error while reading the body from the HTTP request, log it, but only include
 		// it in the response, if the store did not also return an error.
 		bodyErr := c.Body.HasError()
@@ -924,6 +1093,42 @@ func (handler *UnroutedHandler) writeChunk(c *models.HttpContext, resp models.HT
 				c.Log.Error("UploadStopTerminateError", "error", terminateErr.Error())
 			}
 		}
+
+		// If a checksum was provided, the chunk must only be kept if it has been received entirely
+		// and its checksum matches. Otherwise, the data store is instructed to discard it again.
+		if checksumHash != nil && !terminateUpload {
+			if bodyErr == nil && !checksum.Matches(checksumHash.Sum(nil)) {
+				c.Log.Info("ChecksumMismatch", "algorithm", checksum.Algorithm)
+				bodyErr = models.ErrChecksumMismatch
+				if err == nil {
+					err = bodyErr
+				}
+			}
+
+			if bodyErr != nil {
+				rollbackableUpload := handler.composer.Checksum.AsRollbackableUpload(upload)
+				if rollbackErr := rollbackableUpload.RollbackChunk(c, offset); rollbackErr != nil {
+					c.Log.Error("ChunkRollbackError", "error", rollbackErr.Error())
+					return resp, rollbackErr
+				}
+				bytesWritten = 0
+			}
+		}
+
+		// Persist the digest state, but only if the store saved exactly the bytes which have been
+		// fed into the hashes.
+		if digestHashes != nil && bytesWritten > 0 && bytesWritten == c.Body.BytesRead() {
+			state, stateErr := models.NewDigestState(digestHashes, offset+bytesWritten)
+			if stateErr == nil {
+				digestableUpload := handler.composer.Digester.AsDigestableUpload(upload)
+				stateErr = digestableUpload.UpdateDigests(c, state, nil)
+			}
+			if stateErr != nil {
+				c.Log.Error("DigestStateError", "error", stateErr.Error())
+			} else {
+				info.DigestState = state
+			}
+		}
 	}
 
 	c.Log.Info("ChunkWriteComplete", "bytesWritten", bytesWritten)
@@ -933,11 +1138,23 @@ func (handler *UnroutedHandler) writeChunk(c *models.HttpContext, resp models.HT
 	resp.Header["Upload-Offset"] = strconv.FormatInt(newOffset, 10)
 	handler.Metrics.IncBytesReceived(uint64(bytesWritten))
 	info.Offset = newOffset
+	setExpiresHeader(resp, info)
 
 	// We try to finish the upload, even if an error occurred. If we have a previous error,
 	// we return it and its HTTP response.
 	finishResp, finishErr := handler.finishUploadIfComplete(c, resp, upload, info)
 	if err != nil {
+		// Tell the client how much data has been saved, so that it can resume the upload
+		// once the server is available again.
+		var detailedErr models.Error
+		if errors.Is(err, models.ErrServerShutdown) && errors.As(err, &detailedErr) {
+			detailedErr.HTTPResponse = detailedErr.HTTPResponse.MergeWith(models.HTTPResponse{
+				Header: models.HTTPHeader{
+					"Upload-Offset": resp.Header["Upload-Offset"],
+				},
+			})
+			err = detailedErr
+		}
 		return resp, err
 	}
 
@@ -950,6 +1167,15 @@ func (handler *UnroutedHandler) writeChunk(c *models.HttpContext, resp models.HT
 func (handler *UnroutedHandler) finishUploadIfComplete(c *models.HttpContext, resp models.HTTPResponse, upload models.Upload, info models.FileInfo) (models.HTTPResponse, error) {
 	// If the upload is completed, ...
 	if !info.SizeIsDeferred && info.Offset == info.Size {
+		// ... calculate the final digests before the data store cleans up the upload
+		if handler.composer.UsesDigester && len(handler.config.UploadDigests) > 0 && info.Digests == nil {
+			digests, err := handler.finishDigests(c, upload, info)
+			if err != nil {
+				return resp, err
+			}
+			info.Digests = digests
+		}
+
 		// ... allow the data storage to finish and cleanup the upload
 		if err := upload.FinishUpload(c); err != nil {
 			return resp, err
@@ -968,37 +1194,40 @@ func (handler *UnroutedHandler) finishUploadIfComplete(c *models.HttpContext, re
 		handler.Metrics.IncUploadsFinished()
 
 		// ... send the info out to the channel
-		if handler.config.NotifyCompleteUploads {
-			handler.CompleteUploads <- models.NewHookEvent(c, info)
+		if handler.Events.HasSubscribers(models.EventUploadFinished) {
+			handler.Events.Publish(models.EventUploadFinished, models.NewHookEvent(c, info))
 		}
 	}
 
 	return resp, nil
 }
 
+// finishDigests calculates the final digests from the persisted digest state and stores
+// them in the upload's info. If the digests could not be calculated for the entire
+// upload, nil is returned.
+func (handler *UnroutedHandler) finishDigests(c *models.HttpContext, upload models.Upload, info models.FileInfo) (map[string]string, error) {
+	hashes, err := models.RestoreDigestHashes(handler.config.UploadDigests, info.DigestState, info.Offset)
+	if err != nil || hashes == nil {
+		return nil, err
+	}
+
+	digests := models.DigestSums(hashes)
+
+	digestableUpload := handler.composer.Digester.AsDigestableUpload(upload)
+	if err := digestableUpload.UpdateDigests(c, info.DigestState, digests); err != nil {
+		return nil, err
+	}
+
+	c.Log.Info("UploadDigestsCalculated", "digests", models.SerializeReprDigestHeader(digests))
+
+	return digests, nil
+}
+
 // GetFile handles requests to download a file using a GET request. This is not
 // part of the specification.
 func (handler *UnroutedHandler) GetFile(w http.ResponseWriter, r *http.Request) {
-	bucketName := r.Header.Get("bucket-name")
-	endpoint := r.Header.Get("endpoint")
-	s3c := handler.config.Service
-	if endpoint != "" {
-		s3c = s3.New(s3.Options{
-			Region: handler.config.Region,
-			Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(
-				handler.config.S3Key,
-				handler.config.S3Secret,
-				"")),
-			BaseEndpoint: &endpoint,
-			UsePathStyle: true,
-		})
-	}
-
-	if bucketName != "" {
-		store := s3store.New(bucketName, s3c)
-		composer := models.NewStoreComposer()
-		store.UseIn(composer)
-		handler.composer = composer
+	if bucketName := r.Header.Get("bucket-name"); bucketName != "" {
+		handler.composer = handler.newBucketComposer(bucketName, r.Header.Get("endpoint"))
 	}
 
 	c := handler.getContext(w, r)
@@ -1032,7 +1261,51 @@ func (handler *UnroutedHandler) GetFile(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	var hookResp models.HTTPResponse
+	if handler.config.PreGetCallback != nil {
+		hookResp, err = handler.config.PreGetCallback(models.NewHookEvent(c, info))
+		if err != nil {
+			handler.sendError(c, err)
+			return
+		}
+	}
+
 	contentType, contentDisposition := filterContentType(info)
+
+	if presignable, ok := upload.(models.PresignableUpload); ok && handler.presignDownloads(r, info) {
+		presign := true
+		if handler.config.PreDownloadPresignCallback != nil {
+			presign, err = handler.config.PreDownloadPresignCallback(models.NewHookEvent(c, info))
+			if err != nil {
+				// The download can still be served by tusd, so a failing hook
+				// should not prevent it.
+				c.Log.Warn("PreDownloadPresignError", "error", err)
+				presign = false
+			}
+		}
+
+		if presign {
+			url, err := presignable.PresignGetURL(c, models.PresignOptions{
+				Expires:            handler.config.PresignExpiration,
+				ContentType:        contentType,
+				ContentDisposition: contentDisposition,
+			})
+			if err != nil {
+				handler.sendError(c, err)
+				return
+			}
+
+			handler.sendResp(c, hookResp.MergeWith(models.HTTPResponse{
+				StatusCode: http.StatusFound,
+				Header: models.HTTPHeader{
+					"Location":      url,
+					"Cache-Control": "no-store",
+				},
+			}))
+			return
+		}
+	}
+
 	resp := models.HTTPResponse{
 		StatusCode: http.StatusOK,
 		Header: models.HTTPHeader{
@@ -1042,6 +1315,25 @@ func (handler *UnroutedHandler) GetFile(w http.ResponseWriter, r *http.Request)
 		},
 		Body: "", // Body is intentionally left empty, and we copy it manually in later.
 	}
+	resp = resp.MergeWith(hookResp)
+
+	if len(info.Digests) > 0 {
+		resp.Header["Repr-Digest"] = models.SerializeReprDigestHeader(info.Digests)
+	}
+
+	etag := uploadETag(info)
+	resp.Header["ETag"] = etag
+	resp.Header["Accept-Ranges"] = "bytes"
+	if !info.ModifiedAt.IsZero() {
+		resp.Header["Last-Modified"] = info.ModifiedAt.UTC().Format(http.TimeFormat)
+	}
+
+	if isNotModified(r, etag, info.ModifiedAt) {
+		resp.StatusCode = http.StatusNotModified
+		delete(resp.Header, "Content-Length")
+		handler.sendResp(c, resp)
+		return
+	}
 
 	// If no data has been uploaded yet, respond with an empty "204 No Content" status.
 	if info.Offset == 0 {
@@ -1050,6 +1342,19 @@ func (handler *UnroutedHandler) GetFile(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if header := r.Header.Get("Range"); header != "" && ifRangeMatches(r, etag, info.ModifiedAt) {
+		ranges, err := parseRangeHeader(header, info.Offset)
+		if err != nil {
+			handler.sendError(c, err)
+			return
+		}
+
+		if len(ranges) > 0 {
+			handler.sendRanges(c, resp, upload, info, ranges)
+			return
+		}
+	}
+
 	src, err := upload.GetReader(c)
 	if err != nil {
 		handler.sendError(c, err)
@@ -1117,34 +1422,62 @@ func filterContentType(info models.FileInfo) (contentType string, contentDisposi
 
 	// Add a filename to Content-Disposition if one is available in the metadata
 	if filename, ok := info.MetaData["filename"]; ok {
-		contentDisposition += ";filename=" + strconv.Quote(filename)
+		contentDisposition += ";filename=" + strconv.Quote(asciiFilename(filename))
+
+		// Filenames with non-ASCII characters are additionally provided in the
+		// extended notation, which takes precedence in clients supporting it.
+		// See https://www.rfc-editor.org/rfc/rfc6266#section-4.3
+		if !isASCII(filename) {
+			contentDisposition += ";filename*=UTF-8''" + percentEncodeFilename(filename)
+		}
 	}
 
 	return contentType, contentDisposition
 }
 
-// DelFile terminates an upload permanently.
-func (handler *UnroutedHandler) DelFile(w http.ResponseWriter, r *http.Request) {
-	bucketName := r.Header.Get("bucket-name")
-	endpoint := r.Header.Get("endpoint")
-	s3c := handler.config.Service
-	if endpoint != "" {
-		s3c = s3.New(s3.Options{
-			Region: handler.config.Region,
-			Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(
-				handler.config.S3Key,
-				handler.config.S3Secret,
-				"")),
-			BaseEndpoint: &endpoint,
-			UsePathStyle: true,
-		})
+// isASCII returns whether the string only consists of printable ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7E {
+			return false
+		}
 	}
+	return true
+}
 
-	if bucketName != "" {
-		store := s3store.New(bucketName, s3c)
-		composer := models.NewStoreComposer()
-		store.UseIn(composer)
-		handler.composer = composer
+// asciiFilename replaces all characters, which are not printable ASCII, with
+// underscores, so the filename can be used in the filename parameter of the
+// Content-Disposition header.
+func asciiFilename(filename string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7E {
+			return '_'
+		}
+		return r
+	}, filename)
+}
+
+// percentEncodeFilename encodes the filename for the filename* parameter of the
+// Content-Disposition header. See https://www.rfc-editor.org/rfc/rfc8187#section-3.2
+func percentEncodeFilename(filename string) string {
+	const attrChars = "!#$&+-.^_`|~"
+
+	var b strings.Builder
+	for i := 0; i < len(filename); i++ {
+		c := filename[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// DelFile terminates an upload permanently.
+func (handler *UnroutedHandler) DelFile(w http.ResponseWriter, r *http.Request) {
+	if bucketName := r.Header.Get("bucket-name"); bucketName != "" {
+		handler.composer = handler.newBucketComposer(bucketName, r.Header.Get("endpoint"))
 	}
 
 	c := handler.getContext(w, r)
@@ -1179,7 +1512,7 @@ func (handler *UnroutedHandler) DelFile(w http.ResponseWriter, r *http.Request)
 	}
 
 	var info models.FileInfo
-	if handler.config.NotifyTerminatedUploads {
+	if handler.Events.HasSubscribers(models.EventUploadTerminated) || handler.config.PreTerminateCallback != nil {
 		info, err = upload.GetInfo(c)
 		if err != nil {
 			handler.sendError(c, err)
@@ -1187,15 +1520,27 @@ func (handler *UnroutedHandler) DelFile(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	resp := models.HTTPResponse{
+		StatusCode: http.StatusNoContent,
+	}
+
+	// Allow the hook callback to prevent the termination
+	if handler.config.PreTerminateCallback != nil {
+		resp2, err := handler.config.PreTerminateCallback(models.NewHookEvent(c, info))
+		if err != nil {
+			handler.sendError(c, err)
+			return
+		}
+		resp = resp.MergeWith(resp2)
+	}
+
 	err = handler.terminateUpload(c, upload, info)
 	if err != nil {
 		handler.sendError(c, err)
 		return
 	}
 
-	handler.sendResp(c, models.HTTPResponse{
-		StatusCode: http.StatusNoContent,
-	})
+	handler.sendResp(c, resp)
 }
 
 // terminateUpload passes a given upload to the DataStore's Terminater,
@@ -1211,8 +1556,8 @@ func (handler *UnroutedHandler) terminateUpload(c *models.HttpContext, upload mo
 		return err
 	}
 
-	if handler.config.NotifyTerminatedUploads {
-		handler.TerminatedUploads <- models.NewHookEvent(c, info)
+	if handler.Events.HasSubscribers(models.EventUploadTerminated) {
+		handler.Events.Publish(models.EventUploadTerminated, models.NewHookEvent(c, info))
 	}
 
 	c.Log.Info("UploadTerminated")
@@ -1249,6 +1594,57 @@ func (handler *UnroutedHandler) sendResp(c *models.HttpContext, resp models.HTTP
 	c.Log.Info("ResponseOutgoing", "status", resp.StatusCode, "body", resp.Body)
 }
 
+// uploadExpiration returns the duration after which new uploads from the given request
+// expire. The bucket's profile takes precedence over the global configuration.
+func (handler *UnroutedHandler) uploadExpiration(r *http.Request) time.Duration {
+	if profile, ok := handler.config.BucketProfiles[r.Header.Get("bucket-name")]; ok && profile.UploadExpiration > 0 {
+		return profile.UploadExpiration
+	}
+
+	return handler.config.UploadExpiration
+}
+
+// expirationEnabled returns whether uploads can expire, i.e. an expiration is configured
+// globally or for a bucket, or can be set by the PreUploadCreateCallback.
+func expirationEnabled(config config.Config) bool {
+	if config.UploadExpiration > 0 || config.PreUploadCreateCallback != nil {
+		return true
+	}
+
+	for _, profile := range config.BucketProfiles {
+		if profile.UploadExpiration > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// presignDownloads returns whether the download of the upload should be redirected to a
+// presigned URL. This is only done for finished uploads, if enabled globally or for the
+// request's bucket.
+func (handler *UnroutedHandler) presignDownloads(r *http.Request, info models.FileInfo) bool {
+	isFinished := !info.SizeIsDeferred && info.Offset == info.Size
+	if !isFinished {
+		return false
+	}
+
+	if profile, ok := handler.config.BucketProfiles[r.Header.Get("bucket-name")]; ok && profile.PresignDownloads {
+		return true
+	}
+
+	return handler.config.PresignDownloads
+}
+
+// setExpiresHeader adds the Upload-Expires header to the response, if the upload
+// has an expiration date and is not finished yet.
+func setExpiresHeader(resp models.HTTPResponse, info models.FileInfo) {
+	isFinished := !info.SizeIsDeferred && info.Offset == info.Size
+	if !info.ExpiresAt.IsZero() && !isFinished {
+		resp.Header["Upload-Expires"] = info.ExpiresAt.UTC().Format(http.TimeFormat)
+	}
+}
+
 // Make an absolute URLs to the given upload id. If the base path is absolute
 // it will be prepended else the host and protocol from the request is used.
 func (handler *UnroutedHandler) absFileURL(r *http.Request, id string) string {
@@ -1276,7 +1672,7 @@ func (handler *UnroutedHandler) sendProgressMessages(c *models.HttpContext, info
 	emitProgress := func() {
 		hook.Upload.Offset = originalOffset + c.Body.BytesRead()
 		if hook.Upload.Offset != previousOffset {
-			handler.UploadProgress <- hook
+			handler.Events.Publish(models.EventUploadProgress, hook)
 			previousOffset = hook.Upload.Offset
 		}
 	}
@@ -1407,18 +1803,18 @@ func (handler *UnroutedHandler) lockUpload(c *models.HttpContext, id string) (mo
 		return nil, err
 	}
 
-	return lock, nil
+	return handler.trackLock(lock), nil
 }
 
 // isResumableUploadDraftRequest returns whether a HTTP request includes a sign that it is
 // related to resumable upload draft from IETF (instead of tus v1)
-func (handler UnroutedHandler) isResumableUploadDraftRequest(r *http.Request) bool {
+func (handler *UnroutedHandler) isResumableUploadDraftRequest(r *http.Request) bool {
 	return handler.config.EnableExperimentalProtocol && r.Header.Get("Upload-Draft-Interop-Version") == models.CurrentUploadDraftInteropVersion
 }
 
 // newContext constructs a new httpContext for the given request. This should only be done once
 // per request and the context should be stored in the request, so it can be fetched with getContext.
-func (h UnroutedHandler) newContext(w http.ResponseWriter, r *http.Request) *models.HttpContext {
+func (h *UnroutedHandler) newContext(w http.ResponseWriter, r *http.Request) *models.HttpContext {
 	// requestCtx is the context from the native request instance. It gets cancelled
 	// if the connection closes, the request is cancelled (HTTP/2), ServeHTTP returns
 	// or the server's base context is cancelled.
@@ -1433,6 +1829,8 @@ func (h UnroutedHandler) newContext(w http.ResponseWriter, r *http.Request) *mod
 
 	ctx := models.NewHttpContext(delayedCtx, r, w, http.NewResponseController(w), cancelHandling, h.logger.With("method", r.Method, "path", r.URL.Path, "requestId", getRequestId(r)))
 
+	h.trackRequest(ctx)
+
 	go func() {
 		<-cancellableCtx.Done()
 
@@ -1441,13 +1839,17 @@ func (h UnroutedHandler) newContext(w http.ResponseWriter, r *http.Request) *mod
 		if (errors.Is(cause, models.ErrServerShutdown) || errors.Is(cause, models.ErrUploadInterrupted) || errors.Is(cause, models.ErrUploadStoppedByServer)) && ctx.Body != nil {
 			ctx.Body.CloseWithError(cause)
 		}
+
+		// The request context is only done once the request handler has returned.
+		<-requestCtx.Done()
+		h.untrackRequest(ctx)
 	}()
 
 	return ctx
 }
 
 // getContext tries to retrieve a httpContext from the request or constructs a new one.
-func (h UnroutedHandler) getContext(w http.ResponseWriter, r *http.Request) *models.HttpContext {
+func (h *UnroutedHandler) getContext(w http.ResponseWriter, r *http.Request) *models.HttpContext {
 	c, ok := r.Context().(*models.HttpContext)
 	if !ok {
 		c = h.newContext(w, r)
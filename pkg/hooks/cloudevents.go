@@ -0,0 +1,153 @@
+package hooks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/susufqx/dynamic-bucket-tusd/internal/uid"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// PayloadFormat selects how the HTTP and file hooks serialize the hook requests.
+type PayloadFormat string
+
+const (
+	// PayloadHookRequest sends the HookRequest as JSON. This is the default.
+	PayloadHookRequest PayloadFormat = ""
+	// PayloadCloudEventsStructured sends a CloudEvent with its attributes and data
+	// as JSON, see https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/formats/json-format.md.
+	PayloadCloudEventsStructured PayloadFormat = "cloudevents-structured"
+	// PayloadCloudEventsBinary sends the data of a CloudEvent as JSON, while its
+	// attributes are passed separately, e.g. in ce-* HTTP headers.
+	PayloadCloudEventsBinary PayloadFormat = "cloudevents-binary"
+)
+
+// CloudEventDataSchema identifies the schema of CloudEvent.Data. Its version is
+// increased whenever the data changes incompatibly.
+const CloudEventDataSchema = "urn:tusd:hook-event:v1"
+
+// cloudEventTypes are the CloudEvent types for the hook types. Hook types without
+// an entry use "io.tus.upload." followed by the hook type.
+var cloudEventTypes = map[HookType]string{
+	HookPostCreate:    "io.tus.upload.created",
+	HookPostReceive:   "io.tus.upload.progress",
+	HookPostFinish:    "io.tus.upload.finished",
+	HookPostTerminate: "io.tus.upload.terminated",
+}
+
+// CloudEvent is a hook request in the CloudEvents 1.0 format.
+type CloudEvent struct {
+	SpecVersion string `json:"specversion"`
+	// ID is derived from the hook type, upload ID and offset for the post-* hooks,
+	// so that an event delivered again, e.g. from the outbox, keeps its ID. For the
+	// other hooks, every invocation is a separate event and a new ID is generated.
+	// Retries of a request within the HTTP hook keep the ID.
+	ID string `json:"id"`
+	// Source is derived from the bucket and endpoint of the upload, e.g.
+	// https://s3.example.com/bucket, //s3.example.com/bucket if the endpoint has no
+	// scheme, or s3://bucket if no endpoint has been specified. For uploads outside
+	// of buckets, it is /tusd.
+	Source string `json:"source"`
+	// Type is stable for every hook type, e.g. io.tus.upload.finished.
+	Type string `json:"type"`
+	// Subject is the upload ID.
+	Subject         string    `json:"subject,omitempty"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	// DataSchema is CloudEventDataSchema.
+	DataSchema string           `json:"dataschema"`
+	Data       models.HookEvent `json:"data"`
+}
+
+// NewCloudEvent converts the hook request into a CloudEvent.
+func NewCloudEvent(req HookRequest) CloudEvent {
+	typ, ok := cloudEventTypes[req.Type]
+	if !ok {
+		typ = "io.tus.upload." + string(req.Type)
+	}
+
+	return CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              cloudEventID(req),
+		Source:          cloudEventSource(req.Event),
+		Type:            typ,
+		Subject:         req.Event.Upload.ID,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		DataSchema:      CloudEventDataSchema,
+		Data:            req.Event,
+	}
+}
+
+// cloudEventID returns the ID for the CloudEvent of the hook request.
+func cloudEventID(req HookRequest) string {
+	isPostHook := false
+	for _, typ := range postHookTypes {
+		if typ == req.Type {
+			isPostHook = true
+			break
+		}
+	}
+	if !isPostHook || req.Event.Upload.ID == "" {
+		return uid.Uid()
+	}
+
+	sum := sha256.Sum256([]byte(string(req.Type) + "\x00" + req.Event.Upload.ID + "\x00" + strconv.FormatInt(req.Event.Upload.Offset, 10)))
+
+	// Use the same length as the generated IDs.
+	return hex.EncodeToString(sum[:16])
+}
+
+// Attributes returns the context attributes of the event by their names, as used
+// in the binary mode. The data content type is not included, since it is
+// transmitted as the content type of the data.
+func (e CloudEvent) Attributes() map[string]string {
+	attributes := map[string]string{
+		"specversion": e.SpecVersion,
+		"id":          e.ID,
+		"source":      e.Source,
+		"type":        e.Type,
+		"time":        e.Time.Format(time.RFC3339Nano),
+		"dataschema":  e.DataSchema,
+	}
+	if e.Subject != "" {
+		attributes["subject"] = e.Subject
+	}
+
+	return attributes
+}
+
+// EventBucket returns the bucket and endpoint of the event's upload, which have been
+// selected using the bucket-name and endpoint headers or are stored in the upload.
+// Both are empty, if the upload is not stored in a bucket. The endpoint is empty, if
+// the default endpoint is used.
+func EventBucket(event models.HookEvent) (bucket string, endpoint string) {
+	bucket = event.HTTPRequest.Header.Get("bucket-name")
+	if bucket == "" {
+		bucket = event.Upload.Storage["Bucket"]
+	}
+
+	return bucket, event.HTTPRequest.Header.Get("endpoint")
+}
+
+// cloudEventSource derives the source from the bucket and endpoint.
+func cloudEventSource(event models.HookEvent) string {
+	bucket, endpoint := EventBucket(event)
+	if bucket == "" {
+		return "/tusd"
+	}
+
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if endpoint == "" {
+		return "s3://" + bucket
+	}
+	if !strings.Contains(endpoint, "://") {
+		// Endpoints without scheme become network-path references.
+		endpoint = "//" + endpoint
+	}
+
+	return endpoint + "/" + bucket
+}
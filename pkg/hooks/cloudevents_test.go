@@ -0,0 +1,33 @@
+package hooks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestCloudEventID(t *testing.T) {
+	assert := assert.New(t)
+
+	req := HookRequest{
+		Type:  HookPostFinish,
+		Event: models.HookEvent{Upload: models.FileInfo{ID: "upload", Offset: 100}},
+	}
+	id := NewCloudEvent(req).ID
+	assert.Len(id, 32)
+	assert.Equal(id, NewCloudEvent(req).ID)
+
+	other := req
+	other.Type = HookPostReceive
+	assert.NotEqual(id, NewCloudEvent(other).ID)
+
+	other = req
+	other.Event.Upload.Offset = 50
+	assert.NotEqual(id, NewCloudEvent(other).ID)
+
+	// Every invocation of a pre-* hook is a separate event.
+	pre := req
+	pre.Type = HookPreGet
+	assert.NotEqual(NewCloudEvent(pre).ID, NewCloudEvent(pre).ID)
+}
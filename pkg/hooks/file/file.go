@@ -4,36 +4,156 @@
 // exist, the event will be ignored.
 // Information about the current upload and HTTP request is provided on stdin and in the
 // environment variables. By writing to stdout, the response from tusd can be influenced.
+//
+// Instead of the hook request, a CloudEvent can be provided on stdin by setting
+// FileHook.Format. In binary mode, stdin only contains its data, while its attributes
+// are provided in CE_* environment variables, e.g. CE_TYPE.
+//
+// Besides TUS_ID, TUS_SIZE and TUS_OFFSET, the environment contains TUS_HOOK_TYPE,
+// TUS_BUCKET and TUS_ENDPOINT, if the upload is stored in a bucket, and a TUS_META_*
+// variable for every metadata key, e.g. TUS_META_FILENAME, so that simple scripts do
+// not have to parse the JSON on stdin. Metadata keys are converted to upper case and
+// characters other than letters and digits are replaced by underscores. Values, which
+// cannot be passed in the environment or are too long, are only available on stdin.
+//
+// Hooks, which run longer than their timeout, are killed together with the processes
+// they started. The number of hooks running at the same time can be limited using
+// FileHook.Concurrency.
 package file
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/susufqx/dynamic-bucket-tusd/internal/semaphore"
 	"github.com/susufqx/dynamic-bucket-tusd/pkg/hooks"
 )
 
+var MetricsFileHooksQueued = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "tusd_file_hooks_queued",
+		Help: "Number of file hooks waiting for a free slot due to the concurrency limit.",
+	},
+)
+
+var MetricsFileHooksRunning = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "tusd_file_hooks_running",
+		Help: "Number of file hooks currently running.",
+	},
+)
+
+var MetricsFileHookTimeoutsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tusd_file_hook_timeouts_total",
+		Help: "Total number of file hooks killed after exceeding their timeout per hook type.",
+	},
+	[]string{"hooktype"},
+)
+
+// FileHook must be used as a pointer, e.g. &file.FileHook{Directory: "./hooks"}, since
+// Setup prepares the concurrency limit, which InvokeHook relies on. Only *FileHook
+// implements hooks.HookHandler, so that a copy without the limit cannot be used by
+// mistake.
 type FileHook struct {
 	Directory string
+	// Format selects the serialization of the hook requests. Defaults to the
+	// HookRequest as JSON.
+	Format hooks.PayloadFormat
+
+	// Timeout is the maximum duration of a hook. Afterwards, the hook's process and
+	// all processes started by it are killed and the hook fails. If zero, there is no
+	// timeout.
+	Timeout time.Duration
+	// Timeouts overrides the Timeout for individual hook types.
+	Timeouts map[hooks.HookType]time.Duration
+
+	// Concurrency limits the number of hooks running at the same time. Further hooks
+	// wait until a running hook has finished. The time spent waiting does not count
+	// towards the timeout. If zero, there is no limit.
+	Concurrency int
+
+	semaphore semaphore.Semaphore
 }
 
-func (FileHook) Setup() error {
+func (h *FileHook) Setup() error {
+	if h.Concurrency > 0 {
+		h.semaphore = semaphore.New(h.Concurrency)
+	}
+
 	return nil
 }
 
-func (h FileHook) InvokeHook(req hooks.HookRequest) (res hooks.HookResponse, err error) {
+func (h *FileHook) InvokeHook(req hooks.HookRequest) (res hooks.HookResponse, err error) {
+	if h.semaphore != nil {
+		MetricsFileHooksQueued.Inc()
+		h.semaphore.Acquire()
+		MetricsFileHooksQueued.Dec()
+		defer h.semaphore.Release()
+	}
+
+	MetricsFileHooksRunning.Inc()
+	defer MetricsFileHooksRunning.Dec()
+
+	timeout := h.Timeout
+	if typeTimeout, ok := h.Timeouts[req.Type]; ok {
+		timeout = typeTimeout
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	hookPath := h.Directory + string(os.PathSeparator) + string(req.Type)
-	cmd := exec.Command(hookPath)
+	cmd := exec.CommandContext(ctx, hookPath)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	// Do not wait for the output of processes, which survived the kill.
+	cmd.WaitDelay = time.Second
+
 	env := os.Environ()
 	env = append(env, "TUS_ID="+req.Event.Upload.ID)
 	env = append(env, "TUS_SIZE="+strconv.FormatInt(req.Event.Upload.Size, 10))
 	env = append(env, "TUS_OFFSET="+strconv.FormatInt(req.Event.Upload.Offset, 10))
+	env = append(env, "TUS_HOOK_TYPE="+string(req.Type))
+
+	bucket, endpoint := hooks.EventBucket(req.Event)
+	if bucket != "" {
+		env = append(env, "TUS_BUCKET="+bucket)
+	}
+	if endpoint != "" {
+		env = append(env, "TUS_ENDPOINT="+endpoint)
+	}
+	env = append(env, metadataEnv(req.Event.Upload.MetaData)...)
 
-	jsonReq, err := json.Marshal(req)
+	var jsonReq []byte
+	switch h.Format {
+	case hooks.PayloadCloudEventsStructured:
+		jsonReq, err = json.Marshal(hooks.NewCloudEvent(req))
+	case hooks.PayloadCloudEventsBinary:
+		event := hooks.NewCloudEvent(req)
+		jsonReq, err = json.Marshal(event.Data)
+		for name, value := range event.Attributes() {
+			env = append(env, "CE_"+strings.ToUpper(name)+"="+value)
+		}
+	default:
+		jsonReq, err = json.Marshal(req)
+	}
 	if err != nil {
 		return res, err
 	}
@@ -47,6 +167,11 @@ func (h FileHook) InvokeHook(req hooks.HookRequest) (res hooks.HookResponse, err
 
 	output, err := cmd.Output()
 
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		MetricsFileHookTimeoutsTotal.WithLabelValues(string(req.Type)).Inc()
+		return res, fmt.Errorf("hook %s timed out after %s", req.Type, timeout)
+	}
+
 	// Ignore the error if the hook's file could not be found. This usually
 	// means that the user is only using a subset of the available hooks.
 	if os.IsNotExist(err) {
@@ -72,3 +197,66 @@ func (h FileHook) InvokeHook(req hooks.HookRequest) (res hooks.HookResponse, err
 
 	return res, nil
 }
+
+const (
+	// maxMetadataEnvValue is the maximum length of a metadata value passed in a
+	// TUS_META_* variable.
+	maxMetadataEnvValue = 4 * 1024
+	// maxMetadataEnvSize is the maximum total size of the TUS_META_* variables, so
+	// that the environment stays far below the limits of the operating system.
+	maxMetadataEnvSize = 64 * 1024
+)
+
+// metadataEnv returns the TUS_META_* variables for the metadata. Values containing
+// NUL characters, which cannot be passed in the environment, and values longer than
+// maxMetadataEnvValue are left out, as are all values once maxMetadataEnvSize is
+// reached. If multiple keys are converted to the same name, e.g. file-name and
+// file_name, the first key in lexical order is used. The complete metadata is always
+// available on stdin.
+func metadataEnv(metadata map[string]string) []string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	env := make([]string, 0, len(keys))
+	names := make(map[string]bool, len(keys))
+	size := 0
+	for _, key := range keys {
+		value := metadata[key]
+		if len(value) > maxMetadataEnvValue || strings.ContainsRune(value, 0) {
+			continue
+		}
+
+		name := "TUS_META_" + envName(key)
+		if names[name] {
+			continue
+		}
+
+		variable := name + "=" + value
+		if size+len(variable) > maxMetadataEnvSize {
+			break
+		}
+
+		names[name] = true
+		size += len(variable)
+		env = append(env, variable)
+	}
+
+	return env
+}
+
+// envName converts a metadata key into the suffix of an environment variable.
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
@@ -0,0 +1,59 @@
+package file
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	env := metadataEnv(map[string]string{
+		"filename":   "Menü.txt",
+		"file_name":  "second",
+		"file-name":  "first",
+		"nul":        "a\x00b",
+		"large":      strings.Repeat("x", maxMetadataEnvValue+1),
+		"content.ty": "text/plain",
+	})
+	assert.Equal([]string{
+		"TUS_META_CONTENT_TY=text/plain",
+		"TUS_META_FILE_NAME=first",
+		"TUS_META_FILENAME=Menü.txt",
+	}, env)
+
+	// Colliding keys are resolved the same way every time.
+	for i := 0; i < 10; i++ {
+		assert.Equal(env, metadataEnv(map[string]string{
+			"filename":   "Menü.txt",
+			"file_name":  "second",
+			"file-name":  "first",
+			"content.ty": "text/plain",
+		}))
+	}
+}
+
+func TestMetadataEnvSizeLimit(t *testing.T) {
+	metadata := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		metadata[strings.Repeat("k", i+1)] = strings.Repeat("v", maxMetadataEnvValue)
+	}
+
+	size := 0
+	for _, variable := range metadataEnv(metadata) {
+		size += len(variable)
+	}
+	assert.LessOrEqual(t, size, maxMetadataEnvSize)
+	assert.Greater(t, size, 0)
+}
+
+func TestEnvName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("FILENAME", envName("filename"))
+	assert.Equal("CONTENT_TYPE", envName("content-type"))
+	assert.Equal("A_B_C9", envName("a.b c9"))
+	assert.Equal("M_NCHEN", envName("münchen"))
+}
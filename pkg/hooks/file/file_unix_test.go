@@ -0,0 +1,160 @@
+//go:build linux || darwin
+
+package file
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/hooks"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// writeHook writes an executable shell script for the hook type to the directory.
+func writeHook(t *testing.T, dir string, typ hooks.HookType, script string) {
+	if err := os.WriteFile(filepath.Join(dir, string(typ)), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var testEvent = models.HookEvent{
+	Upload: models.FileInfo{
+		ID:       "upload",
+		Size:     5,
+		MetaData: models.MetaData{"filename": "a.txt", "content-type": "text/plain", "nul": "a\x00b"},
+	},
+	HTTPRequest: models.HTTPRequest{
+		Header: http.Header{"Bucket-Name": {"bucket"}, "Endpoint": {"https://s3.example.com"}},
+	},
+}
+
+func TestFileHook(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeHook(t, dir, hooks.HookPreCreate, `cat > request.json; echo '{"RejectUpload":true}'`)
+	writeHook(t, dir, hooks.HookPostFinish, `env | grep ^TUS_ | sort > env.out`)
+	writeHook(t, dir, hooks.HookPreFinish, `echo failed; exit 3`)
+
+	h := &FileHook{Directory: dir}
+	assert.NoError(h.Setup())
+
+	res, err := h.InvokeHook(hooks.HookRequest{Type: hooks.HookPreCreate, Event: testEvent})
+	assert.NoError(err)
+	assert.True(res.RejectUpload)
+	request, err := os.ReadFile(filepath.Join(dir, "request.json"))
+	assert.NoError(err)
+	var hookReq hooks.HookRequest
+	assert.NoError(json.Unmarshal(request, &hookReq))
+	assert.Equal("upload", hookReq.Event.Upload.ID)
+	assert.Equal("a\x00b", hookReq.Event.Upload.MetaData["nul"])
+
+	// Values with NUL characters are only available on stdin.
+	_, err = h.InvokeHook(hooks.HookRequest{Type: hooks.HookPostFinish, Event: testEvent})
+	assert.NoError(err)
+	env, err := os.ReadFile(filepath.Join(dir, "env.out"))
+	assert.NoError(err)
+	assert.Equal(strings.Join([]string{
+		"TUS_BUCKET=bucket",
+		"TUS_ENDPOINT=https://s3.example.com",
+		"TUS_HOOK_TYPE=post-finish",
+		"TUS_ID=upload",
+		"TUS_META_CONTENT_TYPE=text/plain",
+		"TUS_META_FILENAME=a.txt",
+		"TUS_OFFSET=0",
+		"TUS_SIZE=5",
+	}, "\n")+"\n", string(env))
+
+	_, err = h.InvokeHook(hooks.HookRequest{Type: hooks.HookPreFinish, Event: testEvent})
+	assert.EqualError(err, "unexpected return code 3 from hook endpoint: failed\n")
+
+	// Missing hooks are ignored.
+	res, err = h.InvokeHook(hooks.HookRequest{Type: hooks.HookPostCreate, Event: testEvent})
+	assert.NoError(err)
+	assert.Equal(hooks.HookResponse{}, res)
+}
+
+func TestFileHookCloudEvents(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeHook(t, dir, hooks.HookPostFinish, `env | grep ^CE_ | sort > env.out; cat > stdin.out`)
+	event := hooks.NewCloudEvent(hooks.HookRequest{Type: hooks.HookPostFinish, Event: testEvent})
+
+	h := &FileHook{Directory: dir, Format: hooks.PayloadCloudEventsBinary}
+	assert.NoError(h.Setup())
+	_, err := h.InvokeHook(hooks.HookRequest{Type: hooks.HookPostFinish, Event: testEvent})
+	assert.NoError(err)
+
+	env, err := os.ReadFile(filepath.Join(dir, "env.out"))
+	assert.NoError(err)
+	assert.Contains(string(env), "CE_ID="+event.ID+"\n")
+	assert.Contains(string(env), "CE_TYPE="+event.Type+"\n")
+	stdin, err := os.ReadFile(filepath.Join(dir, "stdin.out"))
+	assert.NoError(err)
+	// In binary mode, stdin only contains the event's data.
+	var data models.HookEvent
+	assert.NoError(json.Unmarshal(stdin, &data))
+	assert.Equal("upload", data.Upload.ID)
+
+	h.Format = hooks.PayloadCloudEventsStructured
+	_, err = h.InvokeHook(hooks.HookRequest{Type: hooks.HookPostFinish, Event: testEvent})
+	assert.NoError(err)
+	stdin, err = os.ReadFile(filepath.Join(dir, "stdin.out"))
+	assert.NoError(err)
+	var structured hooks.CloudEvent
+	assert.NoError(json.Unmarshal(stdin, &structured))
+	assert.Equal(event.ID, structured.ID)
+}
+
+func TestFileHookTimeout(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	// The hook starts a child process, which must be killed as well.
+	writeHook(t, dir, hooks.HookPreCreate, "(sleep 30; touch survived) &\nsleep 30\n")
+
+	h := &FileHook{
+		Directory: dir,
+		Timeout:   time.Hour,
+		Timeouts:  map[hooks.HookType]time.Duration{hooks.HookPreCreate: 200 * time.Millisecond},
+	}
+	assert.NoError(h.Setup())
+
+	start := time.Now()
+	_, err := h.InvokeHook(hooks.HookRequest{Type: hooks.HookPreCreate, Event: testEvent})
+	assert.EqualError(err, "hook pre-create timed out after 200ms")
+	assert.Less(time.Since(start), 2*time.Second)
+
+	time.Sleep(100 * time.Millisecond)
+	out, _ := exec.Command("sh", "-c", "ps -eo args | grep '^sleep 30$'").Output()
+	assert.Empty(string(out))
+}
+
+func TestFileHookConcurrency(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	writeHook(t, dir, hooks.HookPostCreate, "sleep 0.3\n")
+
+	h := &FileHook{Directory: dir, Concurrency: 2}
+	assert.NoError(h.Setup())
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.InvokeHook(hooks.HookRequest{Type: hooks.HookPostCreate, Event: testEvent})
+		}()
+	}
+	wg.Wait()
+
+	// Four hooks with a limit of two run in two rounds.
+	assert.GreaterOrEqual(time.Since(start), 600*time.Millisecond)
+}
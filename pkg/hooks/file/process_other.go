@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package file
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op, since process groups are not supported on this
+// platform.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup only kills the hook's process, but not its children.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
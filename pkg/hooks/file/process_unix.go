@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package file
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the hook in its own process group, so that it can be
+// killed together with its children.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the hook's process group.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -1,18 +1,31 @@
 // Package grpc implements a gRPC-based hook system. For each hook event, the InvokeHook
 // procedure is invoked with additional details about the hook type, upload and request.
 // The Protocol Buffers are defined in github.com/susufqx/dynamic-bucket-tusd/pkg/hooks/grpc/proto/hook.proto.
+//
+// If StreamPostReceive is enabled, the post-receive hooks are sent on a stream per upload
+// using the PostReceiveStream procedure instead.
 package grpc
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
 	grpc_retry "github.com/grpc-ecosystem/go-grpc-middleware/retry"
 	"github.com/susufqx/dynamic-bucket-tusd/pkg/hooks"
 	pb "github.com/susufqx/dynamic-bucket-tusd/pkg/hooks/grpc/proto"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+	"golang.org/x/exp/slog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type GrpcHook struct {
@@ -20,6 +33,38 @@ type GrpcHook struct {
 	MaxRetries int
 	Backoff    time.Duration
 	Client     pb.HookHandlerClient
+
+	// Timeout is the deadline for every call to InvokeHook, including retries. If zero,
+	// there is no deadline.
+	Timeout time.Duration
+
+	// Secure enables TLS for the connection. The server's certificate is verified using
+	// the system's root certificates or ServerTLSCertificateFilePath. Setup fails if
+	// any of the certificate paths below is set while Secure is disabled.
+	Secure bool
+	// ServerTLSCertificateFilePath is the path to a PEM-encoded certificate of the
+	// server or its certificate authority.
+	ServerTLSCertificateFilePath string
+	// ClientTLSCertificateFilePath and ClientTLSCertificateKeyFilePath are the paths to
+	// the PEM-encoded certificate and key, which tusd presents to the server for mutual
+	// TLS. Both must be set to enable mutual TLS.
+	ClientTLSCertificateFilePath    string
+	ClientTLSCertificateKeyFilePath string
+
+	// StreamPostReceive sends the post-receive hooks on a stream per upload instead of
+	// invoking the InvokeHook procedure for every progress update. Responses to stop the
+	// upload may arrive at any time on the stream and are applied immediately, so
+	// InvokeHook returns an empty response for post-receive hooks.
+	StreamPostReceive bool
+	// StreamIdleTimeout is the time without progress updates, after which the stream for
+	// an upload is closed. Defaults to 30s.
+	StreamIdleTimeout time.Duration
+	// StreamCloseTimeout is the time to wait for the server to end a stream after tusd
+	// has closed its sending side, before the stream is cancelled. Defaults to 5s.
+	StreamCloseTimeout time.Duration
+
+	streams      map[string]*progressStream
+	streamsMutex *sync.Mutex
 }
 
 func (g *GrpcHook) Setup() error {
@@ -27,8 +72,12 @@ func (g *GrpcHook) Setup() error {
 		grpc_retry.WithBackoff(grpc_retry.BackoffLinear(g.Backoff)),
 		grpc_retry.WithMax(uint(g.MaxRetries)),
 	}
+	creds, err := g.transportCredentials()
+	if err != nil {
+		return err
+	}
 	grpcOpts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 		grpc.WithUnaryInterceptor(grpc_retry.UnaryClientInterceptor(opts...)),
 	}
 	conn, err := grpc.Dial(g.Endpoint, grpcOpts...)
@@ -36,11 +85,79 @@ func (g *GrpcHook) Setup() error {
 		return err
 	}
 	g.Client = pb.NewHookHandlerClient(conn)
+
+	if g.StreamPostReceive {
+		if g.StreamIdleTimeout <= 0 {
+			g.StreamIdleTimeout = 30 * time.Second
+		}
+		if g.StreamCloseTimeout <= 0 {
+			g.StreamCloseTimeout = 5 * time.Second
+		}
+		g.streams = make(map[string]*progressStream)
+		g.streamsMutex = new(sync.Mutex)
+		go g.closeIdleStreams()
+	}
+
 	return nil
 }
 
+// transportCredentials returns the credentials for the connection according to the
+// TLS settings.
+func (g *GrpcHook) transportCredentials() (credentials.TransportCredentials, error) {
+	if !g.Secure {
+		if g.ServerTLSCertificateFilePath != "" || g.ClientTLSCertificateFilePath != "" || g.ClientTLSCertificateKeyFilePath != "" {
+			return nil, errors.New("TLS certificates are set, but Secure is disabled")
+		}
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if g.ServerTLSCertificateFilePath != "" {
+		pem, err := os.ReadFile(g.ServerTLSCertificateFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read server certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", g.ServerTLSCertificateFilePath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if g.ClientTLSCertificateFilePath != "" || g.ClientTLSCertificateKeyFilePath != "" {
+		if g.ClientTLSCertificateFilePath == "" || g.ClientTLSCertificateKeyFilePath == "" {
+			return nil, errors.New("both the client certificate and key must be set for mutual TLS")
+		}
+		cert, err := tls.LoadX509KeyPair(g.ClientTLSCertificateFilePath, g.ClientTLSCertificateKeyFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
 func (g *GrpcHook) InvokeHook(hookReq hooks.HookRequest) (hookRes hooks.HookResponse, err error) {
+	if g.StreamPostReceive {
+		switch hookReq.Type {
+		case hooks.HookPostReceive:
+			return hookRes, g.sendProgress(hookReq)
+		case hooks.HookPostFinish, hooks.HookPostTerminate:
+			g.closeStream(hookReq.Event.Upload.ID)
+		}
+	}
+
 	ctx := context.Background()
+	if g.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.Timeout)
+		defer cancel()
+	}
+
 	req := marshal(hookReq)
 	res, err := g.Client.InvokeHook(ctx, req)
 	if err != nil {
@@ -51,6 +168,149 @@ func (g *GrpcHook) InvokeHook(hookReq hooks.HookRequest) (hookRes hooks.HookResp
 	return hookRes, nil
 }
 
+// progressStream is the stream for the post-receive hooks of an upload.
+type progressStream struct {
+	stream pb.HookHandler_PostReceiveStreamClient
+	cancel context.CancelFunc
+	// done is closed once the server has ended the stream.
+	done chan struct{}
+
+	// mutex protects sending on the stream and the fields below.
+	mutex sync.Mutex
+	// upload is the upload from the latest request, whose StopUpload is called if the
+	// handler responds with stopUpload.
+	upload   models.FileInfo
+	lastSent time.Time
+}
+
+// sendProgress sends the hook request on the upload's stream, which is opened if
+// necessary.
+func (g *GrpcHook) sendProgress(hookReq hooks.HookRequest) error {
+	id := hookReq.Event.Upload.ID
+
+	g.streamsMutex.Lock()
+	s, ok := g.streams[id]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := g.Client.PostReceiveStream(ctx)
+		if err != nil {
+			g.streamsMutex.Unlock()
+			cancel()
+			return err
+		}
+
+		s = &progressStream{
+			stream: stream,
+			cancel: cancel,
+			done:   make(chan struct{}),
+		}
+		g.streams[id] = s
+		go g.receiveResponses(id, s)
+	}
+	g.streamsMutex.Unlock()
+
+	s.mutex.Lock()
+	s.upload = hookReq.Event.Upload
+	s.lastSent = time.Now()
+	err := s.stream.Send(marshal(hookReq))
+	s.mutex.Unlock()
+
+	if err != nil {
+		g.closeStream(id)
+		return err
+	}
+
+	return nil
+}
+
+// receiveResponses applies the responses from the stream until it is closed.
+func (g *GrpcHook) receiveResponses(id string, s *progressStream) {
+	defer func() {
+		g.streamsMutex.Lock()
+		if g.streams[id] == s {
+			delete(g.streams, id)
+		}
+		g.streamsMutex.Unlock()
+		close(s.done)
+		s.cancel()
+	}()
+
+	for {
+		res, err := s.stream.Recv()
+		if err != nil {
+			return
+		}
+
+		hookRes := unmarshal(res)
+		if hookRes.StopUpload {
+			slog.Info("HookStopUpload", "id", id)
+
+			s.mutex.Lock()
+			upload := s.upload
+			s.mutex.Unlock()
+
+			upload.StopUpload(hookRes.HTTPResponse)
+		}
+	}
+}
+
+// closeStream closes the upload's stream, if one is open. The server is given
+// StreamCloseTimeout to process the remaining requests and end the stream, before it
+// is cancelled.
+func (g *GrpcHook) closeStream(id string) {
+	g.streamsMutex.Lock()
+	s, ok := g.streams[id]
+	delete(g.streams, id)
+	g.streamsMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	s.mutex.Lock()
+	err := s.stream.CloseSend()
+	s.mutex.Unlock()
+
+	if err == nil {
+		timer := time.NewTimer(g.StreamCloseTimeout)
+		select {
+		case <-s.done:
+		case <-timer.C:
+			slog.Warn("HookStreamCloseTimeout", "id", id)
+		}
+		timer.Stop()
+	}
+
+	s.cancel()
+}
+
+// closeIdleStreams regularly closes the streams, on which no progress has been sent
+// for StreamIdleTimeout.
+func (g *GrpcHook) closeIdleStreams() {
+	ticker := time.NewTicker(g.StreamIdleTimeout / 2)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		var idle []string
+
+		g.streamsMutex.Lock()
+		for id, s := range g.streams {
+			s.mutex.Lock()
+			if time.Since(s.lastSent) > g.StreamIdleTimeout {
+				idle = append(idle, id)
+			}
+			s.mutex.Unlock()
+		}
+		g.streamsMutex.Unlock()
+
+		// The streams are closed concurrently, so that a slow server does not
+		// delay closing the others.
+		for _, id := range idle {
+			go g.closeStream(id)
+		}
+	}
+}
+
 func marshal(hookReq hooks.HookRequest) *pb.HookRequest {
 	event := hookReq.Event
 
@@ -67,17 +327,29 @@ func marshal(hookReq hooks.HookRequest) *pb.HookRequest {
 				IsFinal:        event.Upload.IsFinal,
 				PartialUploads: event.Upload.PartialUploads,
 				Storage:        event.Upload.Storage,
+				Digests:        event.Upload.Digests,
+				ExpiresAt:      marshalTime(event.Upload.ExpiresAt),
 			},
 			HttpRequest: &pb.HTTPRequest{
 				Method:     event.HTTPRequest.Method,
 				Uri:        event.HTTPRequest.URI,
 				RemoteAddr: event.HTTPRequest.RemoteAddr,
 				Header:     getHeader(event.HTTPRequest.Header),
+				Headers:    getHeaders(event.HTTPRequest.Header),
 			},
 		},
 	}
 }
 
+// marshalTime converts the time into a timestamp, which is unset for the zero time.
+func marshalTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+
+	return timestamppb.New(t)
+}
+
 func getHeader(httpHeader http.Header) (hookHeader map[string]string) {
 	hookHeader = make(map[string]string)
 	for key, val := range httpHeader {
@@ -88,9 +360,20 @@ func getHeader(httpHeader http.Header) (hookHeader map[string]string) {
 	return hookHeader
 }
 
+func getHeaders(httpHeader http.Header) (hookHeaders map[string]*pb.HeaderValues) {
+	hookHeaders = make(map[string]*pb.HeaderValues)
+	for key, val := range httpHeader {
+		if key != "" && len(val) > 0 {
+			hookHeaders[key] = &pb.HeaderValues{Values: val}
+		}
+	}
+	return hookHeaders
+}
+
 func unmarshal(res *pb.HookResponse) (hookRes hooks.HookResponse) {
 	hookRes.RejectUpload = res.RejectUpload
 	hookRes.StopUpload = res.StopUpload
+	hookRes.RejectPresign = res.RejectPresign
 
 	httpRes := res.HttpResponse
 	if httpRes != nil {
@@ -104,6 +387,9 @@ func unmarshal(res *pb.HookResponse) (hookRes hooks.HookResponse) {
 		hookRes.ChangeFileInfo.ID = changes.Id
 		hookRes.ChangeFileInfo.MetaData = changes.MetaData
 		hookRes.ChangeFileInfo.Storage = changes.Storage
+		if changes.ExpiresAt != nil {
+			hookRes.ChangeFileInfo.ExpiresAt = changes.ExpiresAt.AsTime()
+		}
 	}
 
 	return hookRes
@@ -0,0 +1,372 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/config"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/filestore"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/hooks"
+	pb "github.com/susufqx/dynamic-bucket-tusd/pkg/hooks/grpc/proto"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testHookServer records the requests and responds to them using the given functions.
+type testHookServer struct {
+	pb.UnimplementedHookHandlerServer
+
+	invoke   func(req *pb.HookRequest) *pb.HookResponse
+	progress func(req *pb.HookRequest) *pb.HookResponse
+
+	mutex    sync.Mutex
+	requests []*pb.HookRequest
+	// streamErrs are the errors, with which the streams ended.
+	streamErrs chan error
+}
+
+func (s *testHookServer) record(req *pb.HookRequest) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+func (s *testHookServer) InvokeHook(ctx context.Context, req *pb.HookRequest) (*pb.HookResponse, error) {
+	s.record(req)
+	if s.invoke == nil {
+		return &pb.HookResponse{}, nil
+	}
+	return s.invoke(req), nil
+}
+
+func (s *testHookServer) PostReceiveStream(stream pb.HookHandler_PostReceiveStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			s.streamErrs <- err
+			return nil
+		}
+
+		s.record(req)
+		if s.progress != nil {
+			if res := s.progress(req); res != nil {
+				stream.Send(res)
+			}
+		}
+	}
+}
+
+// startTestServer starts a gRPC server for the hook server and returns its address.
+func startTestServer(t *testing.T, server *testHookServer, opts ...grpc.ServerOption) string {
+	server.streamErrs = make(chan error, 10)
+	grpcServer := grpc.NewServer(opts...)
+	pb.RegisterHookHandlerServer(grpcServer, server)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	return listener.Addr().String()
+}
+
+// writeCertificate creates a certificate for 127.0.0.1 and writes it to <name>.pem and
+// its key to <name>.key in the directory. If parent is nil, the certificate is a
+// self-signed certificate authority.
+func writeCertificate(t *testing.T, dir string, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func TestGrpcHook(t *testing.T) {
+	assert := assert.New(t)
+	server := &testHookServer{
+		invoke: func(req *pb.HookRequest) *pb.HookResponse {
+			if req.Type == string(hooks.HookPreFinish) {
+				time.Sleep(500 * time.Millisecond)
+			}
+			return &pb.HookResponse{
+				RejectUpload: true,
+				HttpResponse: &pb.HTTPResponse{StatusCode: 400, Body: "rejected"},
+			}
+		},
+	}
+	g := &GrpcHook{
+		Endpoint: startTestServer(t, server),
+		Timeout:  200 * time.Millisecond,
+	}
+	assert.NoError(g.Setup())
+
+	header := http.Header{"X-Multi": {"a", "b"}}
+	res, err := g.InvokeHook(hooks.HookRequest{
+		Type:  hooks.HookPreCreate,
+		Event: models.HookEvent{HTTPRequest: models.HTTPRequest{Header: header}},
+	})
+	assert.NoError(err)
+	assert.True(res.RejectUpload)
+	assert.Equal(models.HTTPResponse{StatusCode: 400, Body: "rejected"}, res.HTTPResponse)
+
+	server.mutex.Lock()
+	if assert.Len(server.requests, 1) {
+		httpReq := server.requests[0].Event.HttpRequest
+		assert.Equal("a", httpReq.Header["X-Multi"])
+		assert.Equal([]string{"a", "b"}, httpReq.Headers["X-Multi"].GetValues())
+	}
+	server.mutex.Unlock()
+
+	// The call is cancelled after the timeout.
+	_, err = g.InvokeHook(hooks.HookRequest{Type: hooks.HookPreFinish})
+	assert.ErrorContains(err, "DeadlineExceeded")
+}
+
+func TestGrpcHookTLS(t *testing.T) {
+	assert := assert.New(t)
+	dir := t.TempDir()
+	ca, caKey := writeCertificate(t, dir, "ca", nil, nil)
+	writeCertificate(t, dir, "server", ca, caKey)
+	writeCertificate(t, dir, "client", ca, caKey)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	serverCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := startTestServer(t, &testHookServer{}, grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+
+	// The server requires a client certificate.
+	g := &GrpcHook{
+		Endpoint:                     endpoint,
+		Timeout:                      time.Second,
+		Secure:                       true,
+		ServerTLSCertificateFilePath: filepath.Join(dir, "ca.pem"),
+	}
+	assert.NoError(g.Setup())
+	_, err = g.InvokeHook(hooks.HookRequest{Type: hooks.HookPreCreate})
+	assert.Error(err)
+
+	g.ClientTLSCertificateFilePath = filepath.Join(dir, "client.pem")
+	g.ClientTLSCertificateKeyFilePath = filepath.Join(dir, "client.key")
+	assert.NoError(g.Setup())
+	_, err = g.InvokeHook(hooks.HookRequest{Type: hooks.HookPreCreate})
+	assert.NoError(err)
+
+	// Incomplete or ignored TLS settings are rejected.
+	g = &GrpcHook{
+		Endpoint:                     endpoint,
+		Secure:                       true,
+		ClientTLSCertificateFilePath: filepath.Join(dir, "client.pem"),
+	}
+	assert.ErrorContains(g.Setup(), "both the client certificate and key must be set")
+	g = &GrpcHook{
+		Endpoint:                     endpoint,
+		ServerTLSCertificateFilePath: filepath.Join(dir, "ca.pem"),
+	}
+	assert.ErrorContains(g.Setup(), "Secure is disabled")
+	g = &GrpcHook{
+		Endpoint:                        endpoint,
+		ClientTLSCertificateFilePath:    filepath.Join(dir, "client.pem"),
+		ClientTLSCertificateKeyFilePath: filepath.Join(dir, "client.key"),
+	}
+	assert.ErrorContains(g.Setup(), "Secure is disabled")
+}
+
+func TestGrpcHookStreamPostReceive(t *testing.T) {
+	assert := assert.New(t)
+	server := &testHookServer{
+		progress: func(req *pb.HookRequest) *pb.HookResponse {
+			if req.Event.Upload.Offset < 3 {
+				return nil
+			}
+			return &pb.HookResponse{StopUpload: true, HttpResponse: &pb.HTTPResponse{Body: "stopped"}}
+		},
+	}
+	g := &GrpcHook{
+		Endpoint:          startTestServer(t, server),
+		StreamPostReceive: true,
+		StreamIdleTimeout: 200 * time.Millisecond,
+	}
+	assert.NoError(g.Setup())
+
+	stopped := make(chan models.HTTPResponse, 1)
+	upload := models.FileInfo{ID: "upload"}
+	upload.SetStopUpload(func(res models.HTTPResponse) {
+		stopped <- res
+	})
+	for offset := int64(1); offset <= 3; offset++ {
+		upload.Offset = offset
+		_, err := g.InvokeHook(hooks.HookRequest{Type: hooks.HookPostReceive, Event: models.HookEvent{Upload: upload}})
+		assert.NoError(err)
+	}
+
+	select {
+	case res := <-stopped:
+		assert.Equal("stopped", res.Body)
+	case <-time.After(time.Second):
+		t.Error("upload has not been stopped")
+	}
+
+	// Idle streams are closed gracefully, so that the server receives io.EOF.
+	select {
+	case err := <-server.streamErrs:
+		assert.Equal(io.EOF, err)
+	case <-time.After(time.Second):
+		t.Error("idle stream has not been closed")
+	}
+
+	// post-finish closes the stream after all progress has been received.
+	upload.Offset = 4
+	_, err := g.InvokeHook(hooks.HookRequest{Type: hooks.HookPostReceive, Event: models.HookEvent{Upload: upload}})
+	assert.NoError(err)
+	_, err = g.InvokeHook(hooks.HookRequest{Type: hooks.HookPostFinish, Event: models.HookEvent{Upload: upload}})
+	assert.NoError(err)
+	assert.Equal(io.EOF, <-server.streamErrs)
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	var types []string
+	for _, req := range server.requests {
+		types = append(types, req.Type)
+	}
+	assert.Equal([]string{"post-receive", "post-receive", "post-receive", "post-receive", "post-finish"}, types)
+}
+
+func TestGrpcHookStreamCloseTimeout(t *testing.T) {
+	assert := assert.New(t)
+	release := make(chan struct{})
+	server := &testHookServer{
+		// The server does not end the stream until it is released.
+		progress: func(req *pb.HookRequest) *pb.HookResponse {
+			<-release
+			return nil
+		},
+	}
+	g := &GrpcHook{
+		Endpoint:           startTestServer(t, server),
+		StreamPostReceive:  true,
+		StreamCloseTimeout: 100 * time.Millisecond,
+	}
+	assert.NoError(g.Setup())
+	defer close(release)
+
+	upload := models.FileInfo{ID: "upload", Offset: 1}
+	_, err := g.InvokeHook(hooks.HookRequest{Type: hooks.HookPostReceive, Event: models.HookEvent{Upload: upload}})
+	assert.NoError(err)
+
+	start := time.Now()
+	g.closeStream(upload.ID)
+	assert.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+	assert.Less(time.Since(start), time.Second)
+}
+
+func TestGrpcHookExpiresAt(t *testing.T) {
+	assert := assert.New(t)
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	server := &testHookServer{
+		invoke: func(req *pb.HookRequest) *pb.HookResponse {
+			if req.Type != string(hooks.HookPreCreate) {
+				return &pb.HookResponse{}
+			}
+			return &pb.HookResponse{ChangeFileInfo: &pb.FileInfoChanges{ExpiresAt: timestamppb.New(expiresAt)}}
+		},
+	}
+	g := &GrpcHook{Endpoint: startTestServer(t, server)}
+
+	composer := models.NewStoreComposer()
+	filestore.New(t.TempDir()).UseIn(composer)
+	cfg := config.Config{BasePath: "/files/", StoreComposer: composer, UploadExpiration: time.Hour}
+	handler, err := hooks.NewHandlerWithHooks(&cfg, g, []hooks.HookType{hooks.HookPreCreate, hooks.HookPostCreate})
+	assert.NoError(err)
+	httpServer := httptest.NewServer(http.StripPrefix("/files/", handler))
+	defer httpServer.Close()
+
+	req, _ := http.NewRequest("POST", httpServer.URL+"/files/", nil)
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Upload-Length", "5")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	assert.Equal(http.StatusCreated, res.StatusCode)
+	assert.Equal("Wed, 02 Jan 2030 03:04:05 GMT", res.Header.Get("Upload-Expires"))
+
+	// The expiration is sent to the following hooks, which are invoked asynchronously.
+	var postCreate *pb.HookRequest
+	for start := time.Now(); postCreate == nil && time.Since(start) < time.Second; time.Sleep(10 * time.Millisecond) {
+		server.mutex.Lock()
+		for _, req := range server.requests {
+			if req.Type == string(hooks.HookPostCreate) {
+				postCreate = req
+			}
+		}
+		server.mutex.Unlock()
+	}
+	if assert.NotNil(postCreate) {
+		assert.True(postCreate.Event.Upload.ExpiresAt.AsTime().Equal(expiresAt))
+	}
+}
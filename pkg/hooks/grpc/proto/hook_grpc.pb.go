@@ -28,6 +28,13 @@ type HookHandlerClient interface {
 	// The return value HookResponse allows to stop or reject an upload, as well as modifying
 	// the HTTP response. See the documentation for HookResponse for more details.
 	InvokeHook(ctx context.Context, in *HookRequest, opts ...grpc.CallOption) (*HookResponse, error)
+	// PostReceiveStream is used instead of InvokeHook for the post-receive hooks, if
+	// enabled in tusd. A stream is opened for every upload and a HookRequest is sent on
+	// it for every progress update. tusd closes the stream once the upload is finished
+	// or terminated, or no progress has been reported for a while. The handler does not
+	// need to respond to every request, but can send a HookResponse with stopUpload at
+	// any time to stop the upload, optionally including an httpResponse.
+	PostReceiveStream(ctx context.Context, opts ...grpc.CallOption) (HookHandler_PostReceiveStreamClient, error)
 }
 
 type hookHandlerClient struct {
@@ -47,6 +54,37 @@ func (c *hookHandlerClient) InvokeHook(ctx context.Context, in *HookRequest, opt
 	return out, nil
 }
 
+func (c *hookHandlerClient) PostReceiveStream(ctx context.Context, opts ...grpc.CallOption) (HookHandler_PostReceiveStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &HookHandler_ServiceDesc.Streams[0], "/proto.HookHandler/PostReceiveStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hookHandlerPostReceiveStreamClient{stream}
+	return x, nil
+}
+
+type HookHandler_PostReceiveStreamClient interface {
+	Send(*HookRequest) error
+	Recv() (*HookResponse, error)
+	grpc.ClientStream
+}
+
+type hookHandlerPostReceiveStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *hookHandlerPostReceiveStreamClient) Send(m *HookRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *hookHandlerPostReceiveStreamClient) Recv() (*HookResponse, error) {
+	m := new(HookResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // HookHandlerServer is the server API for HookHandler service.
 // All implementations must embed UnimplementedHookHandlerServer
 // for forward compatibility
@@ -57,6 +95,13 @@ type HookHandlerServer interface {
 	// The return value HookResponse allows to stop or reject an upload, as well as modifying
 	// the HTTP response. See the documentation for HookResponse for more details.
 	InvokeHook(context.Context, *HookRequest) (*HookResponse, error)
+	// PostReceiveStream is used instead of InvokeHook for the post-receive hooks, if
+	// enabled in tusd. A stream is opened for every upload and a HookRequest is sent on
+	// it for every progress update. tusd closes the stream once the upload is finished
+	// or terminated, or no progress has been reported for a while. The handler does not
+	// need to respond to every request, but can send a HookResponse with stopUpload at
+	// any time to stop the upload, optionally including an httpResponse.
+	PostReceiveStream(HookHandler_PostReceiveStreamServer) error
 	mustEmbedUnimplementedHookHandlerServer()
 }
 
@@ -67,6 +112,9 @@ type UnimplementedHookHandlerServer struct {
 func (UnimplementedHookHandlerServer) InvokeHook(context.Context, *HookRequest) (*HookResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method InvokeHook not implemented")
 }
+func (UnimplementedHookHandlerServer) PostReceiveStream(HookHandler_PostReceiveStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method PostReceiveStream not implemented")
+}
 func (UnimplementedHookHandlerServer) mustEmbedUnimplementedHookHandlerServer() {}
 
 // UnsafeHookHandlerServer may be embedded to opt out of forward compatibility for this service.
@@ -98,6 +146,32 @@ func _HookHandler_InvokeHook_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _HookHandler_PostReceiveStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HookHandlerServer).PostReceiveStream(&hookHandlerPostReceiveStreamServer{stream})
+}
+
+type HookHandler_PostReceiveStreamServer interface {
+	Send(*HookResponse) error
+	Recv() (*HookRequest, error)
+	grpc.ServerStream
+}
+
+type hookHandlerPostReceiveStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *hookHandlerPostReceiveStreamServer) Send(m *HookResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *hookHandlerPostReceiveStreamServer) Recv() (*HookRequest, error) {
+	m := new(HookRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // HookHandler_ServiceDesc is the grpc.ServiceDesc for HookHandler service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -110,6 +184,13 @@ var HookHandler_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _HookHandler_InvokeHook_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PostReceiveStream",
+			Handler:       _HookHandler_PostReceiveStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "pkg/hooks/grpc/proto/hook.proto",
 }
@@ -1,5 +1,5 @@
 // Package hooks allows you to execute hooks based on events emitted from the tusd handler
-// using the callbacks and notification channels. The actual hook systems are implemented
+// using the callbacks and the event bus. The actual hook systems are implemented
 // in the subpackages and this package provides the glue betwen the tusd handler and the hook
 // system. For example, to use the HTTP-based hook system:
 //
@@ -54,7 +54,8 @@ type HookRequest struct {
 // HookResponse is the response after a hook is executed.
 type HookResponse struct {
 	// HTTPResponse's fields can be filled to modify the HTTP response.
-	// This is only possible for pre-create, pre-finish and post-receive hooks.
+	// This is only possible for pre-create, pre-finish, pre-get, pre-head, pre-patch,
+	// pre-terminate and post-receive hooks.
 	// For other hooks this value is ignored.
 	// If multiple hooks modify the HTTP response, a later hook may overwrite the
 	// modified values from a previous hook (e.g. if multiple post-receive hooks
@@ -65,9 +66,10 @@ type HookResponse struct {
 	HTTPResponse models.HTTPResponse
 
 	// RejectUpload will cause the upload to be rejected and not be created during
-	// POST request. This value is only respected for pre-create hooks. For other hooks,
-	// it is ignored. Use the HTTPResponse field to send details about the rejection
-	// to the client.
+	// POST request. For pre-get, pre-head, pre-patch and pre-terminate hooks, it causes
+	// the corresponding request to be rejected, i.e. the upload is not downloaded,
+	// inspected, written to or terminated. For other hooks, it is ignored. Use the
+	// HTTPResponse field to send details about the rejection to the client.
 	RejectUpload bool
 
 	// ChangeFileInfo can be set to change selected properties of an upload before
@@ -82,6 +84,11 @@ type HookResponse struct {
 	// it is ignored. Use the HTTPResponse field to send details about the stop
 	// to the client.
 	StopUpload bool
+
+	// RejectPresign will cause the download to be served by tusd instead of
+	// redirecting the client to a presigned URL. This value is only respected
+	// for pre-download-presign hooks.
+	RejectPresign bool
 }
 
 type HookType string
@@ -93,11 +100,37 @@ const (
 	HookPostCreate    HookType = "post-create"
 	HookPreCreate     HookType = "pre-create"
 	HookPreFinish     HookType = "pre-finish"
+
+	HookPreDownloadPresign HookType = "pre-download-presign"
+
+	HookPreGet       HookType = "pre-get"
+	HookPreHead      HookType = "pre-head"
+	HookPrePatch     HookType = "pre-patch"
+	HookPreTerminate HookType = "pre-terminate"
 )
 
-// AvailableHooks is a slice of all hooks that are implemented by tusd.
+// postHookTypes maps the events from the handler's event bus to the post-* hooks.
+var postHookTypes = map[models.EventType]HookType{
+	models.EventUploadCreated:    HookPostCreate,
+	models.EventUploadProgress:   HookPostReceive,
+	models.EventUploadFinished:   HookPostFinish,
+	models.EventUploadTerminated: HookPostTerminate,
+}
+
+// EventBufferSize is the number of events buffered for the post-* hooks by
+// NewHandlerWithHooks.
+var EventBufferSize = 1000
+
+// AvailableHooks is a slice of all hooks that are enabled by default.
 var AvailableHooks []HookType = []HookType{HookPreCreate, HookPostCreate, HookPostReceive, HookPostTerminate, HookPostFinish, HookPreFinish}
 
+// OptionalHooks is a slice of the hooks that are implemented by tusd, but not
+// contained in AvailableHooks. They are invoked synchronously for every download,
+// HEAD, PATCH or DELETE request and therefore add a round trip to these requests.
+// They must be enabled explicitly by passing them to NewHandlerWithHooks, e.g.
+// append(hooks.AvailableHooks, hooks.HookPreGet).
+var OptionalHooks []HookType = []HookType{HookPreDownloadPresign, HookPreGet, HookPreHead, HookPrePatch, HookPreTerminate}
+
 func preCreateCallback(event models.HookEvent, hookHandler HookHandler) (models.HTTPResponse, models.FileInfoChanges, error) {
 	ok, hookRes, err := invokeHookSync(HookPreCreate, event, hookHandler)
 	if !ok || err != nil {
@@ -130,6 +163,36 @@ func preFinishCallback(event models.HookEvent, hookHandler HookHandler) (models.
 	return httpRes, nil
 }
 
+func preDownloadPresignCallback(event models.HookEvent, hookHandler HookHandler) (bool, error) {
+	ok, hookRes, err := invokeHookSync(HookPreDownloadPresign, event, hookHandler)
+	if !ok || err != nil {
+		return false, err
+	}
+
+	return !hookRes.RejectPresign, nil
+}
+
+// preRequestCallback invokes a hook before a request is processed. If the hook response
+// includes the instruction to reject the request, rejectErr is returned including the
+// custom HTTP response values.
+func preRequestCallback(typ HookType, rejectErr models.Error, event models.HookEvent, hookHandler HookHandler) (models.HTTPResponse, error) {
+	ok, hookRes, err := invokeHookSync(typ, event, hookHandler)
+	if !ok || err != nil {
+		return models.HTTPResponse{}, err
+	}
+
+	httpRes := hookRes.HTTPResponse
+
+	if hookRes.RejectUpload {
+		err := rejectErr
+		err.HTTPResponse = err.HTTPResponse.MergeWith(httpRes)
+
+		return models.HTTPResponse{}, err
+	}
+
+	return httpRes, nil
+}
+
 func postReceiveCallback(event models.HookEvent, hookHandler HookHandler) {
 	ok, hookRes, _ := invokeHookSync(HookPostReceive, event, hookHandler)
 	// invokeHookSync already logs the error, if any occurs. So by checking `ok`, we can ensure
@@ -168,12 +231,25 @@ func SetupHookMetrics() {
 	MetricsHookErrorsTotal.WithLabelValues(string(HookPostCreate)).Add(0)
 	MetricsHookErrorsTotal.WithLabelValues(string(HookPreCreate)).Add(0)
 	MetricsHookErrorsTotal.WithLabelValues(string(HookPreFinish)).Add(0)
+	MetricsHookErrorsTotal.WithLabelValues(string(HookPreDownloadPresign)).Add(0)
+	MetricsHookErrorsTotal.WithLabelValues(string(HookPreGet)).Add(0)
+	MetricsHookErrorsTotal.WithLabelValues(string(HookPreHead)).Add(0)
+	MetricsHookErrorsTotal.WithLabelValues(string(HookPrePatch)).Add(0)
+	MetricsHookErrorsTotal.WithLabelValues(string(HookPreTerminate)).Add(0)
 	MetricsHookInvocationsTotal.WithLabelValues(string(HookPostFinish)).Add(0)
 	MetricsHookInvocationsTotal.WithLabelValues(string(HookPostTerminate)).Add(0)
 	MetricsHookInvocationsTotal.WithLabelValues(string(HookPostReceive)).Add(0)
 	MetricsHookInvocationsTotal.WithLabelValues(string(HookPostCreate)).Add(0)
 	MetricsHookInvocationsTotal.WithLabelValues(string(HookPreCreate)).Add(0)
 	MetricsHookInvocationsTotal.WithLabelValues(string(HookPreFinish)).Add(0)
+	MetricsHookInvocationsTotal.WithLabelValues(string(HookPreDownloadPresign)).Add(0)
+	MetricsHookInvocationsTotal.WithLabelValues(string(HookPreGet)).Add(0)
+	MetricsHookInvocationsTotal.WithLabelValues(string(HookPreHead)).Add(0)
+	MetricsHookInvocationsTotal.WithLabelValues(string(HookPrePatch)).Add(0)
+	MetricsHookInvocationsTotal.WithLabelValues(string(HookPreTerminate)).Add(0)
+	MetricsHookDeadLettersTotal.WithLabelValues(string(HookPostFinish)).Add(0)
+	MetricsHookDeadLettersTotal.WithLabelValues(string(HookPostTerminate)).Add(0)
+	MetricsHookDeadLettersTotal.WithLabelValues(string(HookPostCreate)).Add(0)
 }
 
 func invokeHookAsync(typ HookType, event models.HookEvent, hookHandler HookHandler) {
@@ -213,10 +289,11 @@ func invokeHookSync(typ HookType, event models.HookEvent, hookHandler HookHandle
 	return true, res, nil
 }
 
-// NewHandlerWithHooks creates a tusd request handler, whose notifcation channels and callbacks are configured to
-// emit the hooks on the provided hook models. NewHandlerWithHooks will overwrite the `config.Notify*` and `config.*Callback`
+// NewHandlerWithHooks creates a tusd request handler, whose event bus and callbacks are configured to
+// emit the hooks on the provided hook models. NewHandlerWithHooks will overwrite the `config.*Callback`
 // fields depending on the enabled hooks. These can be controlled via the `enabledHooks` slice. Non-enabled hooks will
-// not be emitted.
+// not be emitted. Passing AvailableHooks enables the default hooks; the hooks in OptionalHooks have to be added
+// explicitly.
 //
 // If you want to create an UnroutedHandler instead of the routed handler, you can first create a routed handler and then
 // extract an unrouted one:
@@ -224,20 +301,24 @@ func invokeHookSync(typ HookType, event models.HookEvent, hookHandler HookHandle
 //	routedHandler := hooks.NewHandlerWithHooks(...)
 //	unroutedHandler := routedmodels.UnroutedHandler
 //
-// Note: NewHandlerWithHooks sets up a goroutine to consume the notfication channels (CompleteUploads, TerminatedUploads,
-// CreatedUploads, UploadProgress) on the created models. These channels must not be consumed by the caller or otherwise
-// events might not be passed to the hook models.
+// Note: NewHandlerWithHooks sets up a goroutine, which subscribes to the handler's event bus for the post-* hooks.
+// Its buffer holds EventBufferSize events and requests wait if it is full, so that no hook is lost. The notification
+// channels (CompleteUploads, TerminatedUploads, CreatedUploads, UploadProgress) are not used and remain available
+// to the caller, if enabled using the `config.Notify*` fields.
 func NewHandlerWithHooks(config *config.Config, hookHandler HookHandler, enabledHooks []HookType) (*handler.Handler, error) {
+	return NewHandlerWithOutbox(config, hookHandler, enabledHooks, nil)
+}
+
+// NewHandlerWithOutbox is like NewHandlerWithHooks, but delivers the post-create, post-finish and
+// post-terminate hooks through the outbox, so that they are retried if the hook handler fails and
+// survive a restart of the process. The outbox is started using the hook handler and should be
+// stopped using Outbox.Stop when shutting down. If outbox is nil, the hooks are invoked once without
+// retries, as done by NewHandlerWithHooks.
+func NewHandlerWithOutbox(config *config.Config, hookHandler HookHandler, enabledHooks []HookType, outbox *Outbox) (*handler.Handler, error) {
 	if err := hookHandler.Setup(); err != nil {
 		return nil, fmt.Errorf("unable to setup hooks for handler: %s", err)
 	}
 
-	// Activate notifications for post-* hooks
-	config.NotifyCompleteUploads = slices.Contains(enabledHooks, HookPostFinish)
-	config.NotifyTerminatedUploads = slices.Contains(enabledHooks, HookPostTerminate)
-	config.NotifyUploadProgress = slices.Contains(enabledHooks, HookPostReceive)
-	config.NotifyCreatedUploads = slices.Contains(enabledHooks, HookPostCreate)
-
 	// Install callbacks for pre-* hooks
 	if slices.Contains(enabledHooks, HookPreCreate) {
 		config.PreUploadCreateCallback = func(event models.HookEvent) (models.HTTPResponse, models.FileInfoChanges, error) {
@@ -249,6 +330,31 @@ func NewHandlerWithHooks(config *config.Config, hookHandler HookHandler, enabled
 			return preFinishCallback(event, hookHandler)
 		}
 	}
+	if slices.Contains(enabledHooks, HookPreDownloadPresign) {
+		config.PreDownloadPresignCallback = func(event models.HookEvent) (bool, error) {
+			return preDownloadPresignCallback(event, hookHandler)
+		}
+	}
+	if slices.Contains(enabledHooks, HookPreGet) {
+		config.PreGetCallback = func(event models.HookEvent) (models.HTTPResponse, error) {
+			return preRequestCallback(HookPreGet, models.ErrDownloadRejectedByServer, event, hookHandler)
+		}
+	}
+	if slices.Contains(enabledHooks, HookPreHead) {
+		config.PreHeadCallback = func(event models.HookEvent) (models.HTTPResponse, error) {
+			return preRequestCallback(HookPreHead, models.ErrUploadAccessRejectedByServer, event, hookHandler)
+		}
+	}
+	if slices.Contains(enabledHooks, HookPrePatch) {
+		config.PrePatchCallback = func(event models.HookEvent) (models.HTTPResponse, error) {
+			return preRequestCallback(HookPrePatch, models.ErrUploadPatchRejectedByServer, event, hookHandler)
+		}
+	}
+	if slices.Contains(enabledHooks, HookPreTerminate) {
+		config.PreTerminateCallback = func(event models.HookEvent) (models.HTTPResponse, error) {
+			return preRequestCallback(HookPreTerminate, models.ErrTerminationRejectedByServer, event, hookHandler)
+		}
+	}
 
 	// Create handler
 	handler, err := handler.NewHandler(*config)
@@ -256,21 +362,40 @@ func NewHandlerWithHooks(config *config.Config, hookHandler HookHandler, enabled
 		return nil, err
 	}
 
-	// Listen for notifications for post-* hooks
-	go func() {
-		for {
-			select {
-			case event := <-handler.CompleteUploads:
-				invokeHookAsync(HookPostFinish, event, hookHandler)
-			case event := <-handler.TerminatedUploads:
-				invokeHookAsync(HookPostTerminate, event, hookHandler)
-			case event := <-handler.CreatedUploads:
-				invokeHookAsync(HookPostCreate, event, hookHandler)
-			case event := <-handler.UploadProgress:
-				go postReceiveCallback(event, hookHandler)
-			}
+	// Deliver the entries left over from a previous run, even if the hooks are not enabled anymore.
+	if outbox != nil {
+		outbox.Start(hookHandler)
+	}
+
+	// Listen for events for post-* hooks
+	var eventTypes []models.EventType
+	for eventType, hookType := range postHookTypes {
+		if slices.Contains(enabledHooks, hookType) {
+			eventTypes = append(eventTypes, eventType)
 		}
-	}()
+	}
+	if len(eventTypes) > 0 {
+		sub := handler.Events.Subscribe(models.SubscriptionOptions{
+			Name:       "hooks",
+			Types:      eventTypes,
+			BufferSize: EventBufferSize,
+			Policy:     models.DeliveryBlock,
+		})
+
+		go func() {
+			for event := range sub.Events() {
+				if event.Type == models.EventUploadProgress {
+					go postReceiveCallback(event.HookEvent, hookHandler)
+					continue
+				}
+				if outbox != nil {
+					outbox.Enqueue(postHookTypes[event.Type], event.HookEvent)
+					continue
+				}
+				invokeHookAsync(postHookTypes[event.Type], event.HookEvent, hookHandler)
+			}
+		}()
+	}
 
 	return handler, nil
 }
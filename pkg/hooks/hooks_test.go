@@ -0,0 +1,116 @@
+package hooks
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/config"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/filestore"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func sendRequest(t *testing.T, method string, url string, body string, header map[string]string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res, string(resBody)
+}
+
+func TestOptionalHooks(t *testing.T) {
+	assert := assert.New(t)
+	composer := models.NewStoreComposer()
+	filestore.New(t.TempDir()).UseIn(composer)
+
+	// The post-* hooks are invoked asynchronously.
+	var mutex sync.Mutex
+	rejected := map[HookType]bool{}
+	hookHandler := hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if rejected[req.Type] {
+			return HookResponse{
+				RejectUpload: true,
+				HTTPResponse: models.HTTPResponse{Body: "legal hold", Header: models.HTTPHeader{"X-Hook": string(req.Type)}},
+			}, nil
+		}
+		return HookResponse{HTTPResponse: models.HTTPResponse{Header: models.HTTPHeader{"X-Hook": string(req.Type)}}}, nil
+	})
+
+	cfg := config.Config{BasePath: "/files/", StoreComposer: composer}
+	handler, err := NewHandlerWithHooks(&cfg, hookHandler, append(AvailableHooks, OptionalHooks...))
+	assert.NoError(err)
+	server := httptest.NewServer(http.StripPrefix("/files/", handler))
+	defer server.Close()
+	files := server.URL + "/files/"
+
+	res, _ := sendRequest(t, "POST", files, "", map[string]string{"Upload-Length": "5"})
+	location := res.Header.Get("Location")
+	id := location[strings.LastIndex(location, "/")+1:]
+	patch := map[string]string{"Upload-Offset": "0", "Content-Type": "application/offset+octet-stream"}
+	res, _ = sendRequest(t, "PATCH", files+id, "hello", patch)
+	assert.Equal(http.StatusNoContent, res.StatusCode)
+
+	// The responses of the hooks are merged into the allowed requests.
+	res, _ = sendRequest(t, "HEAD", files+id, "", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal(string(HookPreHead), res.Header.Get("X-Hook"))
+	res, body := sendRequest(t, "GET", files+id, "", nil)
+	assert.Equal(http.StatusOK, res.StatusCode)
+	assert.Equal(string(HookPreGet), res.Header.Get("X-Hook"))
+	assert.Equal("hello", body)
+
+	mutex.Lock()
+	for _, typ := range []HookType{HookPreHead, HookPreGet, HookPrePatch, HookPreTerminate} {
+		rejected[typ] = true
+	}
+	mutex.Unlock()
+	tests := []struct {
+		method string
+		hook   HookType
+	}{
+		{"HEAD", HookPreHead},
+		{"GET", HookPreGet},
+		{"PATCH", HookPrePatch},
+		{"DELETE", HookPreTerminate},
+	}
+	for _, test := range tests {
+		res, body := sendRequest(t, test.method, files+id, "x", map[string]string{"Upload-Offset": "5", "Content-Type": "application/offset+octet-stream"})
+		assert.Equal(http.StatusForbidden, res.StatusCode, test.method)
+		assert.Equal(string(test.hook), res.Header.Get("X-Hook"), test.method)
+		if test.method != "HEAD" {
+			assert.Equal("legal hold", body, test.method)
+		}
+	}
+
+	// The rejected requests did not modify the upload.
+	mutex.Lock()
+	rejected[HookPreHead] = false
+	rejected[HookPreTerminate] = false
+	mutex.Unlock()
+	res, _ = sendRequest(t, "HEAD", files+id, "", nil)
+	assert.Equal("5", res.Header.Get("Upload-Offset"))
+	res, _ = sendRequest(t, "DELETE", files+id, "", nil)
+	assert.Equal(http.StatusNoContent, res.StatusCode)
+}
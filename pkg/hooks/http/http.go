@@ -2,50 +2,172 @@
 // POST request to the specified endpoint. The body is a JSON-formatted object including
 // the hook type, upload and request information.
 // By responding with a JSON object, the response from tusd can be controlled.
+//
+// Failed requests are retried, unless the endpoint responded with a 4XX status code.
+// If signing keys are configured, the requests are signed, so that the receiver can
+// verify that they were sent by tusd. See VerifySignature for details.
+//
+// Instead of the hook request, a CloudEvent can be sent by setting HttpHook.Format.
+// In binary mode, its attributes are sent in the ce-* headers.
 package http
 
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/sethgrid/pester"
 	"github.com/susufqx/dynamic-bucket-tusd/pkg/hooks"
 )
 
+// ErrCircuitOpen is returned if requests to an endpoint are not sent because the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker for hook endpoint is open")
+
 type HttpHook struct {
 	Endpoint       string
 	MaxRetries     int
 	Backoff        time.Duration
 	ForwardHeaders []string
 
-	client *pester.Client
+	// Format selects the serialization of the hook requests. Defaults to the
+	// HookRequest as JSON.
+	Format hooks.PayloadFormat
+
+	// MaxBackoff makes the delay between retries grow exponentially, starting at
+	// Backoff and doubling for every retry up to MaxBackoff. If zero, Backoff is used
+	// for every retry.
+	MaxBackoff time.Duration
+	// Timeout limits the duration of a single request. If zero, there is no timeout.
+	Timeout time.Duration
+
+	// Endpoints allows sending individual hook types to other endpoints or with other
+	// settings. Unset fields are taken from the HttpHook.
+	Endpoints map[hooks.HookType]HttpEndpoint
+
+	// SigningKeys are used to sign every request, see VerifySignature. To rotate a key,
+	// add the new key, update the receivers and remove the old key afterwards. If
+	// empty, requests are not signed.
+	SigningKeys []SigningKey
+
+	// CircuitBreakerThreshold is the number of consecutive failed requests to an
+	// endpoint, after which no requests are sent to it for CircuitBreakerCooldown.
+	// Requests fail with ErrCircuitOpen in the meantime, so that a dead endpoint does
+	// not delay uploads. Afterwards, a single request is let through and closes the
+	// circuit again if it succeeds. Requests fail if the endpoint cannot be reached or
+	// responds with a 5XX status code after all retries. If zero, there is no circuit
+	// breaker.
+	CircuitBreakerThreshold int
+	// CircuitBreakerCooldown defaults to 30s.
+	CircuitBreakerCooldown time.Duration
+
+	defaultTarget *target
+	targets       map[hooks.HookType]*target
+}
+
+// HttpEndpoint configures the endpoint for a hook type in HttpHook.Endpoints.
+type HttpEndpoint struct {
+	URL        string
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Timeout    time.Duration
+}
+
+// target is an endpoint with its client and circuit breaker.
+type target struct {
+	url     string
+	client  *pester.Client
+	breaker *circuitBreaker
 }
 
 func (h *HttpHook) Setup() error {
-	// Use linear backoff strategy with the user defined values.
+	h.defaultTarget = h.newTarget(HttpEndpoint{})
+	h.targets = make(map[hooks.HookType]*target, len(h.Endpoints))
+	for typ, endpoint := range h.Endpoints {
+		h.targets[typ] = h.newTarget(endpoint)
+	}
+
+	return nil
+}
+
+// newTarget creates a target for the endpoint, taking unset values from the HttpHook.
+func (h *HttpHook) newTarget(endpoint HttpEndpoint) *target {
+	if endpoint.URL == "" {
+		endpoint.URL = h.Endpoint
+	}
+	if endpoint.MaxRetries == 0 {
+		endpoint.MaxRetries = h.MaxRetries
+	}
+	if endpoint.Backoff == 0 {
+		endpoint.Backoff = h.Backoff
+	}
+	if endpoint.MaxBackoff == 0 {
+		endpoint.MaxBackoff = h.MaxBackoff
+	}
+	if endpoint.Timeout == 0 {
+		endpoint.Timeout = h.Timeout
+	}
+
+	cooldown := h.CircuitBreakerCooldown
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+
+	// Use linear or exponential backoff strategy with the user defined values.
 	client := pester.New()
 	client.KeepLog = true
-	client.MaxRetries = h.MaxRetries
-	client.Backoff = func(_ int) time.Duration {
-		return h.Backoff
+	client.MaxRetries = endpoint.MaxRetries
+	client.Timeout = endpoint.Timeout
+	if len(h.SigningKeys) > 0 {
+		client.Transport = &signingTransport{
+			keys: h.SigningKeys,
+			base: http.DefaultTransport,
+			now:  time.Now,
+		}
 	}
+	client.Backoff = func(retry int) time.Duration {
+		if endpoint.MaxBackoff <= 0 {
+			return endpoint.Backoff
+		}
 
-	h.client = client
+		delay := endpoint.Backoff
+		for i := 1; i < retry && delay < endpoint.MaxBackoff; i++ {
+			delay *= 2
+		}
+		if delay > endpoint.MaxBackoff {
+			delay = endpoint.MaxBackoff
+		}
+		return delay
+	}
 
-	return nil
+	return &target{
+		url:    endpoint.URL,
+		client: client,
+		breaker: &circuitBreaker{
+			threshold: h.CircuitBreakerThreshold,
+			cooldown:  cooldown,
+		},
+	}
 }
 
 func (h HttpHook) InvokeHook(hookReq hooks.HookRequest) (hookRes hooks.HookResponse, err error) {
-	jsonInfo, err := json.Marshal(hookReq)
+	t := h.defaultTarget
+	if typeTarget, ok := h.targets[hookReq.Type]; ok {
+		t = typeTarget
+	}
+
+	jsonInfo, header, err := h.payload(hookReq)
 	if err != nil {
 		return hookRes, err
 	}
 
-	httpReq, err := http.NewRequest("POST", h.Endpoint, bytes.NewBuffer(jsonInfo))
+	httpReq, err := http.NewRequest("POST", t.url, bytes.NewBuffer(jsonInfo))
 	if err != nil {
 		return hookRes, err
 	}
@@ -58,14 +180,24 @@ func (h HttpHook) InvokeHook(hookReq hooks.HookRequest) (hookRes hooks.HookRespo
 		}
 	}
 
-	httpReq.Header.Set("Content-Type", "application/json")
+	for k, vals := range header {
+		httpReq.Header[k] = vals
+	}
+
+	if !t.breaker.allow() {
+		return hookRes, fmt.Errorf("%w: %s", ErrCircuitOpen, t.url)
+	}
 
-	httpRes, err := h.client.Do(httpReq)
+	httpRes, err := t.client.Do(httpReq)
 	if err != nil {
+		t.breaker.record(false)
 		return hookRes, err
 	}
 	defer httpRes.Body.Close()
 
+	// 4XX responses show that the endpoint is working, even if the hook failed.
+	t.breaker.record(httpRes.StatusCode < http.StatusInternalServerError && httpRes.StatusCode != http.StatusTooManyRequests)
+
 	httpBody, err := io.ReadAll(httpRes.Body)
 	if err != nil {
 		return hookRes, err
@@ -82,3 +214,80 @@ func (h HttpHook) InvokeHook(hookReq hooks.HookRequest) (hookRes hooks.HookRespo
 
 	return hookRes, nil
 }
+
+// payload serializes the hook request according to the Format and returns the
+// headers describing it.
+func (h HttpHook) payload(hookReq hooks.HookRequest) (body []byte, header http.Header, err error) {
+	header = make(http.Header)
+
+	switch h.Format {
+	case hooks.PayloadCloudEventsStructured:
+		body, err = json.Marshal(hooks.NewCloudEvent(hookReq))
+		header.Set("Content-Type", "application/cloudevents+json")
+	case hooks.PayloadCloudEventsBinary:
+		event := hooks.NewCloudEvent(hookReq)
+		body, err = json.Marshal(event.Data)
+		for name, value := range event.Attributes() {
+			header.Set("ce-"+name, value)
+		}
+		header.Set("Content-Type", event.DataContentType)
+	default:
+		body, err = json.Marshal(hookReq)
+		header.Set("Content-Type", "application/json")
+	}
+
+	return body, header, err
+}
+
+// circuitBreaker stops requests to an endpoint after too many consecutive failures.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mutex     sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow returns whether a request may be sent. While the circuit is open, only a
+// single request is allowed after the cooldown.
+func (b *circuitBreaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return false
+	}
+
+	b.probing = true
+	return true
+}
+
+// record updates the state after a request has been sent.
+func (b *circuitBreaker) record(success bool) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.probing = false
+	if success {
+		b.failures = 0
+		return
+	}
+
+	b.failures += 1
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,257 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/hooks"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// receivedRequest is a hook request recorded by newTestEndpoint.
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newTestEndpoint starts a server, which records the requests and responds using
+// the given function.
+func newTestEndpoint(t *testing.T, respond func(n int) (int, string)) (url string, received func() []receivedRequest) {
+	var mutex sync.Mutex
+	var requests []receivedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		requests = append(requests, receivedRequest{header: r.Header.Clone(), body: body})
+		n := len(requests)
+		mutex.Unlock()
+
+		status, resBody := respond(n)
+		w.WriteHeader(status)
+		w.Write([]byte(resBody))
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL, func() []receivedRequest {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]receivedRequest(nil), requests...)
+	}
+}
+
+var testHookRequest = hooks.HookRequest{
+	Type:  hooks.HookPostFinish,
+	Event: models.HookEvent{Upload: models.FileInfo{ID: "upload", Size: 100, Offset: 100}},
+}
+
+func TestHttpHookFormats(t *testing.T) {
+	assert := assert.New(t)
+	url, received := newTestEndpoint(t, func(int) (int, string) {
+		return http.StatusOK, `{}`
+	})
+
+	for _, format := range []hooks.PayloadFormat{hooks.PayloadHookRequest, hooks.PayloadCloudEventsStructured, hooks.PayloadCloudEventsBinary} {
+		h := &HttpHook{Endpoint: url, Format: format}
+		assert.NoError(h.Setup())
+		_, err := h.InvokeHook(testHookRequest)
+		assert.NoError(err)
+	}
+
+	requests := received()
+	if !assert.Len(requests, 3) {
+		return
+	}
+
+	var hookReq hooks.HookRequest
+	assert.Equal("application/json", requests[0].header.Get("Content-Type"))
+	assert.NoError(json.Unmarshal(requests[0].body, &hookReq))
+	assert.Equal("upload", hookReq.Event.Upload.ID)
+
+	var event hooks.CloudEvent
+	assert.Equal("application/cloudevents+json", requests[1].header.Get("Content-Type"))
+	assert.NoError(json.Unmarshal(requests[1].body, &event))
+	assert.Equal(hooks.NewCloudEvent(testHookRequest).ID, event.ID)
+
+	// In binary mode, the attributes are sent as headers.
+	event = hooks.NewCloudEvent(testHookRequest)
+	assert.Equal("application/json", requests[2].header.Get("Content-Type"))
+	assert.Equal(event.ID, requests[2].header.Get("ce-id"))
+	assert.Equal(event.Type, requests[2].header.Get("ce-type"))
+	assert.Equal(event.Subject, requests[2].header.Get("ce-subject"))
+	var data models.HookEvent
+	assert.NoError(json.Unmarshal(requests[2].body, &data))
+	assert.Equal("upload", data.Upload.ID)
+}
+
+func TestHttpHookSignature(t *testing.T) {
+	assert := assert.New(t)
+	url, received := newTestEndpoint(t, func(int) (int, string) {
+		return http.StatusOK, `{}`
+	})
+	oldKey := SigningKey{ID: "old", Secret: []byte("old secret")}
+	newKey := SigningKey{ID: "new", Secret: []byte("new secret")}
+
+	h := &HttpHook{
+		Endpoint:    url,
+		Format:      hooks.PayloadCloudEventsBinary,
+		SigningKeys: []SigningKey{oldKey, newKey},
+	}
+	assert.NoError(h.Setup())
+	_, err := h.InvokeHook(testHookRequest)
+	assert.NoError(err)
+
+	requests := received()
+	if !assert.Len(requests, 1) {
+		return
+	}
+	req := requests[0]
+
+	// The request is signed with every key.
+	assert.NoError(VerifySignature(oldKey, req.header, req.body, time.Minute))
+	assert.NoError(VerifySignature(newKey, req.header, req.body, time.Minute))
+	assert.ErrorIs(VerifySignature(SigningKey{ID: "new", Secret: []byte("wrong")}, req.header, req.body, time.Minute), ErrSignatureInvalid)
+	assert.ErrorIs(VerifySignature(SigningKey{ID: "other", Secret: newKey.Secret}, req.header, req.body, time.Minute), ErrSignatureInvalid)
+
+	// The body and the CloudEvent attributes are covered by the signature.
+	assert.ErrorIs(VerifySignature(newKey, req.header, append(req.body, ' '), time.Minute), ErrSignatureInvalid)
+	for _, name := range SignedHeaders {
+		header := req.header.Clone()
+		header.Set(name, "tampered")
+		assert.ErrorIs(VerifySignature(newKey, header, req.body, time.Minute), ErrSignatureInvalid, name)
+	}
+
+	header := req.header.Clone()
+	header.Del(TimestampHeader)
+	assert.ErrorIs(VerifySignature(newKey, header, req.body, time.Minute), ErrSignatureMissing)
+
+	// Old signatures are rejected, unless the age is not checked.
+	timestamp := time.Now().Add(-time.Hour).Unix()
+	header = req.header.Clone()
+	header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	header.Set(SignatureHeader, signatureHeader([]SigningKey{newKey}, timestamp, header, req.body))
+	assert.ErrorIs(VerifySignature(newKey, header, req.body, time.Minute), ErrSignatureExpired)
+	assert.NoError(VerifySignature(newKey, header, req.body, 0))
+}
+
+func TestHttpHookSignatureOnRetry(t *testing.T) {
+	assert := assert.New(t)
+	url, received := newTestEndpoint(t, func(n int) (int, string) {
+		if n == 1 {
+			return http.StatusInternalServerError, ""
+		}
+		return http.StatusOK, `{}`
+	})
+	key := SigningKey{ID: "key", Secret: []byte("secret")}
+
+	h := &HttpHook{
+		Endpoint:    url,
+		MaxRetries:  2,
+		Backoff:     time.Millisecond,
+		SigningKeys: []SigningKey{key},
+	}
+	assert.NoError(h.Setup())
+	// Every attempt is signed a minute after the previous one.
+	now := time.Now()
+	h.defaultTarget.client.Transport.(*signingTransport).now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	_, err := h.InvokeHook(testHookRequest)
+	assert.NoError(err)
+
+	requests := received()
+	if !assert.Len(requests, 2) {
+		return
+	}
+	assert.NotEqual(requests[0].header.Get(TimestampHeader), requests[1].header.Get(TimestampHeader))
+	for _, req := range requests {
+		assert.NoError(VerifySignature(key, req.header, req.body, 0))
+	}
+}
+
+func TestHttpHookEndpoints(t *testing.T) {
+	assert := assert.New(t)
+	defaultURL, defaultReceived := newTestEndpoint(t, func(int) (int, string) {
+		return http.StatusOK, `{"RejectUpload":true}`
+	})
+	finishURL, finishReceived := newTestEndpoint(t, func(int) (int, string) {
+		return http.StatusOK, `{"StopUpload":true}`
+	})
+
+	h := &HttpHook{
+		Endpoint: defaultURL,
+		Endpoints: map[hooks.HookType]HttpEndpoint{
+			hooks.HookPostFinish: {URL: finishURL, Timeout: time.Second},
+		},
+	}
+	assert.NoError(h.Setup())
+
+	res, err := h.InvokeHook(hooks.HookRequest{Type: hooks.HookPreCreate})
+	assert.NoError(err)
+	assert.True(res.RejectUpload)
+	res, err = h.InvokeHook(testHookRequest)
+	assert.NoError(err)
+	assert.True(res.StopUpload)
+
+	assert.Len(defaultReceived(), 1)
+	assert.Len(finishReceived(), 1)
+}
+
+func TestHttpHookCircuitBreaker(t *testing.T) {
+	assert := assert.New(t)
+	var mutex sync.Mutex
+	status := http.StatusInternalServerError
+	url, received := newTestEndpoint(t, func(int) (int, string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return status, `{}`
+	})
+	setStatus := func(s int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		status = s
+	}
+
+	h := &HttpHook{
+		Endpoint:                url,
+		MaxRetries:              2,
+		Backoff:                 time.Millisecond,
+		CircuitBreakerThreshold: 2,
+		CircuitBreakerCooldown:  100 * time.Millisecond,
+	}
+	assert.NoError(h.Setup())
+
+	// The circuit opens after two hooks failed with both attempts.
+	_, err := h.InvokeHook(testHookRequest)
+	assert.Error(err)
+	_, err = h.InvokeHook(testHookRequest)
+	assert.Error(err)
+	assert.Len(received(), 4)
+	_, err = h.InvokeHook(testHookRequest)
+	assert.ErrorIs(err, ErrCircuitOpen)
+	assert.Len(received(), 4)
+
+	// After the cooldown, a failing probe opens the circuit again.
+	time.Sleep(150 * time.Millisecond)
+	_, err = h.InvokeHook(testHookRequest)
+	assert.NotErrorIs(err, ErrCircuitOpen)
+	_, err = h.InvokeHook(testHookRequest)
+	assert.ErrorIs(err, ErrCircuitOpen)
+
+	// A 4XX response closes the circuit, since the endpoint is reachable.
+	time.Sleep(150 * time.Millisecond)
+	setStatus(http.StatusForbidden)
+	_, err = h.InvokeHook(testHookRequest)
+	assert.NotErrorIs(err, ErrCircuitOpen)
+	setStatus(http.StatusOK)
+	_, err = h.InvokeHook(testHookRequest)
+	assert.NoError(err)
+}
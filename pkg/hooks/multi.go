@@ -0,0 +1,265 @@
+package hooks
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+// ErrHookTimeout is returned by MultiHook if a handler did not respond within its
+// timeout.
+var ErrHookTimeout = errors.New("hooks: hook handler timed out")
+
+// ErrorPolicy controls how MultiHook treats errors from a handler.
+type ErrorPolicy int
+
+const (
+	// ErrorPolicyFail makes the hook fail with the handler's error. This is the default.
+	ErrorPolicyFail ErrorPolicy = iota
+	// ErrorPolicyIgnore logs the error and continues as if the handler had returned an
+	// empty response.
+	ErrorPolicyIgnore
+)
+
+// MultiHookHandler is a handler used by MultiHook.
+type MultiHookHandler struct {
+	// Name identifies the handler in errors and logs. Defaults to its position.
+	Name    string
+	Handler HookHandler
+	// Types are the hooks, for which the handler is invoked. If empty, it is invoked
+	// for all hooks in AvailableHooks. Hooks from OptionalHooks must be listed
+	// explicitly.
+	Types []HookType
+	// Timeout limits the time for a single invocation. The invocation is not
+	// cancelled, but its response is ignored once the timeout has passed and
+	// ErrHookTimeout is treated according to the ErrorPolicy. If zero, there is no
+	// timeout.
+	Timeout time.Duration
+	// ErrorPolicy controls how errors from the handler are treated.
+	ErrorPolicy ErrorPolicy
+}
+
+// MultiHook is a HookHandler, which invokes multiple handlers, e.g. a plugin for
+// validating uploads in pre-create and an HTTP hook for post-finish:
+//
+//	hookHandler := &hooks.MultiHook{
+//		Handlers: []hooks.MultiHookHandler{
+//			{Name: "validation", Handler: pluginHook, Types: []hooks.HookType{hooks.HookPreCreate}},
+//			{Name: "billing", Handler: httpHook, Types: []hooks.HookType{hooks.HookPostFinish}},
+//		},
+//	}
+//	handler, err = hooks.NewHandlerWithHooks(&config, hookHandler, hookHandler.HookTypes())
+//
+// The pre-* hooks are chained: the handlers are invoked one after another in the
+// given order and the responses are merged as follows:
+//
+//   - HTTPResponse values are merged using HTTPResponse.MergeWith, so later
+//     handlers overwrite the status code, body and headers set by earlier ones.
+//   - RejectUpload and RejectPresign from any handler reject the upload or the
+//     presigned URL. No further handlers are invoked afterwards.
+//   - ChangeFileInfo changes are merged on a per-property basis in order. The
+//     changes are also applied to the upload passed to the following handlers, so
+//     that they can build upon them.
+//
+// The post-* hooks are fanned out: all handlers are invoked concurrently and the
+// responses are merged in the given order once all have completed. StopUpload from
+// any handler stops the upload.
+//
+// If a handler fails and its ErrorPolicy is ErrorPolicyFail, the hook fails. For
+// the pre-* hooks, the following handlers are not invoked in this case.
+type MultiHook struct {
+	Handlers []MultiHookHandler
+}
+
+// HookTypes returns the hooks, for which at least one handler is invoked. It can be
+// passed as enabledHooks to NewHandlerWithHooks.
+func (m *MultiHook) HookTypes() []HookType {
+	all := make([]HookType, 0, len(AvailableHooks)+len(OptionalHooks))
+	all = append(all, AvailableHooks...)
+	all = append(all, OptionalHooks...)
+
+	types := make([]HookType, 0, len(all))
+	for _, typ := range all {
+		if len(m.handlersFor(typ)) > 0 {
+			types = append(types, typ)
+		}
+	}
+
+	return types
+}
+
+func (m *MultiHook) Setup() error {
+	for i, h := range m.Handlers {
+		if err := h.Handler.Setup(); err != nil {
+			return fmt.Errorf("hooks: unable to setup handler %s: %w", handlerName(i, h), err)
+		}
+	}
+
+	return nil
+}
+
+func (m *MultiHook) InvokeHook(req HookRequest) (HookResponse, error) {
+	switch req.Type {
+	case HookPreCreate, HookPreFinish, HookPreDownloadPresign, HookPreGet, HookPreHead, HookPrePatch, HookPreTerminate:
+		return m.chain(req)
+	default:
+		return m.fanOut(req)
+	}
+}
+
+// chain invokes the handlers one after another.
+func (m *MultiHook) chain(req HookRequest) (HookResponse, error) {
+	var res HookResponse
+	for _, i := range m.handlersFor(req.Type) {
+		handlerRes, err := m.invoke(i, req)
+		if err != nil {
+			return HookResponse{}, err
+		}
+
+		res = mergeHookResponses(res, handlerRes)
+		if res.RejectUpload || res.RejectPresign {
+			break
+		}
+
+		// Let the following handlers see the changes.
+		changes := handlerRes.ChangeFileInfo
+		upload := &req.Event.Upload
+		if changes.ID != "" {
+			upload.ID = changes.ID
+		}
+		if changes.MetaData != nil {
+			upload.MetaData = changes.MetaData
+		}
+		if changes.Storage != nil {
+			upload.Storage = changes.Storage
+		}
+		if !changes.ExpiresAt.IsZero() {
+			upload.ExpiresAt = changes.ExpiresAt
+		}
+	}
+
+	return res, nil
+}
+
+// fanOut invokes the handlers concurrently.
+func (m *MultiHook) fanOut(req HookRequest) (HookResponse, error) {
+	indexes := m.handlersFor(req.Type)
+	responses := make([]HookResponse, len(indexes))
+	errs := make([]error, len(indexes))
+
+	var wg sync.WaitGroup
+	for n, i := range indexes {
+		wg.Add(1)
+		go func(n, i int) {
+			defer wg.Done()
+			responses[n], errs[n] = m.invoke(i, req)
+		}(n, i)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return HookResponse{}, err
+	}
+
+	var res HookResponse
+	for _, handlerRes := range responses {
+		res = mergeHookResponses(res, handlerRes)
+	}
+
+	return res, nil
+}
+
+// invoke calls the handler at the given index, applying its timeout and error policy.
+func (m *MultiHook) invoke(i int, req HookRequest) (HookResponse, error) {
+	h := m.Handlers[i]
+	name := handlerName(i, h)
+
+	res, err := invokeWithTimeout(h.Handler, req, h.Timeout)
+	if err == nil {
+		return res, nil
+	}
+
+	if h.ErrorPolicy == ErrorPolicyIgnore {
+		slog.Warn("HookHandlerErrorIgnored", "type", req.Type, "id", req.Event.Upload.ID, "handler", name, "error", err.Error())
+		return HookResponse{}, nil
+	}
+
+	return HookResponse{}, fmt.Errorf("handler %s: %w", name, err)
+}
+
+// handlersFor returns the indexes of the handlers invoked for the hook type.
+func (m *MultiHook) handlersFor(typ HookType) []int {
+	indexes := make([]int, 0, len(m.Handlers))
+	for i, h := range m.Handlers {
+		if slices.Contains(h.Types, typ) || (len(h.Types) == 0 && slices.Contains(AvailableHooks, typ)) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes
+}
+
+func handlerName(i int, h MultiHookHandler) string {
+	if h.Name != "" {
+		return h.Name
+	}
+
+	return "#" + strconv.Itoa(i)
+}
+
+func invokeWithTimeout(hookHandler HookHandler, req HookRequest, timeout time.Duration) (HookResponse, error) {
+	if timeout <= 0 {
+		return hookHandler.InvokeHook(req)
+	}
+
+	type result struct {
+		res HookResponse
+		err error
+	}
+	// The channel is buffered, so that the goroutine can finish after the timeout.
+	resultChan := make(chan result, 1)
+	go func() {
+		res, err := hookHandler.InvokeHook(req)
+		resultChan <- result{res, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-resultChan:
+		return r.res, r.err
+	case <-timer.C:
+		return HookResponse{}, ErrHookTimeout
+	}
+}
+
+// mergeHookResponses merges the response res2 into res1, with the values from res2
+// taking precedence.
+func mergeHookResponses(res1, res2 HookResponse) HookResponse {
+	res := res1
+	res.HTTPResponse = res1.HTTPResponse.MergeWith(res2.HTTPResponse)
+	res.RejectUpload = res1.RejectUpload || res2.RejectUpload
+	res.StopUpload = res1.StopUpload || res2.StopUpload
+	res.RejectPresign = res1.RejectPresign || res2.RejectPresign
+
+	if res2.ChangeFileInfo.ID != "" {
+		res.ChangeFileInfo.ID = res2.ChangeFileInfo.ID
+	}
+	if res2.ChangeFileInfo.MetaData != nil {
+		res.ChangeFileInfo.MetaData = res2.ChangeFileInfo.MetaData
+	}
+	if res2.ChangeFileInfo.Storage != nil {
+		res.ChangeFileInfo.Storage = res2.ChangeFileInfo.Storage
+	}
+	if !res2.ChangeFileInfo.ExpiresAt.IsZero() {
+		res.ChangeFileInfo.ExpiresAt = res2.ChangeFileInfo.ExpiresAt
+	}
+
+	return res
+}
@@ -0,0 +1,135 @@
+package hooks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// hookHandlerFunc is a HookHandler, which calls the function for every hook.
+type hookHandlerFunc func(req HookRequest) (HookResponse, error)
+
+func (f hookHandlerFunc) Setup() error {
+	return nil
+}
+
+func (f hookHandlerFunc) InvokeHook(req HookRequest) (HookResponse, error) {
+	return f(req)
+}
+
+func TestMultiHookTypes(t *testing.T) {
+	m := &MultiHook{Handlers: []MultiHookHandler{
+		{Handler: hookHandlerFunc(nil), Types: []HookType{HookPreCreate, HookPreGet}},
+		{Handler: hookHandlerFunc(nil), Types: []HookType{HookPostFinish}},
+	}}
+	assert.Equal(t, []HookType{HookPreCreate, HookPostFinish, HookPreGet}, m.HookTypes())
+
+	// Without types, a handler is invoked for all hooks except the optional ones.
+	m = &MultiHook{Handlers: []MultiHookHandler{{Handler: hookHandlerFunc(nil)}}}
+	assert.Equal(t, AvailableHooks, m.HookTypes())
+}
+
+func TestMultiHookChain(t *testing.T) {
+	assert := assert.New(t)
+	var invoked []string
+	m := &MultiHook{Handlers: []MultiHookHandler{
+		{Name: "a", Types: []HookType{HookPreCreate}, Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			invoked = append(invoked, "a")
+			return HookResponse{
+				ChangeFileInfo: models.FileInfoChanges{ID: "changed", MetaData: models.MetaData{"a": "1"}},
+				HTTPResponse:   models.HTTPResponse{StatusCode: 201, Header: models.HTTPHeader{"A": "1"}},
+			}, nil
+		})},
+		{Name: "b", Types: []HookType{HookPreCreate}, Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			invoked = append(invoked, "b")
+			// The changes from the previous handler are visible.
+			metaData := models.MetaData{"b": "2"}
+			for key, value := range req.Event.Upload.MetaData {
+				metaData[key] = value
+			}
+			return HookResponse{
+				ChangeFileInfo: models.FileInfoChanges{MetaData: metaData},
+				HTTPResponse:   models.HTTPResponse{Header: models.HTTPHeader{"B": req.Event.Upload.ID}},
+			}, nil
+		})},
+		{Name: "reject", Types: []HookType{HookPreCreate}, Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			invoked = append(invoked, "reject")
+			return HookResponse{RejectUpload: true}, nil
+		})},
+		{Name: "skipped", Types: []HookType{HookPreCreate}, Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			invoked = append(invoked, "skipped")
+			return HookResponse{}, nil
+		})},
+	}}
+
+	res, err := m.InvokeHook(HookRequest{Type: HookPreCreate})
+	assert.NoError(err)
+	assert.Equal([]string{"a", "b", "reject"}, invoked)
+	assert.True(res.RejectUpload)
+	assert.Equal("changed", res.ChangeFileInfo.ID)
+	assert.Equal(models.MetaData{"a": "1", "b": "2"}, res.ChangeFileInfo.MetaData)
+	assert.Equal(201, res.HTTPResponse.StatusCode)
+	assert.Equal(models.HTTPHeader{"A": "1", "B": "changed"}, res.HTTPResponse.Header)
+}
+
+func TestMultiHookChainError(t *testing.T) {
+	assert := assert.New(t)
+	invoked := false
+	m := &MultiHook{Handlers: []MultiHookHandler{
+		{Name: "ignored", Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			return HookResponse{}, errors.New("ignored")
+		}), ErrorPolicy: ErrorPolicyIgnore},
+		{Name: "failing", Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			return HookResponse{}, errors.New("boom")
+		})},
+		{Name: "skipped", Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			invoked = true
+			return HookResponse{}, nil
+		})},
+	}}
+
+	_, err := m.InvokeHook(HookRequest{Type: HookPreFinish})
+	assert.EqualError(err, "handler failing: boom")
+	assert.False(invoked)
+}
+
+func TestMultiHookFanOut(t *testing.T) {
+	assert := assert.New(t)
+	m := &MultiHook{Handlers: []MultiHookHandler{
+		{Name: "slow", Timeout: 50 * time.Millisecond, ErrorPolicy: ErrorPolicyIgnore, Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			time.Sleep(time.Second)
+			return HookResponse{}, nil
+		})},
+		{Name: "stop", Types: []HookType{HookPostReceive}, Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			return HookResponse{StopUpload: true}, nil
+		})},
+		{Name: "failing", Types: []HookType{HookPostFinish}, Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			return HookResponse{}, errors.New("boom")
+		})},
+	}}
+
+	// The handlers run concurrently and the slow one is cut off by its timeout.
+	start := time.Now()
+	res, err := m.InvokeHook(HookRequest{Type: HookPostReceive})
+	assert.NoError(err)
+	assert.True(res.StopUpload)
+	assert.Less(time.Since(start), 500*time.Millisecond)
+
+	_, err = m.InvokeHook(HookRequest{Type: HookPostFinish})
+	assert.EqualError(err, "handler failing: boom")
+}
+
+func TestMultiHookTimeout(t *testing.T) {
+	m := &MultiHook{Handlers: []MultiHookHandler{
+		{Timeout: 50 * time.Millisecond, Handler: hookHandlerFunc(func(req HookRequest) (HookResponse, error) {
+			time.Sleep(time.Second)
+			return HookResponse{}, nil
+		})},
+	}}
+
+	_, err := m.InvokeHook(HookRequest{Type: HookPostCreate})
+	assert.ErrorIs(t, err, ErrHookTimeout)
+}
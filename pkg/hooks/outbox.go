@@ -0,0 +1,378 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/susufqx/dynamic-bucket-tusd/internal/semaphore"
+	"github.com/susufqx/dynamic-bucket-tusd/internal/uid"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+	"golang.org/x/exp/slog"
+)
+
+// OutboxState is the state of an entry in the outbox.
+type OutboxState string
+
+const (
+	// OutboxPending entries are waiting for their (next) delivery attempt.
+	OutboxPending OutboxState = "pending"
+	// OutboxDead entries have failed too often and are kept in the dead-letter
+	// queue until they are replayed or discarded.
+	OutboxDead OutboxState = "dead"
+)
+
+// OutboxEntry is a hook request stored in the outbox.
+type OutboxEntry struct {
+	// ID identifies the entry. IDs are ordered by the time the entry was created.
+	ID string `json:"id"`
+	// Request is the hook request to be delivered. Its event's Context is not stored.
+	Request HookRequest `json:"request"`
+	State   OutboxState `json:"state"`
+	// Attempts is the number of failed delivery attempts.
+	Attempts int `json:"attempts"`
+	// NextAttempt is the earliest time of the next delivery attempt.
+	NextAttempt time.Time `json:"nextAttempt"`
+	// LastError is the error of the last failed delivery attempt.
+	LastError string    `json:"lastError,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OutboxStore persists the entries of an Outbox. See FileOutboxStore and
+// SQLOutboxStore for implementations.
+type OutboxStore interface {
+	// Put stores the entry, replacing an existing entry with the same ID. Once Put
+	// returns, the entry must survive a restart of the process.
+	Put(ctx context.Context, entry OutboxEntry) error
+	// Delete removes the entry with the given ID. Deleting an unknown entry is not
+	// an error.
+	Delete(ctx context.Context, id string) error
+	// List returns all entries in the given state, ordered by their IDs.
+	List(ctx context.Context, state OutboxState) ([]OutboxEntry, error)
+}
+
+// Outbox delivers the post-create, post-finish and post-terminate hooks reliably.
+// Every hook request is stored in the OutboxStore before it is delivered and only
+// removed once the hook handler succeeded. Failed deliveries are retried with
+// exponential backoff, also after a restart of the process. Entries, which have
+// failed MaxAttempts times, are moved to the dead-letter queue, from where they
+// can be inspected using DeadLetters and retried using Replay. Operators can do so
+// over HTTP using NewOutboxAdminHandler.
+//
+// Since an entry may be delivered again if the process stops between the delivery
+// and its removal, hooks are delivered at least once. Hook handlers, which must not
+// process an event twice, can use the upload ID and hook type to detect duplicates.
+//
+// The fields must not be modified after Start has been called.
+type Outbox struct {
+	Store OutboxStore
+	// MaxAttempts is the number of failed attempts, after which an entry is moved
+	// to the dead-letter queue. Defaults to 10.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It is doubled for every
+	// further attempt. Defaults to 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff limits the delay between two attempts. Defaults to 10min.
+	MaxBackoff time.Duration
+	// PollInterval is the interval, in which the store is checked for entries
+	// added or replayed by other processes. Defaults to 10s.
+	PollInterval time.Duration
+	// Concurrency is the number of hooks delivered at the same time. Defaults to 10.
+	Concurrency int
+
+	hookHandler HookHandler
+	wake        chan struct{}
+	cancel      context.CancelFunc
+	done        chan struct{}
+}
+
+// NewOutbox creates an outbox storing the hook requests in the given store. The
+// other fields are set to their defaults by Start.
+func NewOutbox(store OutboxStore) *Outbox {
+	return &Outbox{
+		Store: store,
+	}
+}
+
+var MetricsHookDeadLettersTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "tusd_hook_dead_letters_total",
+		Help: "Total number of hook requests moved to the dead-letter queue of the outbox per hook type.",
+	},
+	[]string{"hooktype"},
+)
+
+// Start begins delivering the stored entries using the hook handler in the
+// background, including the ones left over from a previous run. It is called by
+// NewHandlerWithOutbox.
+func (o *Outbox) Start(hookHandler HookHandler) {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 10
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 10 * time.Minute
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = 10 * time.Second
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 10
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	o.hookHandler = hookHandler
+	o.wake = make(chan struct{}, 1)
+	o.cancel = cancel
+	o.done = make(chan struct{})
+
+	go o.run(ctx)
+}
+
+// Stop ends the delivery and waits for running hooks to complete. Undelivered
+// entries remain in the store and are delivered after the next Start.
+func (o *Outbox) Stop() {
+	if o.cancel == nil {
+		return
+	}
+
+	o.cancel()
+	<-o.done
+}
+
+// Enqueue stores a hook request and triggers its delivery. If the request cannot
+// be stored, it is delivered once without retries, so that it is not lost right away.
+func (o *Outbox) Enqueue(typ HookType, event models.HookEvent) {
+	now := time.Now()
+	entry := OutboxEntry{
+		ID: fmt.Sprintf("%020d-%s", now.UnixNano(), uid.Uid()),
+		Request: HookRequest{
+			Type:  typ,
+			Event: event,
+		},
+		State:       OutboxPending,
+		NextAttempt: now,
+		CreatedAt:   now,
+	}
+
+	if err := o.Store.Put(context.Background(), entry); err != nil {
+		slog.Error("HookOutboxError", "type", typ, "id", event.Upload.ID, "error", err.Error())
+		invokeHookAsync(typ, event, o.hookHandler)
+		return
+	}
+
+	o.notify()
+}
+
+// DeadLetters returns the entries in the dead-letter queue.
+func (o *Outbox) DeadLetters(ctx context.Context) ([]OutboxEntry, error) {
+	return o.Store.List(ctx, OutboxDead)
+}
+
+// Replay moves the entries with the given IDs from the dead-letter queue back to
+// the outbox, so that they are delivered again with a fresh number of attempts.
+// If no IDs are given, all dead letters are replayed. It returns the number of
+// replayed entries.
+func (o *Outbox) Replay(ctx context.Context, ids ...string) (int, error) {
+	entries, err := o.selectDeadLetters(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, entry := range entries {
+		entry.State = OutboxPending
+		entry.Attempts = 0
+		entry.NextAttempt = time.Now()
+		if err := o.Store.Put(ctx, entry); err != nil {
+			return i, err
+		}
+	}
+
+	if len(entries) > 0 {
+		o.notify()
+	}
+
+	return len(entries), nil
+}
+
+// Discard removes the entries with the given IDs from the dead-letter queue
+// without delivering them. If no IDs are given, all dead letters are discarded.
+// It returns the number of discarded entries.
+func (o *Outbox) Discard(ctx context.Context, ids ...string) (int, error) {
+	entries, err := o.selectDeadLetters(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, entry := range entries {
+		if err := o.Store.Delete(ctx, entry.ID); err != nil {
+			return i, err
+		}
+	}
+
+	return len(entries), nil
+}
+
+func (o *Outbox) selectDeadLetters(ctx context.Context, ids []string) ([]OutboxEntry, error) {
+	entries, err := o.Store.List(ctx, OutboxDead)
+	if err != nil || len(ids) == 0 {
+		return entries, err
+	}
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	selected := entries[:0]
+	for _, entry := range entries {
+		if wanted[entry.ID] {
+			selected = append(selected, entry)
+		}
+	}
+
+	return selected, nil
+}
+
+// notify wakes up the delivery loop, if it has been started.
+func (o *Outbox) notify() {
+	if o.wake == nil {
+		return
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (o *Outbox) run(ctx context.Context) {
+	defer close(o.done)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-timer.C:
+		}
+
+		wait := o.PollInterval
+		next, err := o.deliverDue(ctx)
+		if err != nil {
+			slog.Error("HookOutboxError", "error", err.Error())
+		} else if !next.IsZero() && time.Until(next) < wait {
+			wait = time.Until(next)
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+	}
+}
+
+// deliverDue delivers all pending entries, whose next attempt is due, and returns
+// the time of the next attempt for the remaining ones.
+func (o *Outbox) deliverDue(ctx context.Context) (next time.Time, err error) {
+	entries, err := o.Store.List(ctx, OutboxPending)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var wg sync.WaitGroup
+	var nextLock sync.Mutex
+	sem := semaphore.New(o.Concurrency)
+	now := time.Now()
+
+	for _, entry := range entries {
+		if entry.NextAttempt.After(now) {
+			nextLock.Lock()
+			if next.IsZero() || entry.NextAttempt.Before(next) {
+				next = entry.NextAttempt
+			}
+			nextLock.Unlock()
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		sem.Acquire()
+		wg.Add(1)
+		go func(entry OutboxEntry) {
+			defer sem.Release()
+			defer wg.Done()
+
+			retryAt := o.deliver(entry)
+
+			nextLock.Lock()
+			if !retryAt.IsZero() && (next.IsZero() || retryAt.Before(next)) {
+				next = retryAt
+			}
+			nextLock.Unlock()
+		}(entry)
+	}
+
+	wg.Wait()
+
+	return next, nil
+}
+
+// deliver invokes the hook for the entry and updates the store accordingly. It
+// returns the time of the next attempt, if the entry remains pending.
+func (o *Outbox) deliver(entry OutboxEntry) (retryAt time.Time) {
+	// The stored entries have no context, but hook handlers may rely on its presence.
+	event := entry.Request.Event
+	event.Context = context.Background()
+
+	ctx := context.Background()
+	ok, _, err := invokeHookSync(entry.Request.Type, event, o.hookHandler)
+	if ok {
+		if err := o.Store.Delete(ctx, entry.ID); err != nil {
+			slog.Error("HookOutboxError", "type", entry.Request.Type, "id", event.Upload.ID, "entry", entry.ID, "error", err.Error())
+		}
+		return time.Time{}
+	}
+
+	entry.Attempts += 1
+	entry.LastError = err.Error()
+	if entry.Attempts >= o.MaxAttempts {
+		entry.State = OutboxDead
+		slog.Error("HookDeadLettered", "type", entry.Request.Type, "id", event.Upload.ID, "entry", entry.ID, "attempts", entry.Attempts, "error", entry.LastError)
+		MetricsHookDeadLettersTotal.WithLabelValues(string(entry.Request.Type)).Add(1)
+	} else {
+		entry.NextAttempt = time.Now().Add(o.backoff(entry.Attempts))
+		retryAt = entry.NextAttempt
+	}
+
+	if err := o.Store.Put(ctx, entry); err != nil {
+		slog.Error("HookOutboxError", "type", entry.Request.Type, "id", event.Upload.ID, "entry", entry.ID, "error", err.Error())
+	}
+
+	return retryAt
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.InitialBackoff
+	for i := 1; i < attempts && delay < o.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > o.MaxBackoff {
+		delay = o.MaxBackoff
+	}
+
+	return delay
+}
@@ -0,0 +1,160 @@
+package hooks
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/bmizerany/pat"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/handler"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+	"golang.org/x/exp/slog"
+)
+
+// outboxAdminSelection is the request body for replaying and discarding dead letters.
+type outboxAdminSelection struct {
+	IDs []string `json:"ids"`
+}
+
+type outboxAdminHandler struct {
+	outbox *Outbox
+	config handler.AdminConfig
+}
+
+// NewOutboxAdminHandler creates a router for operators to inspect and retry the
+// dead-letter queue of the outbox. Like the router from handler.NewAdminHandler, it
+// should be mounted under a private path, e.g.:
+//
+//	mux.Handle("/admin/outbox/", http.StripPrefix("/admin/outbox/", outboxAdminHandler))
+//
+// The following routes are provided:
+//
+//	GET   dead-letters          the entries in the dead-letter queue
+//	POST  dead-letters/replay   deliver the entries again, see Outbox.Replay
+//	POST  dead-letters/discard  remove the entries, see Outbox.Discard
+//
+// The replay and discard routes accept a JSON body of the form {"ids": ["..."]}. If
+// no IDs are given, all dead letters are replayed or discarded. Every request is
+// authenticated using AdminConfig.Authenticate and recorded in the audit log, which
+// defaults to slog's default logger.
+func NewOutboxAdminHandler(outbox *Outbox, config handler.AdminConfig) (http.Handler, error) {
+	if config.Authenticate == nil {
+		return nil, errors.New("hooks: AdminConfig.Authenticate must be set")
+	}
+	if config.AuditLogger == nil {
+		config.AuditLogger = slog.Default()
+	}
+
+	admin := &outboxAdminHandler{
+		outbox: outbox,
+		config: config,
+	}
+
+	mux := pat.New()
+	mux.Get("dead-letters", admin.action("ListDeadLetters", admin.listDeadLetters))
+	mux.Post("dead-letters/replay", admin.action("ReplayDeadLetters", admin.replayDeadLetters))
+	mux.Post("dead-letters/discard", admin.action("DiscardDeadLetters", admin.discardDeadLetters))
+
+	return mux, nil
+}
+
+type outboxAdminActionFunc func(r *http.Request) (res any, ids []string, err error)
+
+// action authenticates the request, runs the action and records the outcome in the
+// audit log. The action's result is sent as JSON.
+func (admin *outboxAdminHandler) action(name string, fn outboxAdminActionFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res any
+		var ids []string
+		operator, err := admin.config.Authenticate(r)
+		if err != nil {
+			err = models.ErrAdminUnauthorized
+		} else {
+			res, ids, err = fn(r)
+		}
+
+		resp := models.HTTPResponse{
+			StatusCode: http.StatusOK,
+			Header: models.HTTPHeader{
+				"Content-Type":  "application/json",
+				"Cache-Control": "no-store",
+			},
+		}
+		if err == nil {
+			var body []byte
+			body, err = json.Marshal(res)
+			resp.Body = string(body)
+		}
+		if err != nil {
+			detailedErr, ok := err.(models.Error)
+			if !ok {
+				detailedErr = models.NewError("ERR_INTERNAL_SERVER_ERROR", err.Error(), http.StatusInternalServerError)
+			}
+			resp = detailedErr.HTTPResponse
+		}
+
+		resp.WriteTo(w)
+
+		attrs := []any{
+			"action", name,
+			"operator", operator,
+			"entries", ids,
+			"remoteAddr", r.RemoteAddr,
+			"status", resp.StatusCode,
+		}
+		if err != nil {
+			admin.config.AuditLogger.Warn("AdminAction", append(attrs, "error", err.Error())...)
+			return
+		}
+
+		admin.config.AuditLogger.Info("AdminAction", attrs...)
+	})
+}
+
+func (admin *outboxAdminHandler) listDeadLetters(r *http.Request) (any, []string, error) {
+	entries, err := admin.outbox.DeadLetters(r.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return map[string]any{"deadLetters": entries}, nil, nil
+}
+
+func (admin *outboxAdminHandler) replayDeadLetters(r *http.Request) (any, []string, error) {
+	ids, err := readOutboxAdminSelection(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	replayed, err := admin.outbox.Replay(r.Context(), ids...)
+	if err != nil {
+		return nil, ids, err
+	}
+
+	return map[string]int{"replayed": replayed}, ids, nil
+}
+
+func (admin *outboxAdminHandler) discardDeadLetters(r *http.Request) (any, []string, error) {
+	ids, err := readOutboxAdminSelection(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	discarded, err := admin.outbox.Discard(r.Context(), ids...)
+	if err != nil {
+		return nil, ids, err
+	}
+
+	return map[string]int{"discarded": discarded}, ids, nil
+}
+
+// readOutboxAdminSelection returns the IDs from the request body, which may be empty.
+func readOutboxAdminSelection(r *http.Request) ([]string, error) {
+	var selection outboxAdminSelection
+	if err := json.NewDecoder(r.Body).Decode(&selection); err != nil && err != io.EOF {
+		return nil, models.ErrInvalidRequestBody
+	}
+
+	return selection.IDs, nil
+}
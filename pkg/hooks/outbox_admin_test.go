@@ -0,0 +1,93 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/handler"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestOutboxAdminHandler(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store, err := OpenFileOutboxStore(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	assert.NoError(err)
+	defer store.Close()
+	for _, id := range []string{"a", "b", "c"} {
+		assert.NoError(store.Put(ctx, OutboxEntry{
+			ID:      id,
+			State:   OutboxDead,
+			Request: HookRequest{Type: HookPostFinish, Event: models.HookEvent{Upload: models.FileInfo{ID: "upload-" + id}}},
+		}))
+	}
+	// The outbox is not started, so replayed entries remain pending.
+	outbox := NewOutbox(store)
+
+	adminHandler, err := NewOutboxAdminHandler(outbox, handler.AdminConfig{
+		Authenticate: handler.AdminTokenAuthenticator(map[string]string{"secret": "alice"}),
+	})
+	assert.NoError(err)
+	server := httptest.NewServer(http.StripPrefix("/outbox/", adminHandler))
+	defer server.Close()
+
+	send := func(method string, path string, body string, token string) (int, string) {
+		req, err := http.NewRequest(method, server.URL+"/outbox/"+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		resBody, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, string(resBody)
+	}
+
+	status, _ := send("GET", "dead-letters", "", "wrong")
+	assert.Equal(http.StatusUnauthorized, status)
+	status, _ = send("POST", "dead-letters/replay", "", "wrong")
+	assert.Equal(http.StatusUnauthorized, status)
+
+	status, body := send("GET", "dead-letters", "", "secret")
+	assert.Equal(http.StatusOK, status)
+	var listed struct {
+		DeadLetters []OutboxEntry `json:"deadLetters"`
+	}
+	assert.NoError(json.Unmarshal([]byte(body), &listed))
+	if assert.Len(listed.DeadLetters, 3) {
+		assert.Equal("upload-a", listed.DeadLetters[0].Request.Event.Upload.ID)
+	}
+
+	status, body = send("POST", "dead-letters/replay", `{"ids":["a","unknown"]}`, "secret")
+	assert.Equal(http.StatusOK, status)
+	assert.JSONEq(`{"replayed":1}`, body)
+	pending, err := store.List(ctx, OutboxPending)
+	assert.NoError(err)
+	if assert.Len(pending, 1) {
+		assert.Equal("a", pending[0].ID)
+	}
+
+	status, _ = send("POST", "dead-letters/discard", `{"ids":`, "secret")
+	assert.Equal(http.StatusBadRequest, status)
+
+	// Without IDs, all dead letters are discarded.
+	status, body = send("POST", "dead-letters/discard", "", "secret")
+	assert.Equal(http.StatusOK, status)
+	assert.JSONEq(`{"discarded":2}`, body)
+	dead, err := outbox.DeadLetters(ctx)
+	assert.NoError(err)
+	assert.Empty(dead)
+}
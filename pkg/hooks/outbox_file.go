@@ -0,0 +1,240 @@
+package hooks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// fileOutboxCompactThreshold is the minimum number of records in the journal,
+// before it is compacted.
+const fileOutboxCompactThreshold = 1000
+
+// FileOutboxStore is an OutboxStore, which keeps the entries in memory and records
+// every change in an append-only journal file, one JSON object per line. The journal
+// is replayed when the store is opened and compacted once it contains mostly
+// outdated records. Every write is synced to disk before it returns.
+//
+// The journal must only be opened by a single process at a time.
+type FileOutboxStore struct {
+	path    string
+	file    *os.File
+	entries map[string]OutboxEntry
+	records int
+	mutex   sync.Mutex
+}
+
+// journalRecord is a line in the journal. Either Entry is set for storing an entry
+// or ID for deleting one.
+type journalRecord struct {
+	Entry *OutboxEntry `json:"entry,omitempty"`
+	ID    string       `json:"id,omitempty"`
+}
+
+// OpenFileOutboxStore opens the journal at the given path, creating it if it
+// does not exist yet.
+func OpenFileOutboxStore(path string) (*FileOutboxStore, error) {
+	store := &FileOutboxStore{
+		path:    path,
+		entries: make(map[string]OutboxEntry),
+	}
+
+	if err := store.replay(); err != nil {
+		return nil, err
+	}
+
+	// Compacting right away removes a record, which may have been cut off by a crash.
+	if err := store.compact(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (store *FileOutboxStore) Put(ctx context.Context, entry OutboxEntry) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if err := store.append(journalRecord{Entry: &entry}); err != nil {
+		return err
+	}
+	store.entries[entry.ID] = entry
+
+	return store.maybeCompact()
+}
+
+func (store *FileOutboxStore) Delete(ctx context.Context, id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if _, ok := store.entries[id]; !ok {
+		return nil
+	}
+
+	if err := store.append(journalRecord{ID: id}); err != nil {
+		return err
+	}
+	delete(store.entries, id)
+
+	return store.maybeCompact()
+}
+
+func (store *FileOutboxStore) List(ctx context.Context, state OutboxState) ([]OutboxEntry, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	entries := make([]OutboxEntry, 0)
+	for _, entry := range store.entries {
+		if entry.State == state {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	return entries, nil
+}
+
+// Close closes the journal. The store cannot be used afterwards.
+func (store *FileOutboxStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.file.Close()
+}
+
+// replay reads the journal into memory.
+func (store *FileOutboxStore) replay() error {
+	file, err := os.Open(store.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		// Only the end of the file stops the replay. Other errors must not be ignored,
+		// since the following compaction would remove the unread entries.
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("hooks: unable to read outbox journal %s: %w", store.path, err)
+		}
+		if len(data) > 0 && err == nil {
+			var record journalRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return fmt.Errorf("hooks: invalid record in outbox journal %s at line %d: %w", store.path, line, err)
+			}
+
+			if record.Entry != nil {
+				store.entries[record.Entry.ID] = *record.Entry
+			} else {
+				delete(store.entries, record.ID)
+			}
+		}
+
+		// A last line without a newline has not been written completely and is skipped.
+		if err == io.EOF {
+			break
+		}
+	}
+
+	return nil
+}
+
+// append writes the record to the journal and syncs it to disk.
+func (store *FileOutboxStore) append(record journalRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := store.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	store.records += 1
+
+	return store.file.Sync()
+}
+
+// maybeCompact compacts the journal, if most of its records are outdated.
+func (store *FileOutboxStore) maybeCompact() error {
+	if store.records < fileOutboxCompactThreshold || store.records < 2*len(store.entries) {
+		return nil
+	}
+
+	return store.compact()
+}
+
+// compact replaces the journal with a new one, which only contains the current
+// entries.
+func (store *FileOutboxStore) compact() error {
+	tmpPath := store.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for _, entry := range store.entries {
+		entry := entry
+		data, err := json.Marshal(journalRecord{Entry: &entry})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(data, '\n'))
+	}
+
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, store.path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(store.path))
+
+	file, err := os.OpenFile(store.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if store.file != nil {
+		store.file.Close()
+	}
+	store.file = file
+	store.records = len(store.entries)
+
+	return nil
+}
+
+// syncDir syncs the directory, so that a renamed file survives a crash. Errors are
+// ignored since not all platforms support syncing directories.
+func syncDir(path string) {
+	dir, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer dir.Close()
+
+	_ = dir.Sync()
+}
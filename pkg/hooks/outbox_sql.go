@@ -0,0 +1,103 @@
+package hooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/susufqx/dynamic-bucket-tusd/internal/sqlquery"
+)
+
+// SQLOutboxStore is an OutboxStore backed by a SQL database, such as SQLite. It uses
+// the database/sql package and the driver must be imported by the application. The
+// entries are not claimed before their delivery, so every instance of tusd should use
+// its own table. The entries are stored as JSON in a table with the following columns,
+// which can be created using CreateTable:
+//
+//	id     VARCHAR(255) PRIMARY KEY
+//	state  VARCHAR(16) NOT NULL
+//	entry  TEXT NOT NULL
+type SQLOutboxStore struct {
+	// DB is the database containing the outbox table.
+	DB *sql.DB
+	// Table is the name of the outbox table. Since it is not quoted in the
+	// statements, it must be a valid SQL identifier.
+	Table string
+	// NumberedPlaceholders must be set for databases expecting $1, $2 and so on as
+	// placeholders, such as PostgreSQL.
+	NumberedPlaceholders bool
+}
+
+// NewSQLOutboxStore creates a new SQL based outbox store, which stores the entries
+// in the given table. The table is not created automatically, use CreateTable in
+// this case.
+func NewSQLOutboxStore(db *sql.DB, table string) SQLOutboxStore {
+	return SQLOutboxStore{
+		DB:    db,
+		Table: table,
+	}
+}
+
+// CreateTable creates the table for storing the entries, if it does not exist yet.
+func (store SQLOutboxStore) CreateTable(ctx context.Context) error {
+	_, err := store.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+store.Table+" (id VARCHAR(255) PRIMARY KEY, state VARCHAR(16) NOT NULL, entry TEXT NOT NULL)")
+	return err
+}
+
+func (store SQLOutboxStore) Put(ctx context.Context, entry OutboxEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tx, err := store.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Replacing rows is not part of standard SQL, so the row is deleted and inserted
+	// in a transaction.
+	if _, err := tx.ExecContext(ctx, store.query("DELETE FROM "+store.Table+" WHERE id = ?"), entry.ID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, store.query("INSERT INTO "+store.Table+" (id, state, entry) VALUES (?, ?, ?)"), entry.ID, string(entry.State), string(data)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (store SQLOutboxStore) Delete(ctx context.Context, id string) error {
+	_, err := store.DB.ExecContext(ctx, store.query("DELETE FROM "+store.Table+" WHERE id = ?"), id)
+	return err
+}
+
+func (store SQLOutboxStore) List(ctx context.Context, state OutboxState) ([]OutboxEntry, error) {
+	rows, err := store.DB.QueryContext(ctx, store.query("SELECT entry FROM "+store.Table+" WHERE state = ? ORDER BY id"), string(state))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]OutboxEntry, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+
+		var entry OutboxEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// query adapts the placeholders in the statement to the database.
+func (store SQLOutboxStore) query(statement string) string {
+	return sqlquery.Rewrite(statement, store.NumberedPlaceholders)
+}
@@ -0,0 +1,358 @@
+package hooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// flakyHookHandler fails the given number of invocations and records the successful
+// ones afterwards.
+type flakyHookHandler struct {
+	mutex     sync.Mutex
+	failures  int
+	calls     int
+	delivered []string
+}
+
+func (h *flakyHookHandler) Setup() error {
+	return nil
+}
+
+func (h *flakyHookHandler) InvokeHook(req HookRequest) (HookResponse, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.calls += 1
+	if req.Event.Context == nil {
+		return HookResponse{}, errors.New("missing context")
+	}
+	if h.failures > 0 {
+		h.failures -= 1
+		return HookResponse{}, errors.New("hook handler is unavailable")
+	}
+
+	h.delivered = append(h.delivered, string(req.Type)+":"+req.Event.Upload.ID)
+	return HookResponse{}, nil
+}
+
+func (h *flakyHookHandler) result() (calls int, delivered []string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.calls, append([]string{}, h.delivered...)
+}
+
+// waitFor polls the condition until it is true or a second has passed.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(5 * time.Millisecond) {
+		if condition() {
+			return
+		}
+	}
+	t.Fatal("condition has not been met in time")
+}
+
+func newTestOutbox(t *testing.T, store OutboxStore, hookHandler HookHandler) *Outbox {
+	outbox := NewOutbox(store)
+	outbox.InitialBackoff = 10 * time.Millisecond
+	outbox.MaxAttempts = 3
+	outbox.Start(hookHandler)
+	t.Cleanup(outbox.Stop)
+
+	return outbox
+}
+
+func TestOutboxRetries(t *testing.T) {
+	assert := assert.New(t)
+	store, err := OpenFileOutboxStore(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	assert.NoError(err)
+	hookHandler := &flakyHookHandler{failures: 2}
+	outbox := newTestOutbox(t, store, hookHandler)
+
+	outbox.Enqueue(HookPostFinish, models.HookEvent{Upload: models.FileInfo{ID: "a"}})
+	waitFor(t, func() bool {
+		_, delivered := hookHandler.result()
+		return len(delivered) == 1
+	})
+
+	calls, delivered := hookHandler.result()
+	assert.Equal(3, calls)
+	assert.Equal([]string{"post-finish:a"}, delivered)
+
+	// Delivered entries are removed.
+	waitFor(t, func() bool {
+		pending, err := store.List(context.Background(), OutboxPending)
+		return err == nil && len(pending) == 0
+	})
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	outbox := &Outbox{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	var delays []time.Duration
+	for attempts := 1; attempts <= 5; attempts++ {
+		delays = append(delays, outbox.backoff(attempts))
+	}
+
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, delays)
+}
+
+func TestOutboxDeadLetters(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store, err := OpenFileOutboxStore(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	assert.NoError(err)
+	hookHandler := &flakyHookHandler{failures: 6}
+	outbox := newTestOutbox(t, store, hookHandler)
+
+	outbox.Enqueue(HookPostCreate, models.HookEvent{Upload: models.FileInfo{ID: "a"}})
+	outbox.Enqueue(HookPostTerminate, models.HookEvent{Upload: models.FileInfo{ID: "b"}})
+
+	var deadLetters []OutboxEntry
+	waitFor(t, func() bool {
+		deadLetters, err = outbox.DeadLetters(ctx)
+		return err == nil && len(deadLetters) == 2
+	})
+	for _, entry := range deadLetters {
+		assert.Equal(OutboxDead, entry.State)
+		assert.Equal(3, entry.Attempts)
+		assert.Equal("hook handler is unavailable", entry.LastError)
+	}
+	assert.Equal("a", deadLetters[0].Request.Event.Upload.ID)
+
+	// Dead letters are not retried on their own.
+	time.Sleep(50 * time.Millisecond)
+	calls, _ := hookHandler.result()
+	assert.Equal(6, calls)
+
+	discarded, err := outbox.Discard(ctx, deadLetters[1].ID, "unknown")
+	assert.NoError(err)
+	assert.Equal(1, discarded)
+
+	replayed, err := outbox.Replay(ctx)
+	assert.NoError(err)
+	assert.Equal(1, replayed)
+	waitFor(t, func() bool {
+		_, delivered := hookHandler.result()
+		return len(delivered) == 1
+	})
+	_, delivered := hookHandler.result()
+	assert.Equal([]string{"post-create:a"}, delivered)
+
+	deadLetters, err = outbox.DeadLetters(ctx)
+	assert.NoError(err)
+	assert.Empty(deadLetters)
+}
+
+func TestOutboxStoreError(t *testing.T) {
+	assert := assert.New(t)
+	store, err := OpenFileOutboxStore(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	assert.NoError(err)
+	hookHandler := &flakyHookHandler{}
+	outbox := newTestOutbox(t, store, hookHandler)
+
+	// If the request cannot be stored, it is delivered once nevertheless.
+	assert.NoError(store.Close())
+	outbox.Enqueue(HookPostFinish, models.HookEvent{Context: context.Background(), Upload: models.FileInfo{ID: "a"}})
+	waitFor(t, func() bool {
+		_, delivered := hookHandler.result()
+		return len(delivered) == 1
+	})
+}
+
+// testOutboxStore checks the behavior of an OutboxStore, which must be empty.
+func testOutboxStore(t *testing.T, store OutboxStore) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	for _, id := range []string{"c", "a", "b"} {
+		assert.NoError(store.Put(ctx, OutboxEntry{
+			ID:      id,
+			State:   OutboxPending,
+			Request: HookRequest{Type: HookPostFinish, Event: models.HookEvent{Upload: models.FileInfo{ID: "upload-" + id}}},
+		}))
+	}
+	assert.NoError(store.Put(ctx, OutboxEntry{ID: "b", State: OutboxDead, Attempts: 3, LastError: "failed"}))
+	assert.NoError(store.Delete(ctx, "c"))
+	assert.NoError(store.Delete(ctx, "unknown"))
+
+	pending, err := store.List(ctx, OutboxPending)
+	assert.NoError(err)
+	if assert.Len(pending, 1) {
+		assert.Equal("a", pending[0].ID)
+		assert.Equal(HookPostFinish, pending[0].Request.Type)
+		assert.Equal("upload-a", pending[0].Request.Event.Upload.ID)
+	}
+
+	dead, err := store.List(ctx, OutboxDead)
+	assert.NoError(err)
+	if assert.Len(dead, 1) {
+		assert.Equal("b", dead[0].ID)
+		assert.Equal(3, dead[0].Attempts)
+		assert.Equal("failed", dead[0].LastError)
+	}
+}
+
+func TestFileOutboxStore(t *testing.T) {
+	store, err := OpenFileOutboxStore(filepath.Join(t.TempDir(), "outbox.jsonl"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	testOutboxStore(t, store)
+}
+
+func TestSQLOutboxStore(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Skipf("SQLite is not available: %s", err)
+	}
+
+	store := NewSQLOutboxStore(db, "outbox")
+	assert.NoError(t, store.CreateTable(context.Background()))
+	assert.NoError(t, store.CreateTable(context.Background()))
+
+	testOutboxStore(t, store)
+}
+
+func TestOutboxAfterRestart(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	// The first process stores the entry, but cannot deliver it.
+	store, err := OpenFileOutboxStore(path)
+	assert.NoError(err)
+	outbox := NewOutbox(store)
+	outbox.Enqueue(HookPostFinish, models.HookEvent{Upload: models.FileInfo{ID: "a"}})
+	assert.NoError(store.Close())
+
+	// The next process delivers it.
+	store, err = OpenFileOutboxStore(path)
+	assert.NoError(err)
+	defer store.Close()
+	hookHandler := &flakyHookHandler{}
+	newTestOutbox(t, store, hookHandler)
+
+	waitFor(t, func() bool {
+		_, delivered := hookHandler.result()
+		return len(delivered) == 1
+	})
+	waitFor(t, func() bool {
+		pending, err := store.List(ctx, OutboxPending)
+		return err == nil && len(pending) == 0
+	})
+}
+
+func TestFileOutboxStoreJournal(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	store, err := OpenFileOutboxStore(path)
+	assert.NoError(err)
+	assert.NoError(store.Put(ctx, OutboxEntry{ID: "a", State: OutboxPending}))
+	assert.NoError(store.Put(ctx, OutboxEntry{ID: "b", State: OutboxPending}))
+	assert.NoError(store.Put(ctx, OutboxEntry{ID: "b", State: OutboxDead}))
+	assert.NoError(store.Delete(ctx, "a"))
+	assert.NoError(store.Close())
+
+	// A crash while appending leaves an incomplete last record, which is skipped.
+	journal, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	assert.NoError(err)
+	_, err = journal.WriteString(`{"entry":{"id":"c","sta`)
+	assert.NoError(err)
+	assert.NoError(journal.Close())
+
+	store, err = OpenFileOutboxStore(path)
+	assert.NoError(err)
+	pending, err := store.List(ctx, OutboxPending)
+	assert.NoError(err)
+	assert.Empty(pending)
+	dead, err := store.List(ctx, OutboxDead)
+	assert.NoError(err)
+	if assert.Len(dead, 1) {
+		assert.Equal("b", dead[0].ID)
+	}
+
+	// Opening the store compacts the journal, so it only contains the current entry.
+	data, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal(1, strings.Count(string(data), "\n"))
+
+	// New records are appended after the compacted journal.
+	assert.NoError(store.Put(ctx, OutboxEntry{ID: "d", State: OutboxPending}))
+	assert.NoError(store.Close())
+	store, err = OpenFileOutboxStore(path)
+	assert.NoError(err)
+	defer store.Close()
+	pending, err = store.List(ctx, OutboxPending)
+	assert.NoError(err)
+	assert.Len(pending, 1)
+}
+
+func TestFileOutboxStoreCompaction(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	store, err := OpenFileOutboxStore(path)
+	assert.NoError(err)
+	defer store.Close()
+
+	for i := 0; i < fileOutboxCompactThreshold; i++ {
+		id := fmt.Sprintf("%04d", i)
+		assert.NoError(store.Put(ctx, OutboxEntry{ID: id, State: OutboxPending}))
+		if i%10 != 0 {
+			assert.NoError(store.Delete(ctx, id))
+		}
+	}
+
+	// Most records have become outdated, so the journal has been compacted.
+	data, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.Less(strings.Count(string(data), "\n"), fileOutboxCompactThreshold)
+
+	pending, err := store.List(ctx, OutboxPending)
+	assert.NoError(err)
+	assert.Len(pending, fileOutboxCompactThreshold/10)
+}
+
+func TestFileOutboxStoreInvalidJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	assert.NoError(t, os.WriteFile(path, []byte("{\"entry\":{\"id\":\"a\"}}\nnot json\n"), 0o600))
+
+	_, err := OpenFileOutboxStore(path)
+	assert.ErrorContains(t, err, "line 2")
+
+	// The journal is kept, so that it can be repaired.
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "not json")
+
+	// A journal, which cannot be read, is not replaced either.
+	dir := filepath.Join(t.TempDir(), "outbox.jsonl")
+	assert.NoError(t, os.Mkdir(dir, 0o700))
+	_, err = OpenFileOutboxStore(dir)
+	assert.ErrorContains(t, err, "unable to read outbox journal")
+	info, err := os.Stat(dir)
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+}
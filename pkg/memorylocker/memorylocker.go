@@ -17,7 +17,10 @@ package memorylocker
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
 )
@@ -33,6 +36,7 @@ type MemoryLocker struct {
 type lockEntry struct {
 	lockReleased   chan struct{}
 	requestRelease func()
+	acquiredAt     time.Time
 }
 
 // New creates a new in-memory locker.
@@ -48,16 +52,66 @@ func (locker *MemoryLocker) UseIn(composer *models.StoreComposer) {
 }
 
 func (locker *MemoryLocker) NewLock(id string) (models.Lock, error) {
-	return memoryLock{locker, id}, nil
+	return &memoryLock{locker: locker, id: id}, nil
+}
+
+// InspectLock returns information about the lock for the given upload. Since the
+// locks only exist in this process, the holder is always this process.
+func (locker *MemoryLocker) InspectLock(ctx context.Context, id string) (models.LockInfo, error) {
+	locker.mutex.RLock()
+	entry, ok := locker.locks[id]
+	locker.mutex.RUnlock()
+
+	if !ok {
+		return models.LockInfo{}, models.ErrLockNotHeld
+	}
+
+	return models.LockInfo{
+		Holder:     holder(),
+		AcquiredAt: entry.acquiredAt,
+	}, nil
+}
+
+// ForceUnlock requests the holder to release the lock and removes the lock
+// afterwards, so that it can be acquired again even if the holder does not react.
+func (locker *MemoryLocker) ForceUnlock(ctx context.Context, id string) error {
+	locker.mutex.Lock()
+	entry, ok := locker.locks[id]
+	if ok {
+		delete(locker.locks, id)
+	}
+	locker.mutex.Unlock()
+
+	if !ok {
+		return models.ErrLockNotHeld
+	}
+
+	entry.requestRelease()
+	close(entry.lockReleased)
+
+	return nil
+}
+
+// holder returns a description of this process for LockInfo.Holder.
+func holder() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s/%d", hostname, os.Getpid())
 }
 
 type memoryLock struct {
 	locker *MemoryLocker
 	id     string
+
+	// lockReleased identifies the lock entry created by this lock, so that it is
+	// not removed by Unlock after it has been forcefully released.
+	lockReleased chan struct{}
 }
 
 // Lock tries to obtain the exclusive lock.
-func (lock memoryLock) Lock(ctx context.Context, requestRelease func()) error {
+func (lock *memoryLock) Lock(ctx context.Context, requestRelease func()) error {
 	lock.locker.mutex.RLock()
 	entry, ok := lock.locker.locks[lock.id]
 	lock.locker.mutex.RUnlock()
@@ -85,26 +139,34 @@ requestRelease:
 	entry = lockEntry{
 		lockReleased:   make(chan struct{}),
 		requestRelease: requestRelease,
+		acquiredAt:     time.Now(),
 	}
 
 	lock.locker.locks[lock.id] = entry
+	lock.lockReleased = entry.lockReleased
 	lock.locker.mutex.Unlock()
 
 	return nil
 }
 
 // Unlock releases a lock. If no such lock exists, no error will be returned.
-func (lock memoryLock) Unlock() error {
+func (lock *memoryLock) Unlock() error {
 	lock.locker.mutex.Lock()
 
-	lockReleased := lock.locker.locks[lock.id].lockReleased
+	// The lock might have been released forcefully and acquired by someone else
+	// in the meantime, in which case there is nothing left to release.
+	entry, ok := lock.locker.locks[lock.id]
+	if !ok || entry.lockReleased != lock.lockReleased {
+		lock.locker.mutex.Unlock()
+		return nil
+	}
 
 	// Delete the lock entry entirely
 	delete(lock.locker.locks, lock.id)
 
 	lock.locker.mutex.Unlock()
 
-	close(lockReleased)
+	close(entry.lockReleased)
 
 	return nil
 }
@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"hash"
 	"io"
 	"net"
 	"net/http"
@@ -30,6 +31,8 @@ type BodyReader struct {
 	reader       io.ReadCloser
 	err          error
 	onReadDone   func()
+	// hashes contains the hashes into which all bytes from the body are fed.
+	hashes []hash.Hash
 }
 
 func NewBodyReader(c *HttpContext, maxSize int64) *BodyReader {
@@ -44,6 +47,19 @@ func (r *BodyReader) SetOnReadDone(f func()) {
 	r.onReadDone = f
 }
 
+// AddHash instructs the BodyReader to feed all bytes read from the request body
+// into the provided hash. It must be called before the body is consumed.
+func (r *BodyReader) AddHash(h hash.Hash) {
+	r.hashes = append(r.hashes, h)
+}
+
+// ContentLength returns the length of the request body as declared by the client,
+// or -1 if it is unknown. Data stores can use it to decide how to store the body,
+// but must not rely on the body actually containing this many bytes.
+func (r *BodyReader) ContentLength() int64 {
+	return r.ctx.req.ContentLength
+}
+
 func (r *BodyReader) Read(b []byte) (int, error) {
 	if r.err != nil {
 		return 0, io.EOF
@@ -51,6 +67,9 @@ func (r *BodyReader) Read(b []byte) (int, error) {
 
 	n, err := r.reader.Read(b)
 	atomic.AddInt64(&r.bytesCounter, int64(n))
+	for _, h := range r.hashes {
+		h.Write(b[:n])
+	}
 	if !errors.Is(err, os.ErrDeadlineExceeded) {
 		// If the timeout wasn't exceeded (due to SetReadDeadline), invoke
 		// the callback so the deadline can be extended
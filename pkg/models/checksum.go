@@ -0,0 +1,77 @@
+package models
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"sort"
+	"strings"
+)
+
+// ChecksumAlgorithms maps the name of a checksum algorithm, as used in the
+// Upload-Checksum header, to a constructor for the corresponding hash.
+type ChecksumAlgorithms map[string]func() hash.Hash
+
+// DefaultChecksumAlgorithms contains all checksum algorithms which are supported
+// by default. Additional algorithms can be supported by adding them to
+// Config.ChecksumAlgorithms.
+var DefaultChecksumAlgorithms = ChecksumAlgorithms{
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+	"crc32":  func() hash.Hash { return crc32.NewIEEE() },
+	"sha256": sha256.New,
+}
+
+// Names returns a sorted, comma-separated list of the algorithm names, as used
+// in the Tus-Checksum-Algorithm header.
+func (algorithms ChecksumAlgorithms) Names() string {
+	names := make([]string, 0, len(algorithms))
+	for name := range algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ",")
+}
+
+// Checksum is the parsed value of an Upload-Checksum header.
+type Checksum struct {
+	// Algorithm is the name of the used algorithm, e.g. sha1.
+	Algorithm string
+	// Sum is the decoded checksum as provided by the client.
+	Sum []byte
+}
+
+// ParseChecksumHeader parses the Upload-Checksum header as defined in the
+// Checksum extension, e.g. Upload-Checksum: sha1 Kq5sNclPz7QV2+lfQIuc6R7oRu0=
+// Only algorithms included in the provided map are accepted.
+func ParseChecksumHeader(header string, algorithms ChecksumAlgorithms) (Checksum, hash.Hash, error) {
+	algorithm, encoded, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok {
+		return Checksum{}, nil, ErrInvalidChecksum
+	}
+
+	newHash, ok := algorithms[algorithm]
+	if !ok {
+		return Checksum{}, nil, ErrUnsupportedChecksumAlgorithm
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(sum) == 0 {
+		return Checksum{}, nil, ErrInvalidChecksum
+	}
+
+	return Checksum{
+		Algorithm: algorithm,
+		Sum:       sum,
+	}, newHash(), nil
+}
+
+// Matches returns whether the calculated sum is equal to the provided one.
+func (checksum Checksum) Matches(sum []byte) bool {
+	return bytes.Equal(checksum.Sum, sum)
+}
@@ -14,6 +14,16 @@ type StoreComposer struct {
 	Concater           ConcaterDataStore
 	UsesLengthDeferrer bool
 	LengthDeferrer     LengthDeferrerDataStore
+	UsesChecksum       bool
+	Checksum           ChecksumDataStore
+	UsesDigester       bool
+	Digester           DigesterDataStore
+	UsesExpirer        bool
+	Expirer            ExpirerDataStore
+	UsesDirectUploader bool
+	DirectUploader     DirectUploaderDataStore
+	UsesLister         bool
+	Lister             ListerDataStore
 }
 
 // NewStoreComposer creates a new and empty store composer.
@@ -56,6 +66,36 @@ func (store *StoreComposer) Capabilities() string {
 	} else {
 		str += "✗"
 	}
+	str += ` Checksum: `
+	if store.UsesChecksum {
+		str += "✓"
+	} else {
+		str += "✗"
+	}
+	str += ` Digester: `
+	if store.UsesDigester {
+		str += "✓"
+	} else {
+		str += "✗"
+	}
+	str += ` Expirer: `
+	if store.UsesExpirer {
+		str += "✓"
+	} else {
+		str += "✗"
+	}
+	str += ` DirectUploader: `
+	if store.UsesDirectUploader {
+		str += "✓"
+	} else {
+		str += "✗"
+	}
+	str += ` Lister: `
+	if store.UsesLister {
+		str += "✓"
+	} else {
+		str += "✗"
+	}
 
 	return str
 }
@@ -85,3 +125,28 @@ func (store *StoreComposer) UseLengthDeferrer(ext LengthDeferrerDataStore) {
 	store.UsesLengthDeferrer = ext != nil
 	store.LengthDeferrer = ext
 }
+
+func (store *StoreComposer) UseChecksum(ext ChecksumDataStore) {
+	store.UsesChecksum = ext != nil
+	store.Checksum = ext
+}
+
+func (store *StoreComposer) UseDigester(ext DigesterDataStore) {
+	store.UsesDigester = ext != nil
+	store.Digester = ext
+}
+
+func (store *StoreComposer) UseExpirer(ext ExpirerDataStore) {
+	store.UsesExpirer = ext != nil
+	store.Expirer = ext
+}
+
+func (store *StoreComposer) UseDirectUploader(ext DirectUploaderDataStore) {
+	store.UsesDirectUploader = ext != nil
+	store.DirectUploader = ext
+}
+
+func (store *StoreComposer) UseLister(ext ListerDataStore) {
+	store.UsesLister = ext != nil
+	store.Lister = ext
+}
@@ -63,7 +63,9 @@ func (c HttpContext) GetResC() *http.ResponseController {
 	return c.resC
 }
 
-func (c HttpContext) GetCancel() context.CancelCauseFunc {
+// GetCancel uses a pointer receiver, since it is called from other goroutines, e.g.
+// during shutdown, while the request handler modifies the context's Body.
+func (c *HttpContext) GetCancel() context.CancelCauseFunc {
 	return c.cancel
 }
 
@@ -3,6 +3,7 @@ package models
 import (
 	"context"
 	"io"
+	"time"
 )
 
 type MetaData map[string]string
@@ -32,13 +33,37 @@ type FileInfo struct {
 	// for example a file path. The available values vary depending on what data
 	// store is used. This map may also be nil.
 	Storage map[string]string
+	// Digests contains the digests of the upload's entire content, keyed by the
+	// algorithm (e.g. sha-256) and encoded using Base64. It is only available once
+	// the upload is finished, if digests are enabled and the data store implements
+	// the DigesterDataStore interface.
+	Digests map[string]string
+	// DigestState holds the intermediate state of the digests while the upload is
+	// in progress, so they can be continued across requests and restarts.
+	DigestState *DigestState `json:",omitempty"`
+	// ExpiresAt is the point in time after which the upload expires if it has not
+	// been finished until then. A zero value means that the upload does not expire.
+	ExpiresAt time.Time
+	// ModifiedAt is the point in time at which the upload's data was last modified,
+	// as reported by the data store when the upload is fetched. It is used for the
+	// Last-Modified header and is zero if the data store does not provide it.
+	ModifiedAt time.Time
+	// DirectUpload holds the state of the upload, if its data is uploaded by the
+	// client directly to the storage backend. See DirectUploaderDataStore.
+	DirectUpload *DirectUploadState
+	// CreatedAt is the point in time at which the upload was created. It is zero for
+	// uploads, which have been created before it was recorded.
+	CreatedAt time.Time
 
 	// stopUpload is a callback for communicating that an upload should by stopped
 	// and interrupt the writes to DataStore#WriteChunk.
 	stopUpload func(HTTPResponse)
 }
 
-func (f FileInfo) SetStopUpload(stopUpload func(HTTPResponse)) {
+// SetStopUpload sets the callback, which is invoked by StopUpload. It has a pointer
+// receiver, since the callback would otherwise be set on a copy and StopUpload would
+// never do anything.
+func (f *FileInfo) SetStopUpload(stopUpload func(HTTPResponse)) {
 	f.stopUpload = stopUpload
 }
 
@@ -54,6 +79,13 @@ func (f FileInfo) StopUpload(response HTTPResponse) {
 	}
 }
 
+// IsExpired returns whether the upload is unfinished and its expiration date has
+// passed at the given time.
+func (f FileInfo) IsExpired(now time.Time) bool {
+	isFinished := !f.SizeIsDeferred && f.Offset == f.Size
+	return !f.ExpiresAt.IsZero() && !isFinished && now.After(f.ExpiresAt)
+}
+
 // FileInfoChanges collects changes the should be made to a FileInfo struct. This
 // can be done using the PreUploadCreateCallback to modify certain properties before
 // an upload is created. Properties which should not be modified (e.g. Size or Offset)
@@ -80,6 +112,10 @@ type FileInfoChanges struct {
 	// Please be aware that this behavior is currently not supported by any data store in
 	// the github.com/tus/tusd package.
 	Storage map[string]string
+
+	// If ExpiresAt is not zero, it replaces the expiration date of the upload as
+	// configured in Config.UploadExpiration.
+	ExpiresAt time.Time
 }
 
 type Upload interface {
@@ -105,6 +141,37 @@ type Upload interface {
 	FinishUpload(ctx context.Context) error
 }
 
+// RangeReader is an optional interface, which can be implemented by uploads to
+// efficiently read a part of their content, e.g. for responding to requests with
+// a Range header. If an upload does not implement it, the handler reads the
+// content from the beginning using GetReader and skips the preceding bytes.
+type RangeReader interface {
+	// GetRangeReader returns an io.ReadCloser for length bytes of the upload's
+	// content, starting at the given offset. The handler ensures that the range
+	// is within the upload's current offset.
+	GetRangeReader(ctx context.Context, offset int64, length int64) (io.ReadCloser, error)
+}
+
+// PresignableUpload is an optional interface, which can be implemented by uploads
+// whose content can be downloaded directly from the storage backend using a presigned
+// URL. It is used if Config.PresignDownloads is enabled.
+type PresignableUpload interface {
+	// PresignGetURL returns a URL, which allows downloading the upload's content
+	// without further authentication until it expires.
+	PresignGetURL(ctx context.Context, options PresignOptions) (string, error)
+}
+
+// PresignOptions controls the URL returned by PresignableUpload.PresignGetURL.
+type PresignOptions struct {
+	// Expires is the duration for which the URL is valid.
+	Expires time.Duration
+	// ContentType overwrites the Content-Type header in the response to the URL, if set.
+	ContentType string
+	// ContentDisposition overwrites the Content-Disposition header in the response to
+	// the URL, if set.
+	ContentDisposition string
+}
+
 // DataStore is the base interface for storages to implement. It provides functions
 // to create new uploads and fetch existing ones.
 //
@@ -164,6 +231,78 @@ type LengthDeclarableUpload interface {
 	DeclareLength(ctx context.Context, length int64) error
 }
 
+// ChecksumDataStore is the interface that must be implemented if the checksum
+// extension should be enabled. The handler verifies the checksum of each chunk
+// on its own while the data is read from the request body. However, the data
+// store must be able to discard a chunk again once it has been written, if the
+// checksum turns out to not match.
+type ChecksumDataStore interface {
+	AsRollbackableUpload(upload Upload) RollbackableUpload
+}
+
+type RollbackableUpload interface {
+	// RollbackChunk discards all data which has been written by the most recent
+	// call to WriteChunk on this upload, so that the upload's offset is reset
+	// to the provided offset. The handler will only call this function directly
+	// after WriteChunk has returned and while holding the upload's lock.
+	RollbackChunk(ctx context.Context, offset int64) error
+}
+
+// DigesterDataStore is the interface that must be implemented if the digests of
+// entire uploads should be calculated. The handler calculates the digests on its
+// own while the data is read from the request bodies, but the data store must
+// persist their intermediate state between requests.
+type DigesterDataStore interface {
+	AsDigestableUpload(upload Upload) DigestableUpload
+}
+
+type DigestableUpload interface {
+	// UpdateDigests stores the digest state and the final digests (which are nil
+	// while the upload is in progress), so that they are included in the FileInfo
+	// returned by subsequent GetInfo calls.
+	UpdateDigests(ctx context.Context, state *DigestState, digests map[string]string) error
+}
+
+// ExpirerDataStore is the interface which must be implemented by DataStores
+// if expired uploads should be removed automatically. The removal itself is
+// performed using the TerminaterDataStore interface.
+type ExpirerDataStore interface {
+	// ListExpiredUploads returns the IDs of all unfinished uploads, whose
+	// expiration date is before the given time.
+	ListExpiredUploads(ctx context.Context, before time.Time) ([]string, error)
+}
+
+// DirectUploaderDataStore is the interface which must be implemented by DataStores
+// if clients should be able to upload the data of an upload directly to the storage
+// backend using presigned URLs, while the handler only coordinates the upload.
+type DirectUploaderDataStore interface {
+	AsDirectUploadableUpload(upload Upload) DirectUploadableUpload
+}
+
+type DirectUploadableUpload interface {
+	// PresignUploadParts returns presigned URLs for uploading the parts with the
+	// given numbers. On the first call, the upload is switched to direct uploads,
+	// which is only possible if its size is known and no data has been written to it.
+	// Afterwards, FileInfo.DirectUpload is set and the upload does not accept
+	// WriteChunk calls anymore.
+	PresignUploadParts(ctx context.Context, partNumbers []int32, expires time.Duration) ([]PresignedPart, error)
+	// CompleteUploadParts verifies that the given parts have been uploaded and
+	// records them. The upload's offset is advanced to the end of the last part
+	// which is preceded only by completed parts. Once the offset reaches the size,
+	// the handler finishes the upload as usual.
+	CompleteUploadParts(ctx context.Context, parts []UploadedPart) error
+}
+
+// ListerDataStore is the interface which must be implemented by DataStores if their
+// uploads should be enumerable, for example to inspect the uploads in progress.
+type ListerDataStore interface {
+	// ListUploads returns the uploads matching the options, ordered by their IDs.
+	// At most options.Limit uploads are returned at once. If more uploads may
+	// exist, ListUploadsResult.NextCursor is set and can be passed in the options
+	// to retrieve them.
+	ListUploads(ctx context.Context, options ListUploadsOptions) (ListUploadsResult, error)
+}
+
 // Locker is the interface required for custom lock persisting mechanisms.
 // Common ways to store this information is in memory, on disk or using an
 // external service, such as Redis.
@@ -192,3 +331,25 @@ type Lock interface {
 	// Unlock releases an existing lock for the given upload.
 	Unlock() error
 }
+
+// LockInspector is an optional interface for Lockers, which allows operators to find
+// out who holds a lock and to release locks, whose holder got stuck.
+type LockInspector interface {
+	// InspectLock returns information about the lock for the given upload. If the
+	// upload is not locked, ErrLockNotHeld is returned.
+	InspectLock(ctx context.Context, id string) (LockInfo, error)
+	// ForceUnlock releases the lock for the given upload, regardless of who holds it.
+	// The holder is asked to release the lock first, so that it stops its operation.
+	// If the upload is not locked, ErrLockNotHeld is returned.
+	ForceUnlock(ctx context.Context, id string) error
+}
+
+// LockInfo describes a held lock, as returned by LockInspector.InspectLock.
+type LockInfo struct {
+	// Holder identifies the instance holding the lock, e.g. by its host name and
+	// process ID.
+	Holder string
+	// AcquiredAt is the point in time at which the lock was acquired. It is zero if
+	// the locker does not know it.
+	AcquiredAt time.Time
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStopUpload(t *testing.T) {
+	var info FileInfo
+	// Without a callback, StopUpload does nothing.
+	info.StopUpload(HTTPResponse{})
+
+	var stopped []HTTPResponse
+	info.SetStopUpload(func(res HTTPResponse) {
+		stopped = append(stopped, res)
+	})
+
+	// Copies, e.g. the FileInfo in a HookEvent, stop the upload as well.
+	copied := info
+	copied.StopUpload(HTTPResponse{StatusCode: http.StatusForbidden})
+
+	assert.Equal(t, []HTTPResponse{{StatusCode: http.StatusForbidden}}, stopped)
+}
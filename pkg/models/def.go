@@ -41,9 +41,28 @@ var (
 	ErrInvalidUploadDeferLength         = NewError("ERR_INVALID_UPLOAD_LENGTH_DEFER", "invalid Upload-Defer-Length header", http.StatusBadRequest)
 	ErrUploadStoppedByServer            = NewError("ERR_UPLOAD_STOPPED", "upload has been stopped by server", http.StatusBadRequest)
 	ErrUploadRejectedByServer           = NewError("ERR_UPLOAD_REJECTED", "upload creation has been rejected by server", http.StatusBadRequest)
+	ErrDownloadRejectedByServer         = NewError("ERR_DOWNLOAD_REJECTED", "download has been rejected by server", http.StatusForbidden)
+	ErrUploadAccessRejectedByServer     = NewError("ERR_UPLOAD_ACCESS_REJECTED", "access to upload has been rejected by server", http.StatusForbidden)
+	ErrUploadPatchRejectedByServer      = NewError("ERR_UPLOAD_PATCH_REJECTED", "writing to upload has been rejected by server", http.StatusForbidden)
+	ErrTerminationRejectedByServer      = NewError("ERR_TERMINATION_REJECTED", "upload termination has been rejected by server", http.StatusForbidden)
 	ErrUploadInterrupted                = NewError("ERR_UPLOAD_INTERRUPTED", "upload has been interrupted by another request for this upload resource", http.StatusBadRequest)
 	ErrServerShutdown                   = NewError("ERR_SERVER_SHUTDOWN", "request has been interrupted because the server is shutting down", http.StatusServiceUnavailable)
 	ErrOriginNotAllowed                 = NewError("ERR_ORIGIN_NOT_ALLOWED", "request origin is not allowed", http.StatusForbidden)
+	ErrInvalidChecksum                  = NewError("ERR_INVALID_CHECKSUM", "invalid Upload-Checksum header", http.StatusBadRequest)
+	ErrUnsupportedChecksumAlgorithm     = NewError("ERR_UNSUPPORTED_CHECKSUM_ALGORITHM", "unsupported checksum algorithm", http.StatusBadRequest)
+	ErrChecksumMismatch                 = NewError("ERR_CHECKSUM_MISMATCH", "checksum mismatch", 460)
+	ErrUploadExpired                    = NewError("ERR_UPLOAD_EXPIRED", "upload has expired", http.StatusGone)
+	ErrRangeNotSatisfiable              = NewError("ERR_RANGE_NOT_SATISFIABLE", "requested range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+	ErrDirectUploadUnavailable          = NewError("ERR_DIRECT_UPLOAD_UNAVAILABLE", "upload cannot be switched to direct uploads", http.StatusConflict)
+	ErrDirectUploadInProgress           = NewError("ERR_DIRECT_UPLOAD_IN_PROGRESS", "upload receives its data directly in the storage backend", http.StatusConflict)
+	ErrInvalidPartNumber                = NewError("ERR_INVALID_PART_NUMBER", "invalid part number", http.StatusBadRequest)
+	ErrInvalidUploadedPart              = NewError("ERR_INVALID_UPLOADED_PART", "uploaded part not found or does not match", http.StatusBadRequest)
+	ErrInvalidRequestBody               = NewError("ERR_INVALID_REQUEST_BODY", "invalid request body", http.StatusBadRequest)
+	ErrInfoConflict                     = NewError("ERR_UPLOAD_INFO_CONFLICT", "upload information has been modified concurrently, please retry", http.StatusConflict)
+	ErrInvalidListQuery                 = NewError("ERR_INVALID_LIST_QUERY", "invalid query parameter for listing uploads", http.StatusBadRequest)
+	ErrAdminUnauthorized                = NewError("ERR_ADMIN_UNAUTHORIZED", "missing or invalid credentials for the admin API", http.StatusUnauthorized)
+	ErrLockNotHeld                      = NewError("ERR_LOCK_NOT_HELD", "upload is not locked", http.StatusNotFound)
+	ErrUploadNotActive                  = NewError("ERR_UPLOAD_NOT_ACTIVE", "upload is not being written to by this instance", http.StatusNotFound)
 
 	// These two responses are 500 for backwards compatability. Clients might receive a timeout response
 	// when the upload got interrupted. Most clients will not retry 4XX but only 5XX, so we responsd with 500 here.
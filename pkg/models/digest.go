@@ -0,0 +1,126 @@
+package models
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"sort"
+	"strings"
+)
+
+// DigestAlgorithms contains all algorithms which can be used for calculating the
+// digest of an entire upload. The keys are the names as registered for the Repr-Digest
+// header in the IANA "Hash Algorithms for HTTP Digest Fields" registry.
+// All hashes must implement encoding.BinaryMarshaler and encoding.BinaryUnmarshaler,
+// so their state can be persisted between requests.
+var DigestAlgorithms = map[string]func() hash.Hash{
+	"sha-256": sha256.New,
+	"md5":     md5.New,
+	"crc32c":  func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+}
+
+// DigestState holds the intermediate state of the digests for an upload that is
+// still in progress.
+type DigestState struct {
+	// Offset is the number of bytes that have been fed into the hashes. The state
+	// can only be continued if it matches the upload's offset.
+	Offset int64
+	// Hashes contains the marshalled state of each hash, keyed by algorithm.
+	Hashes map[string][]byte
+}
+
+// RestoreDigestHashes constructs the hashes for the given algorithms and restores
+// their state, so that they can be continued at the given offset. If the state does
+// not belong to this offset, the digests cannot be calculated anymore and nil is
+// returned. A nil state is only accepted at offset 0.
+func RestoreDigestHashes(algorithms []string, state *DigestState, offset int64) (map[string]hash.Hash, error) {
+	if state == nil && offset != 0 {
+		return nil, nil
+	}
+	if state != nil && state.Offset != offset {
+		return nil, nil
+	}
+
+	hashes := make(map[string]hash.Hash, len(algorithms))
+	for _, algorithm := range algorithms {
+		newHash, ok := DigestAlgorithms[algorithm]
+		if !ok {
+			return nil, fmt.Errorf("unsupported digest algorithm: %s", algorithm)
+		}
+		h := newHash()
+
+		if state != nil {
+			data, ok := state.Hashes[algorithm]
+			if !ok {
+				// The algorithm was not enabled when the upload was started.
+				return nil, nil
+			}
+
+			unmarshaler, ok := h.(encoding.BinaryUnmarshaler)
+			if !ok {
+				return nil, fmt.Errorf("digest algorithm %s cannot be restored", algorithm)
+			}
+			if err := unmarshaler.UnmarshalBinary(data); err != nil {
+				return nil, err
+			}
+		}
+
+		hashes[algorithm] = h
+	}
+
+	return hashes, nil
+}
+
+// NewDigestState captures the state of the provided hashes at the given offset.
+func NewDigestState(hashes map[string]hash.Hash, offset int64) (*DigestState, error) {
+	state := &DigestState{
+		Offset: offset,
+		Hashes: make(map[string][]byte, len(hashes)),
+	}
+
+	for algorithm, h := range hashes {
+		marshaler, ok := h.(encoding.BinaryMarshaler)
+		if !ok {
+			return nil, fmt.Errorf("digest algorithm %s cannot be persisted", algorithm)
+		}
+
+		data, err := marshaler.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		state.Hashes[algorithm] = data
+	}
+
+	return state, nil
+}
+
+// DigestSums returns the Base64-encoded sums of the provided hashes.
+func DigestSums(hashes map[string]hash.Hash) map[string]string {
+	digests := make(map[string]string, len(hashes))
+	for algorithm, h := range hashes {
+		digests[algorithm] = base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+
+	return digests
+}
+
+// SerializeReprDigestHeader serializes the digests into the format used by the
+// Repr-Digest header (RFC 9530), e.g. sha-256=:d435Qo+nKZ+gLcUHn7GQtQ72hiBVAgqoLsZnZPiTGPk=:
+func SerializeReprDigestHeader(digests map[string]string) string {
+	algorithms := make([]string, 0, len(digests))
+	for algorithm := range digests {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+
+	values := make([]string, len(algorithms))
+	for i, algorithm := range algorithms {
+		values[i] = algorithm + "=:" + digests[algorithm] + ":"
+	}
+
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,67 @@
+package models
+
+// DirectUploadState holds the state of an upload whose data is uploaded by the client
+// directly to the storage backend. The data is split into parts of PartSize bytes,
+// numbered starting at 1, where only the last part may be smaller.
+type DirectUploadState struct {
+	// PartSize is the size of all parts except the last one.
+	PartSize int64
+	// Parts contains the ETags of the parts, which have been completed by the client,
+	// keyed by their part number.
+	Parts map[int32]string
+}
+
+// PresignedPart describes a part of a direct upload and the URL to upload it to.
+type PresignedPart struct {
+	PartNumber int32
+	// Offset is the position of the part's first byte in the upload.
+	Offset int64
+	// Size is the exact number of bytes which must be uploaded for this part.
+	Size int64
+	// URL accepts the part's data in a PUT request without further authentication.
+	URL string
+}
+
+// UploadedPart identifies a part, which the client has uploaded directly to the
+// storage backend, by the ETag returned from the storage backend.
+type UploadedPart struct {
+	PartNumber int32
+	ETag       string
+}
+
+// NumParts returns the number of parts for an upload of the given size.
+func (state DirectUploadState) NumParts(size int64) int32 {
+	if size <= 0 {
+		return 1
+	}
+	return int32((size + state.PartSize - 1) / state.PartSize)
+}
+
+// PartRange returns the offset and size of the part with the given number in an
+// upload of the given size. If the part number is out of range, ok is false.
+func (state DirectUploadState) PartRange(number int32, size int64) (offset int64, partSize int64, ok bool) {
+	if number < 1 || number > state.NumParts(size) {
+		return 0, 0, false
+	}
+
+	offset = int64(number-1) * state.PartSize
+	partSize = state.PartSize
+	if offset+partSize > size {
+		partSize = size - offset
+	}
+	return offset, partSize, true
+}
+
+// Offset returns the number of contiguous bytes from the beginning of an upload of
+// the given size, which are covered by completed parts.
+func (state DirectUploadState) Offset(size int64) int64 {
+	offset := int64(0)
+	for number := int32(1); number <= state.NumParts(size); number++ {
+		if _, ok := state.Parts[number]; !ok {
+			break
+		}
+		_, partSize, _ := state.PartRange(number, size)
+		offset += partSize
+	}
+	return offset
+}
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/exp/slices"
+)
+
+// DefaultEventBufferSize is the number of events buffered for a subscription to the
+// EventBus, if no buffer size is specified.
+const DefaultEventBufferSize = 100
+
+// EventType describes what happened to an upload.
+type EventType string
+
+const (
+	// EventUploadCreated is published after an upload has been created.
+	EventUploadCreated EventType = "created"
+	// EventUploadProgress is published regularly while data is received for an
+	// upload, see Config.UploadProgressInterval.
+	EventUploadProgress EventType = "progress"
+	// EventUploadFinished is published after all data of an upload has been received.
+	EventUploadFinished EventType = "finished"
+	// EventUploadTerminated is published after an upload has been terminated, either
+	// by a client, an operator or because it expired.
+	EventUploadTerminated EventType = "terminated"
+)
+
+// Event is delivered to the subscribers of an EventBus.
+type Event struct {
+	Type EventType
+	HookEvent
+}
+
+// DeliveryPolicy controls what happens if an event is published while the buffer
+// of a subscription is full.
+type DeliveryPolicy int
+
+const (
+	// DeliveryDrop discards the event for the subscription, so that the publisher,
+	// usually a request, is not delayed. The dropped event is counted in
+	// Metrics.EventsDropped.
+	DeliveryDrop DeliveryPolicy = iota
+	// DeliveryBlock waits until there is room in the buffer, which delays the
+	// publisher until the subscriber catches up.
+	DeliveryBlock
+)
+
+// SubscriptionOptions configures a subscription to an EventBus.
+type SubscriptionOptions struct {
+	// Name identifies the subscriber in the metrics.
+	Name string
+	// Types limits the subscription to the given event types. If empty, all events
+	// are delivered.
+	Types []EventType
+	// BufferSize is the number of events, which can be buffered for the subscriber.
+	// Defaults to DefaultEventBufferSize.
+	BufferSize int
+	// Policy controls what happens if the buffer is full. Defaults to DeliveryDrop.
+	Policy DeliveryPolicy
+}
+
+// EventBus delivers events about uploads to any number of subscribers. Every
+// subscriber has its own buffer, so a slow subscriber does not affect the others,
+// unless it uses DeliveryBlock.
+type EventBus struct {
+	metrics Metrics
+
+	subscriptions map[*Subscription]struct{}
+	mutex         sync.RWMutex
+}
+
+// NewEventBus creates an event bus, which counts dropped events in the metrics.
+func NewEventBus(metrics Metrics) *EventBus {
+	return &EventBus{
+		metrics:       metrics,
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives events from an EventBus.
+type Subscription struct {
+	bus     *EventBus
+	options SubscriptionOptions
+	events  chan Event
+	dropped *uint64
+
+	// done is closed when unsubscribing to wake up blocked publishers. closed is set
+	// afterwards, once no publisher is sending to events anymore.
+	done   chan struct{}
+	closed bool
+	mutex  sync.RWMutex
+	once   sync.Once
+}
+
+// Subscribe registers a new subscriber. Its events must be consumed using
+// Subscription.Events until Unsubscribe is called.
+func (bus *EventBus) Subscribe(options SubscriptionOptions) *Subscription {
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultEventBufferSize
+	}
+
+	sub := &Subscription{
+		bus:     bus,
+		options: options,
+		events:  make(chan Event, options.BufferSize),
+		dropped: new(uint64),
+		done:    make(chan struct{}),
+	}
+
+	bus.mutex.Lock()
+	bus.subscriptions[sub] = struct{}{}
+	bus.mutex.Unlock()
+
+	return sub
+}
+
+// HasSubscribers returns whether any subscriber is interested in events of the
+// given type. It allows publishers to skip preparing events nobody receives.
+func (bus *EventBus) HasSubscribers(typ EventType) bool {
+	bus.mutex.RLock()
+	defer bus.mutex.RUnlock()
+
+	for sub := range bus.subscriptions {
+		if sub.wants(typ) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Publish delivers the event to all subscribers interested in its type, according
+// to their delivery policies.
+func (bus *EventBus) Publish(typ EventType, event HookEvent) {
+	bus.mutex.RLock()
+	subs := make([]*Subscription, 0, len(bus.subscriptions))
+	for sub := range bus.subscriptions {
+		if sub.wants(typ) {
+			subs = append(subs, sub)
+		}
+	}
+	bus.mutex.RUnlock()
+
+	for _, sub := range subs {
+		sub.deliver(Event{
+			Type:      typ,
+			HookEvent: event,
+		})
+	}
+}
+
+// Events returns the channel, on which the events are delivered. It is closed after
+// Unsubscribe has been called.
+func (sub *Subscription) Events() <-chan Event {
+	return sub.events
+}
+
+// Dropped returns the number of events, which have been dropped for this subscriber.
+func (sub *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(sub.dropped)
+}
+
+// Unsubscribe stops the delivery of events and closes the events channel. Events,
+// which are still buffered, can be consumed afterwards.
+func (sub *Subscription) Unsubscribe() {
+	sub.once.Do(func() {
+		sub.bus.mutex.Lock()
+		delete(sub.bus.subscriptions, sub)
+		sub.bus.mutex.Unlock()
+
+		close(sub.done)
+
+		sub.mutex.Lock()
+		sub.closed = true
+		close(sub.events)
+		sub.mutex.Unlock()
+	})
+}
+
+func (sub *Subscription) wants(typ EventType) bool {
+	return len(sub.options.Types) == 0 || slices.Contains(sub.options.Types, typ)
+}
+
+func (sub *Subscription) deliver(event Event) {
+	sub.mutex.RLock()
+	defer sub.mutex.RUnlock()
+
+	if sub.closed {
+		return
+	}
+
+	if sub.options.Policy == DeliveryBlock {
+		select {
+		case sub.events <- event:
+		case <-sub.done:
+		}
+		return
+	}
+
+	select {
+	case sub.events <- event:
+	default:
+		atomic.AddUint64(sub.dropped, 1)
+		sub.bus.metrics.IncEventsDropped(sub.options.Name, event.Type)
+	}
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	assert := assert.New(t)
+	bus := NewEventBus(NewMetrics())
+
+	all := bus.Subscribe(SubscriptionOptions{Name: "all"})
+	finished := bus.Subscribe(SubscriptionOptions{Name: "finished", Types: []EventType{EventUploadFinished}})
+
+	assert.True(bus.HasSubscribers(EventUploadCreated))
+	assert.True(bus.HasSubscribers(EventUploadFinished))
+
+	bus.Publish(EventUploadCreated, HookEvent{Upload: FileInfo{ID: "a"}})
+	bus.Publish(EventUploadFinished, HookEvent{Upload: FileInfo{ID: "a"}})
+
+	event := <-all.Events()
+	assert.Equal(EventUploadCreated, event.Type)
+	assert.Equal("a", event.Upload.ID)
+	event = <-all.Events()
+	assert.Equal(EventUploadFinished, event.Type)
+	event = <-finished.Events()
+	assert.Equal(EventUploadFinished, event.Type)
+
+	all.Unsubscribe()
+	all.Unsubscribe()
+	_, ok := <-all.Events()
+	assert.False(ok)
+	assert.False(bus.HasSubscribers(EventUploadCreated))
+
+	// Publishing after unsubscribing does not panic.
+	bus.Publish(EventUploadFinished, HookEvent{})
+	finished.Unsubscribe()
+	bus.Publish(EventUploadFinished, HookEvent{})
+}
+
+func TestEventBusDeliveryDrop(t *testing.T) {
+	assert := assert.New(t)
+	metrics := NewMetrics()
+	bus := NewEventBus(metrics)
+
+	sub := bus.Subscribe(SubscriptionOptions{Name: "slow", BufferSize: 2, Policy: DeliveryDrop})
+	for i := 0; i < 5; i++ {
+		bus.Publish(EventUploadProgress, HookEvent{})
+	}
+
+	assert.Len(sub.Events(), 2)
+	assert.EqualValues(3, sub.Dropped())
+	assert.EqualValues(3, *metrics.EventsDropped.Load()[EventsDroppedMapEntry{Subscriber: "slow", Type: EventUploadProgress}])
+}
+
+func TestEventBusDeliveryBlock(t *testing.T) {
+	assert := assert.New(t)
+	bus := NewEventBus(NewMetrics())
+
+	sub := bus.Subscribe(SubscriptionOptions{Name: "slow", BufferSize: 1, Policy: DeliveryBlock})
+	bus.Publish(EventUploadCreated, HookEvent{})
+
+	published := make(chan struct{})
+	go func() {
+		bus.Publish(EventUploadCreated, HookEvent{})
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("publisher has not been blocked by the full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	<-sub.Events()
+	<-published
+	assert.EqualValues(0, sub.Dropped())
+
+	// Unsubscribing wakes up blocked publishers.
+	blocked := make(chan struct{})
+	go func() {
+		bus.Publish(EventUploadCreated, HookEvent{})
+		close(blocked)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	sub.Unsubscribe()
+	<-blocked
+}
@@ -9,6 +9,7 @@ package models
 
 import (
 	"context"
+	"encoding/json"
 )
 
 // HookEvent represents an event from tusd which can be handled by the application.
@@ -35,6 +36,17 @@ type HookEvent struct {
 	HTTPRequest HTTPRequest
 }
 
+// MarshalJSON omits the upload's DigestState, which is only needed by tusd itself, from
+// the payloads sent to hooks.
+func (event HookEvent) MarshalJSON() ([]byte, error) {
+	// The conversion to another type prevents an infinite recursion.
+	type hookEvent HookEvent
+	e := hookEvent(event)
+	e.Upload.DigestState = nil
+
+	return json.Marshal(e)
+}
+
 func NewHookEvent(c *HttpContext, info FileInfo) HookEvent {
 	// The Host header field is not present in the header map, see https://pkg.go.dev/net/http#Request:
 	// > For incoming requests, the Host header is promoted to the
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHookEventOmitsDigestState(t *testing.T) {
+	assert := assert.New(t)
+	event := HookEvent{Upload: FileInfo{
+		ID:          "upload",
+		Digests:     map[string]string{"md5": "XrY7u+Ae7tCTyyK7j1rNww=="},
+		DigestState: &DigestState{Offset: 11},
+	}}
+
+	data, err := json.Marshal(event)
+	assert.NoError(err)
+	assert.NotContains(string(data), "DigestState")
+	assert.Contains(string(data), `"Digests":{"md5":"XrY7u+Ae7tCTyyK7j1rNww=="}`)
+	// The event itself is not modified.
+	assert.NotNil(event.Upload.DigestState)
+}
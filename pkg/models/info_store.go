@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// InfoStore persists the information about uploads, i.e. their FileInfo, on behalf of
+// a data store. Data stores, which support it, keep the information next to the upload's
+// data by default, but can be configured to use another InfoStore, for example one backed
+// by a database, so that no additional objects or files are created.
+//
+// The IDs passed to an InfoStore are chosen by the data store and do not necessarily
+// equal the upload IDs.
+type InfoStore interface {
+	// GetInfo returns the stored information about the upload and its version, which
+	// must be passed to PutInfo when replacing the information. The returned info's
+	// ModifiedAt is the time at which the information was last stored. ErrNotFound is
+	// returned if no information is stored for the upload.
+	GetInfo(ctx context.Context, id string) (info FileInfo, version string, err error)
+	// PutInfo stores the information about the upload, if the stored information still
+	// has the given version. An empty version requires that no information is stored yet.
+	// Otherwise, ErrInfoConflict is returned and nothing is changed. On success, the new
+	// version of the information is returned.
+	PutInfo(ctx context.Context, id string, info FileInfo, version string) (newVersion string, err error)
+	// DeleteInfo removes the information about the upload. It does not fail if no
+	// information is stored.
+	DeleteInfo(ctx context.Context, id string) error
+	// ListInfos returns the IDs of all uploads, for which information is stored.
+	ListInfos(ctx context.Context) ([]string, error)
+}
+
+// InfoMigrationOptions controls the behavior of MigrateInfos.
+type InfoMigrationOptions struct {
+	// DeleteSource removes the information from the source InfoStore once it has been
+	// stored in the destination.
+	DeleteSource bool
+	// DryRun instructs MigrateInfos to only report which information would be migrated
+	// without changing either InfoStore.
+	DryRun bool
+}
+
+// InfoMigrationReport describes the outcome of MigrateInfos.
+type InfoMigrationReport struct {
+	// Migrated contains the IDs of the uploads, whose information has been stored in
+	// the destination.
+	Migrated []string
+	// Skipped contains the IDs of the uploads, whose information already exists in the
+	// destination. It has not been changed in either InfoStore.
+	Skipped []string
+}
+
+// MigrateInfos copies the information about all uploads from one InfoStore to another,
+// for example when a data store is switched to a different InfoStore. It should be run
+// while no uploads are being created or modified. A failure for one upload does not
+// prevent the others from being migrated, and uploads which already exist in the
+// destination are skipped, so the migration can be repeated until it succeeds.
+//
+// No executable is provided for the migration, since only the embedding application
+// knows how its data stores are configured, e.g. their clients, buckets and prefixes.
+// Applications call MigrateInfos from their own maintenance command using the stores'
+// NewInfoStore methods, see the example of FileStore.NewInfoStore.
+func MigrateInfos(ctx context.Context, from InfoStore, to InfoStore, options InfoMigrationOptions) (InfoMigrationReport, error) {
+	var report InfoMigrationReport
+
+	ids, err := from.ListInfos(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	var errs []error
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return report, errors.Join(append(errs, err)...)
+		}
+
+		info, _, err := from.GetInfo(ctx, id)
+		if err != nil {
+			// The upload might have been finished or removed in the meantime.
+			if !errors.Is(err, ErrNotFound) {
+				errs = append(errs, fmt.Errorf("models: unable to read information about upload %s: %w", id, err))
+			}
+			continue
+		}
+
+		if _, _, err := to.GetInfo(ctx, id); err == nil {
+			report.Skipped = append(report.Skipped, id)
+			continue
+		} else if !errors.Is(err, ErrNotFound) {
+			errs = append(errs, fmt.Errorf("models: unable to read information about upload %s: %w", id, err))
+			continue
+		}
+
+		if !options.DryRun {
+			if _, err := to.PutInfo(ctx, id, info, ""); err != nil {
+				if errors.Is(err, ErrInfoConflict) {
+					report.Skipped = append(report.Skipped, id)
+				} else {
+					errs = append(errs, fmt.Errorf("models: unable to store information about upload %s: %w", id, err))
+				}
+				continue
+			}
+
+			if options.DeleteSource {
+				if err := from.DeleteInfo(ctx, id); err != nil {
+					errs = append(errs, fmt.Errorf("models: unable to delete information about upload %s: %w", id, err))
+				}
+			}
+		}
+
+		report.Migrated = append(report.Migrated, id)
+	}
+
+	return report, errors.Join(errs...)
+}
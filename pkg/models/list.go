@@ -0,0 +1,97 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultListLimit is the number of uploads returned by ListerDataStore.ListUploads
+// if no limit is specified.
+const DefaultListLimit = 100
+
+// UploadState describes the progress of an upload, by which listings can be filtered.
+type UploadState string
+
+const (
+	// UploadStateInProgress matches uploads, which have not received all data yet and
+	// have not expired.
+	UploadStateInProgress UploadState = "in-progress"
+	// UploadStateFinished matches uploads, which have received all data.
+	UploadStateFinished UploadState = "finished"
+	// UploadStateExpired matches unfinished uploads, whose expiration date has passed.
+	UploadStateExpired UploadState = "expired"
+)
+
+// State returns the state of the upload at the given time.
+func (f FileInfo) State(now time.Time) UploadState {
+	switch {
+	case !f.SizeIsDeferred && f.Offset == f.Size:
+		return UploadStateFinished
+	case f.IsExpired(now):
+		return UploadStateExpired
+	default:
+		return UploadStateInProgress
+	}
+}
+
+// ListUploadsOptions controls which uploads are returned by ListerDataStore.ListUploads.
+// Filters with zero values are ignored.
+type ListUploadsOptions struct {
+	// State only matches uploads in this state.
+	State UploadState
+	// Prefix only matches uploads, whose ID starts with it.
+	Prefix string
+	// MetaDataKey only matches uploads, which have metadata with this key. If
+	// MetaDataValue is set as well, the metadata's value must equal it.
+	MetaDataKey   string
+	MetaDataValue string
+	// CreatedBefore only matches uploads, which have been created before this time.
+	// Uploads with an unknown creation time do not match.
+	CreatedBefore time.Time
+	// Cursor continues a previous listing, using its ListUploadsResult.NextCursor.
+	Cursor string
+	// Limit is the maximum number of uploads returned at once. Defaults to
+	// DefaultListLimit.
+	Limit int
+}
+
+// ListUploadsResult is a page of uploads returned by ListerDataStore.ListUploads.
+type ListUploadsResult struct {
+	Uploads []FileInfo
+	// NextCursor is set if more uploads may exist. It is passed in
+	// ListUploadsOptions.Cursor to retrieve them.
+	NextCursor string
+}
+
+// Matches returns whether the upload matches all filters in the options at the given
+// time. Cursor and Limit are not considered.
+func (options ListUploadsOptions) Matches(info FileInfo, now time.Time) bool {
+	if options.State != "" && info.State(now) != options.State {
+		return false
+	}
+
+	if !strings.HasPrefix(info.ID, options.Prefix) {
+		return false
+	}
+
+	if options.MetaDataKey != "" {
+		value, ok := info.MetaData[options.MetaDataKey]
+		if !ok || (options.MetaDataValue != "" && value != options.MetaDataValue) {
+			return false
+		}
+	}
+
+	if !options.CreatedBefore.IsZero() && (info.CreatedAt.IsZero() || !info.CreatedAt.Before(options.CreatedBefore)) {
+		return false
+	}
+
+	return true
+}
+
+// PageLimit returns the maximum number of uploads to return for the options.
+func (options ListUploadsOptions) PageLimit() int {
+	if options.Limit <= 0 {
+		return DefaultListLimit
+	}
+	return options.Limit
+}
@@ -26,6 +26,9 @@ type Metrics struct {
 	UploadsFinished   *uint64
 	UploadsCreated    *uint64
 	UploadsTerminated *uint64
+	// EventsDropped counts the events, which have been dropped by the EventBus
+	// because a subscriber's buffer was full, per subscriber and event type.
+	EventsDropped *EventsDroppedMap
 }
 
 // incRequestsTotal increases the counter for this request method atomically by
@@ -63,6 +66,16 @@ func (m Metrics) IncUploadsTerminated() {
 	atomic.AddUint64(m.UploadsTerminated, 1)
 }
 
+// IncEventsDropped increases the counter for dropped events of the subscriber
+// atomically by one.
+func (m Metrics) IncEventsDropped(subscriber string, typ EventType) {
+	ptr := m.EventsDropped.retrievePointerFor(EventsDroppedMapEntry{
+		Subscriber: subscriber,
+		Type:       typ,
+	})
+	atomic.AddUint64(ptr, 1)
+}
+
 func NewMetrics() Metrics {
 	return Metrics{
 		RequestsTotal: map[string]*uint64{
@@ -78,6 +91,7 @@ func NewMetrics() Metrics {
 		UploadsFinished:   new(uint64),
 		UploadsCreated:    new(uint64),
 		UploadsTerminated: new(uint64),
+		EventsDropped:     newEventsDroppedMap(),
 	}
 }
 
@@ -137,3 +151,52 @@ func (e *ErrorsTotalMap) Load() map[ErrorsTotalMapEntry]*uint64 {
 
 	return m
 }
+
+// EventsDroppedMap stores the counters for the dropped events.
+type EventsDroppedMap struct {
+	lock    sync.RWMutex
+	counter map[EventsDroppedMapEntry]*uint64
+}
+
+type EventsDroppedMapEntry struct {
+	Subscriber string
+	Type       EventType
+}
+
+func newEventsDroppedMap() *EventsDroppedMap {
+	return &EventsDroppedMap{
+		counter: make(map[EventsDroppedMapEntry]*uint64),
+	}
+}
+
+// retrievePointerFor returns (after creating it if necessary) the pointer to
+// the counter for the entry.
+func (e *EventsDroppedMap) retrievePointerFor(entry EventsDroppedMapEntry) *uint64 {
+	e.lock.RLock()
+	ptr, ok := e.counter[entry]
+	e.lock.RUnlock()
+	if ok {
+		return ptr
+	}
+
+	e.lock.Lock()
+	if ptr, ok = e.counter[entry]; !ok {
+		ptr = new(uint64)
+		e.counter[entry] = ptr
+	}
+	e.lock.Unlock()
+
+	return ptr
+}
+
+// Load retrieves the map of the counter pointers atomically
+func (e *EventsDroppedMap) Load() map[EventsDroppedMapEntry]*uint64 {
+	e.lock.RLock()
+	m := make(map[EventsDroppedMapEntry]*uint64, len(e.counter))
+	for entry, ptr := range e.counter {
+		m[entry] = ptr
+	}
+	e.lock.RUnlock()
+
+	return m
+}
@@ -41,6 +41,10 @@ var (
 		"tusd_uploads_terminated",
 		"Number of terminated uploads.",
 		nil, nil)
+	eventsDroppedDesc = prometheus.NewDesc(
+		"tusd_events_dropped_total",
+		"Number of events dropped because a subscriber's buffer was full.",
+		[]string{"subscriber", "type"}, nil)
 )
 
 type Collector struct {
@@ -61,6 +65,7 @@ func (Collector) Describe(descs chan<- *prometheus.Desc) {
 	descs <- uploadsCreatedDesc
 	descs <- uploadsFinishedDesc
 	descs <- uploadsTerminatedDesc
+	descs <- eventsDroppedDesc
 }
 
 func (c Collector) Collect(metrics chan<- prometheus.Metric) {
@@ -106,4 +111,14 @@ func (c Collector) Collect(metrics chan<- prometheus.Metric) {
 		prometheus.CounterValue,
 		float64(atomic.LoadUint64(c.metrics.UploadsTerminated)),
 	)
+
+	for entry, valuePtr := range c.metrics.EventsDropped.Load() {
+		metrics <- prometheus.MustNewConstMetric(
+			eventsDroppedDesc,
+			prometheus.CounterValue,
+			float64(atomic.LoadUint64(valuePtr)),
+			entry.Subscriber,
+			string(entry.Type),
+		)
+	}
 }
@@ -9,9 +9,16 @@
 //	s3:AbortMultipartUpload
 //	s3:DeleteObject
 //	s3:GetObject
+//	s3:ListBucket
 //	s3:ListMultipartUploadParts
 //	s3:PutObject
 //
+// The s3:ListBucket permission is only required if expired uploads should be
+// removed using ListExpiredUploads. Removing orphaned uploads using CollectGarbage
+// requires s3:ListBucket and s3:ListBucketMultipartUploads. If the digests of
+// uploads are calculated, s3:PutObjectTagging and s3:GetObjectTagging are required
+// as well.
+//
 // While this package uses the official AWS SDK for Go, S3Store is able
 // to work with any S3-compatible service such as MinIO. In order to change
 // the HTTP endpoint used for sending requests to, adjust the `BaseEndpoint`
@@ -23,7 +30,11 @@
 //
 // First of all, a new info object is stored which contains a JSON-encoded blob
 // of general information about the upload including its size and meta data.
-// This kind of objects have the suffix ".info" in their key.
+// This kind of objects have the suffix ".info" in their key. Alternatively, the
+// information can be stored in the metadata of a placeholder object under the
+// upload's final key (see InfoLocationMetadata) or in any other models.InfoStore,
+// such as a database (see S3Store.InfoStore), so that no additional objects are
+// created. Existing uploads can be moved between these using models.MigrateInfos.
 //
 // In addition a new multipart upload
 // (http://docs.aws.amazon.com/AmazonS3/latest/dev/uploadobjusingmpu.html) is
@@ -33,23 +44,52 @@
 // If meta data is associated with the upload during creation, it will be added
 // to the multipart upload and after finishing it, the meta data will be passed
 // to the final object. However, the metadata which will be attached to the
-// final object can only contain ASCII characters and every non-ASCII character
-// will be replaced by a question mark (for example, "Menü" will be "Men?").
-// However, this does not apply for the metadata returned by the GetInfo
-// function since it relies on the info object for reading the metadata.
-// Therefore, HEAD responses will always contain the unchanged metadata, Base64-
-// encoded, even if it contains non-ASCII characters.
+// final object can only contain ASCII characters. By default, every non-ASCII
+// character will be replaced by a question mark (for example, "Menü" will be
+// "Men?"). Setting S3Store.MetadataEncoding to MetadataEncodingRFC2047 or
+// MetadataEncodingBase64 stores such values in a reversible encoding instead,
+// which can be decoded using DecodeMetadataValue. Be aware that S3 limits the
+// total size of an object's metadata to 2KB, which encoded values reach sooner.
+// While the upload is in progress, the metadata returned by the GetInfo function
+// is read from the info object and thus always unchanged.
 //
 // Once the upload is finished, the multipart upload is completed, resulting in
-// the entire file being stored in the bucket. The info object, containing
-// meta data is not deleted. It is recommended to copy the finished upload to
-// another bucket to avoid it being deleted by the Termination extension.
+// the entire file being stored in the bucket. The info object is deleted
+// afterwards, so GetInfo then constructs the upload's information from the final
+// object, including its decoded metadata. Only objects carrying the upload's object
+// ID in their metadata, which is added when creating the multipart upload, are
+// treated as finished uploads. Since S3 lowercases metadata keys, the keys are
+// lowercase in this case, and properties like whether the upload is partial are
+// not available anymore. The digests of the upload, if calculated, are stored in
+// the final object's tags instead. It is recommended to copy the
+// finished upload to another bucket to avoid it being deleted by the Termination
+// extension.
+//
+// Unfinished uploads can only be downloaded while no part has been uploaded to the
+// multipart upload yet and all data is in the incomplete part object, since S3 does
+// not provide access to the parts of a multipart upload before it is completed.
 //
 // If an upload is about to being terminated, the multipart upload is aborted
 // which removes all of the uploaded parts from the bucket. In addition, the
 // info object is also deleted. If the upload has been finished already, the
 // finished object containing the entire upload is also removed.
 //
+// Alternatively, clients can upload the parts directly to S3 using presigned
+// UploadPart URLs (see PresignUploadParts) and report their ETags afterwards. In
+// this case, the upload's offset is stored in the info object and only covers the
+// reported parts at the beginning of the upload.
+//
+// The info object is written using conditional requests: It is only created if it
+// does not exist yet (If-None-Match: *) and only replaced if it has not changed since
+// it was read (If-Match). If another instance has modified the information in the
+// meantime, models.ErrInfoConflict is returned instead of overwriting its changes, and
+// the request can be retried. Other InfoStores provide the same guarantee. For S3-compatible services, which do not support
+// conditional writes, this can be disabled using S3Store.DisableConditionalInfoWrites.
+//
+// If tusd is interrupted while creating, finishing or terminating an upload,
+// multipart uploads, .info or .part objects may be left behind. These can be
+// removed using CollectGarbage, for example in a periodic maintenance job.
+//
 // # Considerations
 //
 // In order to support tus' principle of resumable upload, S3's Multipart-Uploads
@@ -60,7 +100,8 @@
 // and to allow the AWS SDK to calculate a checksum. Once the part has been uploaded
 // to S3, the temporary file will be removed immediately. Therefore, please
 // ensure that the server running this storage backend has enough disk space
-// available to hold these caches.
+// available to hold these caches. Alternatively, the parts can be buffered in
+// memory by setting S3Store.MemoryArena, whose limit caps the memory used for this.
 //
 // In addition, it must be mentioned that AWS S3 only offers eventual
 // consistency (https://docs.aws.amazon.com/AmazonS3/latest/dev/Introduction.html#ConsistencyModel).
@@ -72,7 +113,6 @@ package s3store
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -90,9 +130,11 @@ import (
 	"golang.org/x/exp/slices"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
 )
 
 // This regular expression matches every character which is not
@@ -135,8 +177,22 @@ type S3Store struct {
 	// S3. S3Store will attempt to slice the incoming data into parts with this
 	// size whenever possible. In some cases, smaller parts are necessary, so
 	// not every part may reach this value. The PreferredPartSize must be inside the
-	// range of MinPartSize to MaxPartSize.
+	// range of MinPartSize to MaxPartSize. With adaptive part sizing, it is only
+	// used until the client's throughput is known.
 	PreferredPartSize int64
+	// DisableAdaptivePartSizing instructs S3Store to use the same part size for all
+	// parts of an upload, which only depends on the upload's size and PreferredPartSize.
+	// By default, the part size adapts to the client's throughput, the data uploaded
+	// so far and the free memory or disk space. Part sizes then grow during large
+	// uploads, so that uploads with a deferred length can reach MaxObjectSize without
+	// exceeding MaxMultipartParts.
+	DisableAdaptivePartSizing bool
+	// TargetPartDuration is the duration in which the client should transmit a single
+	// part when using adaptive part sizing. Faster clients get larger parts, which
+	// reduces the number of requests to S3, while slower clients get smaller parts,
+	// which reduces the amount of data lost if a request is interrupted. A value of 0
+	// or less ignores the throughput. Defaults to 30 seconds.
+	TargetPartDuration time.Duration
 	// MaxMultipartParts is the maximum number of parts an S3 multipart upload is
 	// allowed to have according to AWS S3 API specifications.
 	// See: http://docs.aws.amazon.com/AmazonS3/latest/dev/qfacts.html
@@ -153,12 +209,52 @@ type S3Store struct {
 	// on disk during the upload. An empty string ("", the default value) will
 	// cause S3Store to use the operating system's default temporary directory.
 	TemporaryDirectory string
+	// MemoryArena, if set, is used to buffer parts in memory instead of writing them
+	// to temporary files in TemporaryDirectory. The arena bounds the memory usage and
+	// can be shared between multiple stores. Parts which are larger than the arena's
+	// limit are still written to temporary files.
+	MemoryArena *MemoryArena
 	// DisableContentHashes instructs the S3Store to not calculate the MD5 and SHA256
 	// hashes when uploading data to S3. These hashes are used for file integrity checks
 	// and for authentication. However, these hashes also consume a significant amount of
 	// CPU, so it might be desirable to disable them.
 	// Note that this property is experimental and might be removed in the future!
 	DisableContentHashes bool
+	// MetadataEncoding controls how metadata values with characters, which are not
+	// printable ASCII, are stored on the final object. Defaults to
+	// MetadataEncodingReplace, which replaces these characters with question marks.
+	MetadataEncoding MetadataEncoding
+	// DisableConditionalInfoWrites writes the info object unconditionally, so concurrent
+	// modifications from other instances are silently overwritten. This is only necessary
+	// for S3-compatible services, which reject conditional PutObject requests.
+	DisableConditionalInfoWrites bool
+	// InfoLocation controls where the information about uploads is stored in the
+	// bucket. Defaults to InfoLocationSidecar, which creates an info object next to
+	// each upload.
+	InfoLocation InfoLocation
+	// InfoStore, if set, stores the information about uploads instead of the bucket,
+	// for example in a database. It is keyed by the uploads' object IDs and takes
+	// precedence over InfoLocation.
+	InfoStore models.InfoStore
+	// StreamPartUploads enables streaming the body of a PATCH request directly to S3
+	// without buffering it on disk or in memory. This is only done if the request
+	// declares a Content-Length, which is a valid part size on its own, and no incomplete
+	// part has to be prepended. All other requests are buffered as usual. Since the
+	// payload cannot be hashed before it is sent, it is uploaded unsigned.
+	// Be aware that if such a request is interrupted, no data from it is saved and the
+	// client has to retransmit the entire chunk.
+	StreamPartUploads bool
+	// StreamingChecksumAlgorithm, if set, is used to calculate a trailing checksum for
+	// streamed parts, so S3 can verify their integrity. Trailing checksums are only
+	// supported by the AWS SDK for HTTPS endpoints.
+	StreamingChecksumAlgorithm types.ChecksumAlgorithm
+	// DownloadConcurrency is the number of ranged GetObject requests which are used
+	// concurrently to download a finished upload in GetReader. A value of 1 or less
+	// downloads the object using a single request.
+	DownloadConcurrency int
+	// DownloadPartSize is the size of each range requested when DownloadConcurrency
+	// is larger than 1. Up to DownloadConcurrency ranges are buffered in memory.
+	DownloadPartSize int64
 
 	// uploadSemaphore limits the number of concurrent multipart part uploads to S3.
 	uploadSemaphore semaphore.Semaphore
@@ -169,6 +265,9 @@ type S3Store struct {
 	// diskWriteDurationMetric holds the prometheus instance for storing the time it takes to write chunks to disk.
 	diskWriteDurationMetric prometheus.Summary
 
+	// memoryWriteDurationMetric holds the prometheus instance for storing the time it takes to write chunks to memory.
+	memoryWriteDurationMetric prometheus.Summary
+
 	// uploadSemaphoreDemandMetric holds the prometheus instance for storing the demand on the upload semaphore
 	uploadSemaphoreDemandMetric prometheus.Gauge
 
@@ -185,9 +284,16 @@ const (
 	metricUploadPart              = "upload_part"
 	metricListParts               = "list_parts"
 	metricHeadPartObject          = "head_part_object"
+	metricHeadObject              = "head_object"
 	metricGetPartObject           = "get_part_object"
 	metricPutPartObject           = "put_part_object"
 	metricDeletePartObject        = "delete_part_object"
+	metricListObjects             = "list_objects"
+	metricListMultipartUploads    = "list_multipart_uploads"
+	metricAbortMultipartUpload    = "abort_multipart_upload"
+	metricDeleteObjects           = "delete_objects"
+	metricPutObjectTagging        = "put_object_tagging"
+	metricGetObjectTagging        = "get_object_tagging"
 )
 
 type S3API interface {
@@ -202,6 +308,10 @@ type S3API interface {
 	DeleteObjects(ctx context.Context, input *s3.DeleteObjectsInput, opt ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
 	CompleteMultipartUpload(ctx context.Context, input *s3.CompleteMultipartUploadInput, opt ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
 	UploadPartCopy(ctx context.Context, input *s3.UploadPartCopyInput, opt ...func(*s3.Options)) (*s3.UploadPartCopyOutput, error)
+	ListObjectsV2(ctx context.Context, input *s3.ListObjectsV2Input, opt ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	ListMultipartUploads(ctx context.Context, input *s3.ListMultipartUploadsInput, opt ...func(*s3.Options)) (*s3.ListMultipartUploadsOutput, error)
+	PutObjectTagging(ctx context.Context, input *s3.PutObjectTaggingInput, opt ...func(*s3.Options)) (*s3.PutObjectTaggingOutput, error)
+	GetObjectTagging(ctx context.Context, input *s3.GetObjectTaggingInput, opt ...func(*s3.Options)) (*s3.GetObjectTaggingOutput, error)
 }
 
 // New constructs a new storage using the supplied bucket and service object.
@@ -218,6 +328,12 @@ func New(bucket string, service S3API) S3Store {
 		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
 	})
 
+	memoryWriteDurationMetric := prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "tusd_s3_memory_write_duration_ms",
+		Help:       "Duration of chunk writes to memory in milliseconds",
+		Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+	})
+
 	uploadSemaphoreDemandMetric := prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "tusd_s3_upload_semaphore_demand",
 		Help: "Number of goroutines wanting to acquire the upload lock or having it acquired",
@@ -234,12 +350,16 @@ func New(bucket string, service S3API) S3Store {
 		MaxPartSize:                 5 * 1024 * 1024 * 1024,
 		MinPartSize:                 5 * 1024 * 1024,
 		PreferredPartSize:           50 * 1024 * 1024,
+		TargetPartDuration:          30 * time.Second,
 		MaxMultipartParts:           10000,
 		MaxObjectSize:               5 * 1024 * 1024 * 1024 * 1024,
 		MaxBufferedParts:            20,
 		TemporaryDirectory:          "",
+		DownloadConcurrency:         1,
+		DownloadPartSize:            16 * 1024 * 1024,
 		requestDurationMetric:       requestDurationMetric,
 		diskWriteDurationMetric:     diskWriteDurationMetric,
+		memoryWriteDurationMetric:   memoryWriteDurationMetric,
 		uploadSemaphoreDemandMetric: uploadSemaphoreDemandMetric,
 		uploadSemaphoreLimitMetric:  uploadSemaphoreLimitMetric,
 	}
@@ -261,11 +381,17 @@ func (store S3Store) UseIn(composer *models.StoreComposer) {
 	composer.UseTerminater(store)
 	composer.UseConcater(store)
 	composer.UseLengthDeferrer(store)
+	composer.UseChecksum(store)
+	composer.UseDigester(store)
+	composer.UseExpirer(store)
+	composer.UseDirectUploader(store)
+	composer.UseLister(store)
 }
 
 func (store S3Store) RegisterMetrics(registry prometheus.Registerer) {
 	registry.MustRegister(store.requestDurationMetric)
 	registry.MustRegister(store.diskWriteDurationMetric)
+	registry.MustRegister(store.memoryWriteDurationMetric)
 	registry.MustRegister(store.uploadSemaphoreDemandMetric)
 	registry.MustRegister(store.uploadSemaphoreLimitMetric)
 }
@@ -294,13 +420,39 @@ type s3Upload struct {
 	parts []*s3Part
 	// incompletePartSize is the size of an incomplete part object, if one exists. It will be 0 if info is nil as well.
 	incompletePartSize int64
+
+	// lastChunk describes the state of the upload before the most recent WriteChunk call.
+	// It is used by RollbackChunk and will be nil if no chunk has been written yet.
+	lastChunk *s3ChunkState
+
+	// infoVersion is the version of the stored information, as it was last read or
+	// written. It is empty if the information has not been read or does not exist.
+	infoVersion string
+}
+
+// s3ChunkState captures the state of an upload before a chunk is written, so that
+// the chunk can be rolled back afterwards.
+type s3ChunkState struct {
+	// numParts is the number of parts which existed before the chunk was written.
+	numParts int
+	// incompletePart is the content of the incomplete part object before the chunk
+	// was written. Since the incomplete part is smaller than MinPartSize, it can be
+	// kept in memory.
+	incompletePart []byte
+}
+
+// sizedReader is implemented by readers which know how many bytes they provide,
+// such as models.BodyReader.
+type sizedReader interface {
+	ContentLength() int64
 }
 
 // s3Part represents a single part of a S3 multipart upload.
 type s3Part struct {
-	number int32
-	size   int64
-	etag   string
+	number       int32
+	size         int64
+	etag         string
+	lastModified time.Time
 }
 
 func (store S3Store) NewUpload(ctx context.Context, info models.FileInfo) (models.Upload, error) {
@@ -317,11 +469,9 @@ func (store S3Store) NewUpload(ctx context.Context, info models.FileInfo) (model
 		objectId = info.ID
 	}
 
-	// Convert meta data into a map of pointers for AWS Go SDK, sigh.
-	metadata := make(map[string]string, len(info.MetaData))
-	for key, value := range info.MetaData {
-		metadata[key] = nonPrintableRegexp.ReplaceAllString(value, "?")
-	}
+	// S3 only accepts printable ASCII characters in meta data.
+	metadata := store.encodeMetadata(info.MetaData)
+	metadata[uploadIdMetadataKey] = objectId
 
 	// Create the actual multipart upload
 	t := time.Now()
@@ -344,8 +494,12 @@ func (store S3Store) NewUpload(ctx context.Context, info models.FileInfo) (model
 		"Key":    *store.keyWithPrefix(objectId),
 	}
 
-	upload := &s3Upload{objectId, multipartId, &store, nil, []*s3Part{}, 0}
+	upload := &s3Upload{objectId, multipartId, &store, nil, []*s3Part{}, 0, nil, ""}
 	err = upload.writeInfo(ctx, info)
+	if errors.Is(err, models.ErrInfoConflict) {
+		// An upload with the same ID exists already.
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("s3store: unable to create info file:\n%s", err)
 	}
@@ -360,7 +514,7 @@ func (store S3Store) GetUpload(ctx context.Context, id string) (models.Upload, e
 		return nil, models.ErrNotFound
 	}
 
-	return &s3Upload{objectId, multipartId, &store, nil, []*s3Part{}, 0}, nil
+	return &s3Upload{objectId, multipartId, &store, nil, []*s3Part{}, 0, nil, ""}, nil
 }
 
 func (store S3Store) AsTerminatableUpload(upload models.Upload) models.TerminatableUpload {
@@ -375,27 +529,35 @@ func (store S3Store) AsConcatableUpload(upload models.Upload) models.ConcatableU
 	return upload.(*s3Upload)
 }
 
-func (upload *s3Upload) writeInfo(ctx context.Context, info models.FileInfo) error {
-	store := upload.store
+func (store S3Store) AsRollbackableUpload(upload models.Upload) models.RollbackableUpload {
+	return upload.(*s3Upload)
+}
 
-	upload.info = &info
+func (store S3Store) AsDigestableUpload(upload models.Upload) models.DigestableUpload {
+	return upload.(*s3Upload)
+}
+
+func (store S3Store) AsDirectUploadableUpload(upload models.Upload) models.DirectUploadableUpload {
+	return upload.(*s3Upload)
+}
 
-	infoJson, err := json.Marshal(info)
+// writeInfo stores the info in the store's InfoStore. The information is only replaced
+// if it has not been modified since it was read by this upload, or only created if it
+// does not exist yet. Otherwise, models.ErrInfoConflict is returned and the info is
+// fetched again on the next call to GetInfo.
+func (upload *s3Upload) writeInfo(ctx context.Context, info models.FileInfo) error {
+	version, err := upload.store.infoStore().PutInfo(ctx, upload.objectId, info, upload.infoVersion)
 	if err != nil {
+		if errors.Is(err, models.ErrInfoConflict) {
+			upload.info = nil
+			upload.infoVersion = ""
+		}
 		return err
 	}
 
-	// Create object on S3 containing information about the file
-	t := time.Now()
-	_, err = store.Service.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:        aws.String(store.Bucket),
-		Key:           store.metadataKeyWithPrefix(upload.objectId + ".info"),
-		Body:          bytes.NewReader(infoJson),
-		ContentLength: aws.Int64(int64(len(infoJson))),
-	})
-	store.observeRequestDuration(t, metricPutInfoObject)
-
-	return err
+	upload.info = &info
+	upload.infoVersion = version
+	return nil
 }
 
 func (upload *s3Upload) WriteChunk(ctx context.Context, offset int64, src io.Reader) (int64, error) {
@@ -403,10 +565,29 @@ func (upload *s3Upload) WriteChunk(ctx context.Context, offset int64, src io.Rea
 
 	// Get the total size of the current upload, number of parts to generate next number and whether
 	// an incomplete part exists
-	_, _, incompletePartSize, err := upload.getInternalInfo(ctx)
+	info, _, incompletePartSize, err := upload.getInternalInfo(ctx)
 	if err != nil {
 		return 0, err
 	}
+	upload.trimEmptyParts()
+
+	chunk := &s3ChunkState{
+		numParts: len(upload.parts),
+	}
+	upload.lastChunk = chunk
+
+	if store.StreamPartUploads && incompletePartSize == 0 {
+		partSize, err := upload.streamablePartSize(info, offset, src)
+		if err != nil {
+			return 0, err
+		}
+
+		if partSize > 0 {
+			bytesUploaded, err := upload.streamPart(ctx, src, partSize)
+			upload.info.Offset += bytesUploaded
+			return bytesUploaded, err
+		}
+	}
 
 	if incompletePartSize > 0 {
 		incompletePartFile, err := store.downloadIncompletePartForUpload(ctx, upload.objectId)
@@ -418,6 +599,15 @@ func (upload *s3Upload) WriteChunk(ctx context.Context, offset int64, src io.Rea
 		}
 		defer cleanUpTempFile(incompletePartFile)
 
+		// Keep a copy of the incomplete part, so it can be restored in RollbackChunk.
+		chunk.incompletePart, err = io.ReadAll(incompletePartFile)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := incompletePartFile.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+
 		if err := store.deleteIncompletePartForUpload(ctx, upload.objectId); err != nil {
 			return 0, err
 		}
@@ -441,6 +631,136 @@ func (upload *s3Upload) WriteChunk(ctx context.Context, offset int64, src io.Rea
 	return bytesUploaded, err
 }
 
+// RollbackChunk discards the data from the most recent WriteChunk call. Since S3 does not
+// allow removing single parts from a multipart upload, the parts from the discarded chunk
+// are overwritten with empty parts instead. These do not contribute to the upload's offset
+// and are skipped when the multipart upload is completed. The next WriteChunk call uses
+// their part numbers again, so that rolled back chunks do not use up MaxMultipartParts.
+func (upload *s3Upload) RollbackChunk(ctx context.Context, offset int64) error {
+	store := upload.store
+
+	chunk := upload.lastChunk
+	if chunk == nil {
+		return nil
+	}
+	upload.lastChunk = nil
+
+	for _, part := range upload.parts[chunk.numParts:] {
+		t := time.Now()
+		res, err := store.Service.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(store.Bucket),
+			Key:        store.keyWithPrefix(upload.objectId),
+			UploadId:   aws.String(upload.multipartId),
+			PartNumber: aws.Int32(part.number),
+			Body:       bytes.NewReader([]byte{}),
+		})
+		store.observeRequestDuration(t, metricUploadPart)
+		if err != nil {
+			return err
+		}
+
+		part.etag = *res.ETag
+		part.size = 0
+	}
+
+	// Restore the incomplete part as it was before the chunk was written.
+	if len(chunk.incompletePart) > 0 {
+		if err := store.putIncompletePartForUpload(ctx, upload.objectId, bytes.NewReader(chunk.incompletePart)); err != nil {
+			return err
+		}
+	} else {
+		if err := store.deleteIncompletePartForUpload(ctx, upload.objectId); err != nil {
+			return err
+		}
+	}
+
+	upload.incompletePartSize = int64(len(chunk.incompletePart))
+	if upload.info != nil {
+		upload.info.Offset = offset
+	}
+
+	return nil
+}
+
+// trimEmptyParts removes the empty parts, which RollbackChunk leaves at the end of the
+// upload, from upload.parts. The following parts are then uploaded using their part
+// numbers and overwrite them.
+func (upload *s3Upload) trimEmptyParts() {
+	numParts := len(upload.parts)
+	for numParts > 0 && upload.parts[numParts-1].size == 0 {
+		numParts -= 1
+	}
+	upload.parts = upload.parts[:numParts]
+}
+
+// streamablePartSize returns the size of the chunk in src, if it can be uploaded as a single
+// part without buffering it. Otherwise, 0 is returned. A chunk can be streamed if its size
+// is known and it is either the final chunk or large enough to ensure that the upload does
+// not exceed MaxMultipartParts.
+func (upload *s3Upload) streamablePartSize(info models.FileInfo, offset int64, src io.Reader) (int64, error) {
+	store := upload.store
+
+	sized, ok := src.(sizedReader)
+	if !ok {
+		return 0, nil
+	}
+
+	size := sized.ContentLength()
+	if size <= 0 || size > store.MaxPartSize || int64(len(upload.parts)) >= store.MaxMultipartParts {
+		return 0, nil
+	}
+
+	sizer, err := store.newPartSizer(info, offset, len(upload.parts))
+	if err != nil {
+		return 0, err
+	}
+
+	// The chunk must be at least as large as a regular part would be at this point.
+	minimumPartSize := sizer.fixedSize
+	if minimumPartSize == 0 {
+		minimumPartSize = sizer.minimum()
+	}
+
+	isFinalChunk := !info.SizeIsDeferred && offset+size == info.Size
+	if !isFinalChunk && (size < minimumPartSize || size < store.MinPartSize) {
+		return 0, nil
+	}
+
+	return size, nil
+}
+
+// streamPart uploads the next size bytes from src directly as a new part.
+func (upload *s3Upload) streamPart(ctx context.Context, src io.Reader, size int64) (int64, error) {
+	store := upload.store
+
+	part := &s3Part{
+		etag:   "",
+		size:   size,
+		number: int32(len(upload.parts) + 1),
+	}
+
+	store.acquireUploadSemaphore()
+	defer store.releaseUploadSemaphore()
+
+	t := time.Now()
+	uploadPartInput := &s3.UploadPartInput{
+		Bucket:     aws.String(store.Bucket),
+		Key:        store.keyWithPrefix(upload.objectId),
+		UploadId:   aws.String(upload.multipartId),
+		PartNumber: aws.Int32(part.number),
+	}
+	etag, err := upload.putPartForUpload(ctx, uploadPartInput, io.LimitReader(src, size), size)
+	store.observeRequestDuration(t, metricUploadPart)
+	if err != nil {
+		return 0, err
+	}
+
+	part.etag = etag
+	upload.parts = append(upload.parts, part)
+
+	return size, nil
+}
+
 func (upload *s3Upload) uploadParts(ctx context.Context, offset int64, src io.Reader) (int64, error) {
 	store := upload.store
 
@@ -452,7 +772,7 @@ func (upload *s3Upload) uploadParts(ctx context.Context, offset int64, src io.Re
 
 	size := info.Size
 	bytesUploaded := int64(0)
-	optimalPartSize, err := store.calcOptimalPartSize(size)
+	sizer, err := store.newPartSizer(info, offset, len(parts))
 	if err != nil {
 		return 0, err
 	}
@@ -460,14 +780,14 @@ func (upload *s3Upload) uploadParts(ctx context.Context, offset int64, src io.Re
 	numParts := len(parts)
 	nextPartNum := int32(numParts + 1)
 
-	partProducer, fileChan := newS3PartProducer(src, store.MaxBufferedParts, store.TemporaryDirectory, store.diskWriteDurationMetric)
+	partProducer, fileChan := newS3PartProducer(src, store.MaxBufferedParts, store.TemporaryDirectory, store.MemoryArena, store.diskWriteDurationMetric, store.memoryWriteDurationMetric)
 
 	producerCtx, cancelProducer := context.WithCancel(ctx)
 	defer func() {
 		cancelProducer()
 		partProducer.closeUnreadFiles()
 	}()
-	go partProducer.produce(producerCtx, optimalPartSize)
+	go partProducer.produce(producerCtx, sizer)
 
 	var wg sync.WaitGroup
 	var uploadErr error
@@ -554,11 +874,21 @@ func cleanUpTempFile(file *os.File) {
 	os.Remove(file.Name())
 }
 
-func (upload *s3Upload) putPartForUpload(ctx context.Context, uploadPartInput *s3.UploadPartInput, file io.ReadSeeker, size int64) (string, error) {
+func (upload *s3Upload) putPartForUpload(ctx context.Context, uploadPartInput *s3.UploadPartInput, file io.Reader, size int64) (string, error) {
 	if !upload.store.DisableContentHashes {
 		// By default, use the traditional approach to upload data
 		uploadPartInput.Body = file
-		res, err := upload.store.Service.UploadPart(ctx, uploadPartInput)
+
+		var optFns []func(*s3.Options)
+		if _, ok := file.(io.Seeker); !ok {
+			// A streamed body cannot be read twice for calculating its hash before
+			// sending it, so it is sent as an unsigned payload with a known length.
+			uploadPartInput.ContentLength = aws.Int64(size)
+			uploadPartInput.ChecksumAlgorithm = upload.store.StreamingChecksumAlgorithm
+			optFns = append(optFns, s3.WithAPIOptions(v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware))
+		}
+
+		res, err := upload.store.Service.UploadPart(ctx, uploadPartInput, optFns...)
 		if err != nil {
 			return "", err
 		}
@@ -628,7 +958,7 @@ func (upload *s3Upload) getInternalInfo(ctx context.Context) (info models.FileIn
 	return info, parts, incompletePartSize, nil
 }
 
-func (upload s3Upload) fetchInfo(ctx context.Context) (info models.FileInfo, parts []*s3Part, incompletePartSize int64, err error) {
+func (upload *s3Upload) fetchInfo(ctx context.Context) (info models.FileInfo, parts []*s3Part, incompletePartSize int64, err error) {
 	store := upload.store
 
 	var wg sync.WaitGroup
@@ -640,20 +970,17 @@ func (upload s3Upload) fetchInfo(ctx context.Context) (info models.FileInfo, par
 	var partsErr error
 	var incompletePartSizeErr error
 
+	// The modification times of the info and incomplete part objects, which are
+	// used to determine when the upload was last modified.
+	var infoModifiedAt time.Time
+	var incompletePartModifiedAt time.Time
+	var infoVersion string
+
 	go func() {
 		defer wg.Done()
-		t := time.Now()
-
 		// Get file info stored in separate object
-		var res *s3.GetObjectOutput
-		res, infoErr = store.Service.GetObject(ctx, &s3.GetObjectInput{
-			Bucket: aws.String(store.Bucket),
-			Key:    store.metadataKeyWithPrefix(upload.objectId + ".info"),
-		})
-		store.observeRequestDuration(t, metricGetInfoObject)
-		if infoErr == nil {
-			infoErr = json.NewDecoder(res.Body).Decode(&info)
-		}
+		info, infoVersion, infoErr = store.infoStore().GetInfo(ctx, upload.objectId)
+		infoModifiedAt = info.ModifiedAt
 	}()
 
 	go func() {
@@ -667,7 +994,7 @@ func (upload s3Upload) fetchInfo(ctx context.Context) (info models.FileInfo, par
 		defer wg.Done()
 
 		// Get size of optional incomplete part file.
-		incompletePartSize, incompletePartSizeErr = store.headIncompletePartForUpload(ctx, upload.objectId)
+		incompletePartSize, incompletePartModifiedAt, incompletePartSizeErr = store.headIncompletePartForUpload(ctx, upload.objectId)
 	}()
 
 	wg.Wait()
@@ -675,12 +1002,16 @@ func (upload s3Upload) fetchInfo(ctx context.Context) (info models.FileInfo, par
 	// Finally, after all requests are complete, let's handle the errors
 	if infoErr != nil {
 		err = infoErr
-		// If the info file is not found, we consider the upload to be non-existant
-		if isAwsError[*types.NoSuchKey](err) {
-			err = models.ErrNotFound
+		// If the info file is not found, the upload has either been finished, in which
+		// case the final object exists, or it is non-existant.
+		if errors.Is(err, models.ErrNotFound) {
+			upload.infoVersion = ""
+			info, err = upload.fetchFinishedInfo(ctx)
+			return info, nil, 0, err
 		}
 		return
 	}
+	upload.infoVersion = infoVersion
 
 	if partsErr != nil {
 		err = partsErr
@@ -696,6 +1027,7 @@ func (upload s3Upload) fetchInfo(ctx context.Context) (info models.FileInfo, par
 		// See https://github.com/aws/aws-sdk-go-v2/issues/1635
 		if isAwsError[*types.NoSuchUpload](err) || isAwsErrorCode(err, "NoSuchUpload") || isAwsError[*types.NoSuchKey](err) {
 			info.Offset = info.Size
+			info.ModifiedAt = infoModifiedAt
 			err = nil
 		}
 		return
@@ -707,23 +1039,170 @@ func (upload s3Upload) fetchInfo(ctx context.Context) (info models.FileInfo, par
 	}
 
 	// The offset is the sum of all part sizes and the size of the incomplete part file.
+	// Similarly, the upload was last modified when the latest of these was written.
 	offset := incompletePartSize
+	modifiedAt := infoModifiedAt
+	if incompletePartModifiedAt.After(modifiedAt) {
+		modifiedAt = incompletePartModifiedAt
+	}
 	for _, part := range parts {
 		offset += part.size
+		if part.lastModified.After(modifiedAt) {
+			modifiedAt = part.lastModified
+		}
+	}
+
+	// For direct uploads, parts may be uploaded in any order, but only the completed
+	// parts at the beginning of the upload count towards the offset.
+	if info.DirectUpload != nil {
+		offset = info.DirectUpload.Offset(info.Size)
 	}
 
 	info.Offset = offset
+	info.ModifiedAt = modifiedAt
 
 	return info, parts, incompletePartSize, nil
 }
 
+// uploadIdMetadataKey is the metadata key, under which the upload's object ID is stored
+// on the multipart upload and thereby on the final object. It marks objects created by
+// tusd, so that other objects in the bucket are not exposed as finished uploads.
+const uploadIdMetadataKey = "tusd-upload-id"
+
+// fetchFinishedInfo constructs the information about a finished upload, whose info
+// object has already been removed, from its final object. ErrNotFound is returned if
+// the object has not been created by tusd for this upload.
+func (upload s3Upload) fetchFinishedInfo(ctx context.Context) (info models.FileInfo, err error) {
+	store := upload.store
+
+	t := time.Now()
+	res, err := store.Service.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    store.keyWithPrefix(upload.objectId),
+	})
+	store.observeRequestDuration(t, metricHeadObject)
+	if err != nil {
+		if isAwsError[*types.NoSuchKey](err) || isAwsError[*types.NotFound](err) {
+			err = models.ErrNotFound
+		}
+		return info, err
+	}
+
+	if res.Metadata[uploadIdMetadataKey] != upload.objectId {
+		return info, models.ErrNotFound
+	}
+	delete(res.Metadata, uploadIdMetadataKey)
+
+	digests, err := upload.getDigestTags(ctx)
+	if err != nil {
+		return info, err
+	}
+
+	size := aws.ToInt64(res.ContentLength)
+	return models.FileInfo{
+		ID:       upload.objectId + "+" + upload.multipartId,
+		Size:     size,
+		Offset:   size,
+		MetaData: store.decodeMetadata(res.Metadata),
+		Digests:  digests,
+		Storage: map[string]string{
+			"Type":   "s3store",
+			"Bucket": store.Bucket,
+			"Key":    *store.keyWithPrefix(upload.objectId),
+		},
+		ModifiedAt: aws.ToTime(res.LastModified),
+	}, nil
+}
+
+// GetReader returns a reader for the upload's content. Finished uploads are downloaded
+// using parallel ranged requests if DownloadConcurrency is larger than 1. Unfinished
+// uploads can only be read as long as all of their data is stored in the incomplete
+// part object, because S3 does not allow downloading the parts of a multipart upload
+// before it has been completed. Otherwise, ERR_INCOMPLETE_UPLOAD is returned.
 func (upload s3Upload) GetReader(ctx context.Context) (io.ReadCloser, error) {
 	store := upload.store
 
+	if store.DownloadConcurrency > 1 && store.DownloadPartSize > 0 {
+		// The first request only asks for the first range. Its response also
+		// tells us the object's size.
+		key := store.keyWithPrefix(upload.objectId)
+		res, err := store.Service.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(store.Bucket),
+			Key:    key,
+			Range:  aws.String(fmt.Sprintf("bytes=0-%d", store.DownloadPartSize-1)),
+		})
+		if err == nil {
+			return store.newParallelReader(ctx, key, res)
+		}
+
+		// Empty objects cannot be requested using a range and unfinished uploads
+		// are handled by getObjectReader.
+		if !isAwsErrorCode(err, "InvalidRange") && !isAwsError[*types.NoSuchKey](err) {
+			return nil, err
+		}
+	}
+
+	return upload.getObjectReader(ctx, nil)
+}
+
+// GetRangeReader requests only the given range of the upload's content from S3.
+func (upload s3Upload) GetRangeReader(ctx context.Context, offset int64, length int64) (io.ReadCloser, error) {
+	return upload.getObjectReader(ctx, aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)))
+}
+
+// PresignGetURL returns a presigned URL for downloading the finished object directly
+// from S3. This requires Service to be a *s3.Client.
+func (upload s3Upload) PresignGetURL(ctx context.Context, options models.PresignOptions) (string, error) {
+	store := upload.store
+
+	s3Client, ok := store.Service.(*s3.Client)
+	if !ok {
+		return "", fmt.Errorf("s3store: failed to cast S3 service for presigning")
+	}
+
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    store.keyWithPrefix(upload.objectId),
+	}
+	if options.ContentType != "" {
+		input.ResponseContentType = aws.String(options.ContentType)
+	}
+	if options.ContentDisposition != "" {
+		input.ResponseContentDisposition = aws.String(options.ContentDisposition)
+	}
+
+	req, err := s3.NewPresignClient(s3Client).PresignGetObject(ctx, input, func(opts *s3.PresignOptions) {
+		opts.Expires = options.Expires
+	}, withoutRetryMetricsHeader)
+	if err != nil {
+		return "", fmt.Errorf("s3store: failed to presign GetObject: %s", err)
+	}
+
+	return req.URL, nil
+}
+
+// withoutRetryMetricsHeader removes the retry metrics header from presigned requests.
+// It would otherwise be included in the signed headers, although clients using the
+// presigned URL do not send it.
+func withoutRetryMetricsHeader(opts *s3.PresignOptions) {
+	opts.ClientOptions = append(opts.ClientOptions, func(o *s3.Options) {
+		o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
+			// An error only indicates that the middleware is not present.
+			stack.Finalize.Remove("RetryMetricsHeader")
+			return nil
+		})
+	})
+}
+
+// getObjectReader returns a reader for the upload's content or the given range of it.
+func (upload s3Upload) getObjectReader(ctx context.Context, byteRange *string) (io.ReadCloser, error) {
+	store := upload.store
+
 	// Attempt to get upload content
 	res, err := store.Service.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(store.Bucket),
 		Key:    store.keyWithPrefix(upload.objectId),
+		Range:  byteRange,
 	})
 	if err == nil {
 		// No error occurred, and we are able to stream the object
@@ -739,15 +1218,9 @@ func (upload s3Upload) GetReader(ctx context.Context) (io.ReadCloser, error) {
 
 	// Test whether the multipart upload exists to find out if the upload
 	// never existsted or just has not been finished yet
-	_, err = store.Service.ListParts(ctx, &s3.ListPartsInput{
-		Bucket:   aws.String(store.Bucket),
-		Key:      store.keyWithPrefix(upload.objectId),
-		UploadId: aws.String(upload.multipartId),
-		MaxParts: aws.Int32(0),
-	})
+	parts, err := store.listAllParts(ctx, upload.objectId, upload.multipartId)
 	if err == nil {
-		// The multipart upload still exists, which means we cannot download it yet
-		return nil, models.NewError("ERR_INCOMPLETE_UPLOAD", "cannot stream non-finished upload", http.StatusBadRequest)
+		return upload.getUnfinishedReader(ctx, parts, byteRange)
 	}
 
 	// The AWS Go SDK v2 has a bug where types.NoSuchUpload is not returned,
@@ -761,6 +1234,38 @@ func (upload s3Upload) GetReader(ctx context.Context) (io.ReadCloser, error) {
 	return nil, err
 }
 
+// getUnfinishedReader returns a reader for the data of an upload whose multipart upload
+// has not been completed yet. S3 does not allow downloading the parts of an unfinished
+// multipart upload, so this is only possible as long as all data is stored in the
+// incomplete part object, i.e. no part with data has been uploaded yet.
+func (upload s3Upload) getUnfinishedReader(ctx context.Context, parts []*s3Part, byteRange *string) (io.ReadCloser, error) {
+	store := upload.store
+
+	for _, part := range parts {
+		if part.size > 0 {
+			// The multipart upload still exists, which means we cannot download it yet
+			return nil, models.NewError("ERR_INCOMPLETE_UPLOAD", "cannot stream non-finished upload", http.StatusBadRequest)
+		}
+	}
+
+	t := time.Now()
+	res, err := store.Service.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    store.metadataKeyWithPrefix(upload.objectId + ".part"),
+		Range:  byteRange,
+	})
+	store.observeRequestDuration(t, metricGetPartObject)
+	if err != nil {
+		if isAwsError[*types.NoSuchKey](err) {
+			// No data has been uploaded yet.
+			return io.NopCloser(bytes.NewReader(nil)), nil
+		}
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
 func (upload s3Upload) Terminate(ctx context.Context) error {
 	store := upload.store
 
@@ -785,7 +1290,7 @@ func (upload s3Upload) Terminate(ctx context.Context) error {
 	go func() {
 		defer wg.Done()
 
-		// Delete the info and content files
+		// Delete the content files
 		res, err := store.Service.DeleteObjects(ctx, &s3.DeleteObjectsInput{
 			Bucket: aws.String(store.Bucket),
 			Delete: &types.Delete{
@@ -796,9 +1301,6 @@ func (upload s3Upload) Terminate(ctx context.Context) error {
 					{
 						Key: store.metadataKeyWithPrefix(upload.objectId + ".part"),
 					},
-					{
-						Key: store.metadataKeyWithPrefix(upload.objectId + ".info"),
-					},
 				},
 				Quiet: aws.Bool(true),
 			},
@@ -818,6 +1320,12 @@ func (upload s3Upload) Terminate(ctx context.Context) error {
 
 	wg.Wait()
 
+	// Delete the info once the multipart upload has been aborted, so that it does
+	// not become orphaned if this fails.
+	if err := store.infoStore().DeleteInfo(ctx, upload.objectId); err != nil {
+		errs = append(errs, err)
+	}
+
 	if len(errs) > 0 {
 		return newMultiError(errs)
 	}
@@ -829,11 +1337,17 @@ func (upload s3Upload) FinishUpload(ctx context.Context) error {
 	store := upload.store
 
 	// Get uploaded parts
-	_, parts, _, err := upload.getInternalInfo(ctx)
+	info, parts, _, err := upload.getInternalInfo(ctx)
 	if err != nil {
 		return err
 	}
 
+	if info.DirectUpload != nil {
+		// Only the parts reported by the client are used, since it may have uploaded
+		// other versions of them to S3 afterwards.
+		parts = directUploadParts(info)
+	}
+
 	if len(parts) == 0 {
 		// AWS expects at least one part to be present when completing the multipart
 		// upload. So if the tus upload has a size of 0, we create an empty part
@@ -860,14 +1374,26 @@ func (upload s3Upload) FinishUpload(ctx context.Context) error {
 	}
 
 	// Transform the []*s3.Part slice to a []*s3.CompletedPart slice for the next
-	// request.
-	completedParts := make([]types.CompletedPart, len(parts))
+	// request. Empty parts, which are left behind by RollbackChunk, are skipped
+	// unless the upload consists of nothing else.
+	completedParts := make([]types.CompletedPart, 0, len(parts))
 
-	for index, part := range parts {
-		completedParts[index] = types.CompletedPart{
+	for _, part := range parts {
+		if part.size == 0 {
+			continue
+		}
+
+		completedParts = append(completedParts, types.CompletedPart{
 			ETag:       aws.String(part.etag),
 			PartNumber: aws.Int32(part.number),
-		}
+		})
+	}
+
+	if len(completedParts) == 0 {
+		completedParts = append(completedParts, types.CompletedPart{
+			ETag:       aws.String(parts[0].etag),
+			PartNumber: aws.Int32(parts[0].number),
+		})
 	}
 
 	t := time.Now()
@@ -880,14 +1406,19 @@ func (upload s3Upload) FinishUpload(ctx context.Context) error {
 		},
 	})
 	store.observeRequestDuration(t, metricCompleteMultipartUpload)
+	if err != nil {
+		return err
+	}
 
-	// delete the info file
-	_, err = store.Service.DeleteObject(ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(store.Bucket),
-		Key:    store.metadataKeyWithPrefix(upload.objectId + ".info"),
-	})
+	// The digests must be stored on the final object before the info is gone.
+	if len(info.Digests) > 0 {
+		if err := upload.putDigestTags(ctx, info.Digests); err != nil {
+			return err
+		}
+	}
 
-	return err
+	// delete the info file
+	return store.infoStore().DeleteInfo(ctx, upload.objectId)
 }
 
 func (upload *s3Upload) ConcatUploads(ctx context.Context, partialUploads []models.Upload) error {
@@ -1019,6 +1550,83 @@ func (upload *s3Upload) concatUsingMultipart(ctx context.Context, partialUploads
 	return upload.FinishUpload(ctx)
 }
 
+// UpdateDigests stores the digests in the info object. Since the info object is removed
+// once the upload is finished, FinishUpload copies the final digests into the tags of
+// the final object, from which they are read afterwards.
+func (upload *s3Upload) UpdateDigests(ctx context.Context, state *models.DigestState, digests map[string]string) error {
+	info, err := upload.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+	info.DigestState = state
+	info.Digests = digests
+
+	return upload.writeInfo(ctx, info)
+}
+
+// digestTagPrefix is the prefix of the tag keys, under which the digests of a finished
+// upload are stored, followed by the algorithm, e.g. tusd-digest-sha-256.
+const digestTagPrefix = "tusd-digest-"
+
+// putDigestTags stores the digests in the tags of the final object.
+func (upload s3Upload) putDigestTags(ctx context.Context, digests map[string]string) error {
+	store := upload.store
+
+	tags := make([]types.Tag, 0, len(digests))
+	for algorithm, digest := range digests {
+		tags = append(tags, types.Tag{
+			Key:   aws.String(digestTagPrefix + algorithm),
+			Value: aws.String(digest),
+		})
+	}
+
+	t := time.Now()
+	_, err := store.Service.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(store.Bucket),
+		Key:     store.keyWithPrefix(upload.objectId),
+		Tagging: &types.Tagging{TagSet: tags},
+	})
+	store.observeRequestDuration(t, metricPutObjectTagging)
+	if err != nil {
+		return fmt.Errorf("s3store: unable to store digests in object tags: %w", err)
+	}
+
+	return nil
+}
+
+// getDigestTags reads the digests from the tags of the final object. If the object has
+// no digests or the S3-compatible service does not support tagging, nil is returned.
+func (upload s3Upload) getDigestTags(ctx context.Context) (map[string]string, error) {
+	store := upload.store
+
+	t := time.Now()
+	res, err := store.Service.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    store.keyWithPrefix(upload.objectId),
+	})
+	store.observeRequestDuration(t, metricGetObjectTagging)
+	if err != nil {
+		if isAwsErrorCode(err, "NotImplemented") {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var digests map[string]string
+	for _, tag := range res.TagSet {
+		algorithm, ok := strings.CutPrefix(aws.ToString(tag.Key), digestTagPrefix)
+		if !ok {
+			continue
+		}
+		if digests == nil {
+			digests = make(map[string]string)
+		}
+		digests[algorithm] = aws.ToString(tag.Value)
+	}
+
+	return digests, nil
+}
+
 func (upload *s3Upload) DeclareLength(ctx context.Context, length int64) error {
 	info, err := upload.GetInfo(ctx)
 	if err != nil {
@@ -1030,6 +1638,38 @@ func (upload *s3Upload) DeclareLength(ctx context.Context, length int64) error {
 	return upload.writeInfo(ctx, info)
 }
 
+// ListExpiredUploads lists all information in the InfoStore and returns the IDs of all
+// unfinished uploads in this bucket, which expired before the given time. Since the
+// information is removed once an upload is finished, it only exists for unfinished uploads.
+func (store S3Store) ListExpiredUploads(ctx context.Context, before time.Time) ([]string, error) {
+	infoStore := store.infoStore()
+	objectIds, err := infoStore.ListInfos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	for _, objectId := range objectIds {
+		info, _, err := infoStore.GetInfo(ctx, objectId)
+		if err != nil {
+			// The upload might have been finished or removed in the meantime.
+			if errors.Is(err, models.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+
+		// The offset is not included in the info object, but the expiration date
+		// is usually far enough in the past that fetching the parts is not worth it.
+		// The handler checks the offset again before terminating the upload.
+		if !info.ExpiresAt.IsZero() && before.After(info.ExpiresAt) && store.ownsInfo(info) {
+			ids = append(ids, info.ID)
+		}
+	}
+
+	return ids, nil
+}
+
 func (store S3Store) listAllParts(ctx context.Context, objectId string, multipartId string) (parts []*s3Part, err error) {
 	var partMarker *string
 	for {
@@ -1050,9 +1690,10 @@ func (store S3Store) listAllParts(ctx context.Context, objectId string, multipar
 		parts = slices.Grow(parts, len(parts)+len((*listPtr).Parts))
 		for _, part := range (*listPtr).Parts {
 			parts = append(parts, &s3Part{
-				number: *part.PartNumber,
-				size:   *part.Size,
-				etag:   *part.ETag,
+				number:       *part.PartNumber,
+				size:         *part.Size,
+				etag:         *part.ETag,
+				lastModified: aws.ToTime(part.LastModified),
 			})
 		}
 
@@ -1112,7 +1753,7 @@ func (store S3Store) getIncompletePartForUpload(ctx context.Context, uploadId st
 	return obj, err
 }
 
-func (store S3Store) headIncompletePartForUpload(ctx context.Context, uploadId string) (int64, error) {
+func (store S3Store) headIncompletePartForUpload(ctx context.Context, uploadId string) (int64, time.Time, error) {
 	t := time.Now()
 	obj, err := store.Service.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(store.Bucket),
@@ -1124,10 +1765,10 @@ func (store S3Store) headIncompletePartForUpload(ctx context.Context, uploadId s
 		if isAwsError[*types.NoSuchKey](err) || isAwsError[*types.NotFound](err) || isAwsErrorCode(err, "AccessDenied") || isAwsErrorCode(err, "Forbidden") {
 			err = nil
 		}
-		return 0, err
+		return 0, time.Time{}, err
 	}
 
-	return *obj.ContentLength, nil
+	return *obj.ContentLength, aws.ToTime(obj.LastModified), nil
 }
 
 func (store S3Store) putIncompletePartForUpload(ctx context.Context, uploadId string, file io.ReadSeeker) error {
@@ -0,0 +1,48 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestDigestsOfFinishedUpload(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	fake, store := newFakeS3Store(t)
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: 11})
+	assert.NoError(err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(err)
+	objectId, _ := splitIds(info.ID)
+
+	_, err = upload.WriteChunk(ctx, 0, bytes.NewReader([]byte("hello world")))
+	assert.NoError(err)
+	digests := map[string]string{
+		"sha-256": "uU0nuZNNPgilLlLX2n2r+sSE7+N6U4DukIj3rOLvzek=",
+		"md5":     "XrY7u+Ae7tCTyyK7j1rNww==",
+	}
+	state := &models.DigestState{Offset: 11}
+	assert.NoError(store.AsDigestableUpload(upload).UpdateDigests(ctx, state, digests))
+	assert.NoError(upload.FinishUpload(ctx))
+
+	// The info object is removed, so the digests are kept in the object's tags.
+	object := fake.object(objectId)
+	if !assert.NotNil(object) {
+		return
+	}
+	assert.Equal(digests["sha-256"], object.tags["tusd-digest-sha-256"])
+	assert.Equal(digests["md5"], object.tags["tusd-digest-md5"])
+
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	info, err = upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.Equal(int64(11), info.Offset)
+	assert.Equal(digests, info.Digests)
+	assert.Nil(info.DigestState)
+}
@@ -0,0 +1,166 @@
+package s3store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// PresignUploadParts returns presigned UploadPart URLs for the given parts of the
+// multipart upload. The Content-Length is included in the signature, so S3 only accepts
+// parts of the expected size. This requires Service to be a *s3.Client.
+//
+// The first call switches the upload to direct uploads, which is only possible while no
+// data has been written to it. The part size is chosen as for regular uploads.
+func (upload *s3Upload) PresignUploadParts(ctx context.Context, partNumbers []int32, expires time.Duration) ([]models.PresignedPart, error) {
+	store := upload.store
+
+	s3Client, ok := store.Service.(*s3.Client)
+	if !ok {
+		return nil, fmt.Errorf("s3store: failed to cast S3 service for presigning")
+	}
+
+	info, parts, incompletePartSize, err := upload.getInternalInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.DirectUpload == nil {
+		if info.SizeIsDeferred || info.Size == 0 || info.Offset > 0 || incompletePartSize > 0 {
+			return nil, models.ErrDirectUploadUnavailable
+		}
+		for _, part := range parts {
+			if part.size > 0 {
+				return nil, models.ErrDirectUploadUnavailable
+			}
+		}
+
+		partSize, err := store.calcOptimalPartSize(info.Size)
+		if err != nil {
+			return nil, err
+		}
+
+		info.DirectUpload = &models.DirectUploadState{
+			PartSize: partSize,
+			Parts:    make(map[int32]string),
+		}
+		if err := upload.writeInfo(ctx, info); err != nil {
+			return nil, err
+		}
+	}
+
+	presignClient := s3.NewPresignClient(s3Client)
+	presignedParts := make([]models.PresignedPart, 0, len(partNumbers))
+	for _, number := range partNumbers {
+		offset, size, ok := info.DirectUpload.PartRange(number, info.Size)
+		if !ok {
+			return nil, models.ErrInvalidPartNumber
+		}
+
+		req, err := presignClient.PresignUploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(store.Bucket),
+			Key:           store.keyWithPrefix(upload.objectId),
+			UploadId:      aws.String(upload.multipartId),
+			PartNumber:    aws.Int32(number),
+			ContentLength: aws.Int64(size),
+		}, func(opts *s3.PresignOptions) {
+			opts.Expires = expires
+		}, withoutRetryMetricsHeader)
+		if err != nil {
+			return nil, fmt.Errorf("s3store: failed to presign UploadPart: %s", err)
+		}
+
+		presignedParts = append(presignedParts, models.PresignedPart{
+			PartNumber: number,
+			Offset:     offset,
+			Size:       size,
+			URL:        req.URL,
+		})
+	}
+
+	return presignedParts, nil
+}
+
+// CompleteUploadParts compares the reported parts with the parts listed by S3 and
+// records them in the info object. A part is only accepted if S3 lists it with the
+// same ETag and the expected size.
+func (upload *s3Upload) CompleteUploadParts(ctx context.Context, uploadedParts []models.UploadedPart) error {
+	store := upload.store
+
+	info, err := upload.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	if info.DirectUpload == nil {
+		return models.ErrDirectUploadUnavailable
+	}
+
+	// The parts are listed again, since the cached ones may be outdated after the
+	// client has uploaded further parts.
+	parts, err := store.listAllParts(ctx, upload.objectId, upload.multipartId)
+	if err != nil {
+		return err
+	}
+
+	listedParts := make(map[int32]*s3Part, len(parts))
+	for _, part := range parts {
+		listedParts[part.number] = part
+	}
+
+	state := info.DirectUpload
+	if state.Parts == nil {
+		state.Parts = make(map[int32]string)
+	}
+
+	for _, uploadedPart := range uploadedParts {
+		_, size, ok := state.PartRange(uploadedPart.PartNumber, info.Size)
+		if !ok {
+			return models.ErrInvalidPartNumber
+		}
+
+		part, ok := listedParts[uploadedPart.PartNumber]
+		if !ok || part.size != size || normalizeETag(part.etag) != normalizeETag(uploadedPart.ETag) {
+			return models.ErrInvalidUploadedPart
+		}
+
+		state.Parts[part.number] = part.etag
+	}
+
+	info.Offset = state.Offset(info.Size)
+	info.ModifiedAt = time.Now()
+	upload.parts = parts
+
+	return upload.writeInfo(ctx, info)
+}
+
+// directUploadParts returns the parts, which have been completed by the client in a
+// direct upload, ordered by their number.
+func directUploadParts(info models.FileInfo) []*s3Part {
+	parts := make([]*s3Part, 0, len(info.DirectUpload.Parts))
+	for number, etag := range info.DirectUpload.Parts {
+		_, size, _ := info.DirectUpload.PartRange(number, info.Size)
+		parts = append(parts, &s3Part{
+			number: number,
+			size:   size,
+			etag:   etag,
+		})
+	}
+
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].number < parts[j].number
+	})
+
+	return parts
+}
+
+// normalizeETag removes the quotes around an ETag, which clients might strip.
+func normalizeETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
@@ -0,0 +1,90 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestDirectUpload(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	_, store := newFakeS3Store(t)
+	content := "0123456789abcdefghijABCDE"
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: int64(len(content))})
+	assert.NoError(err)
+	direct := store.AsDirectUploadableUpload(upload)
+
+	parts, err := direct.PresignUploadParts(ctx, []int32{3, 1, 2}, time.Minute)
+	assert.NoError(err)
+	assert.Len(parts, 3)
+
+	etags := map[int32]string{}
+	for _, part := range parts {
+		assert.Equal(int64((part.PartNumber-1)*10), part.Offset)
+		req, err := http.NewRequest("PUT", part.URL, strings.NewReader(content[part.Offset:part.Offset+part.Size]))
+		assert.NoError(err)
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(err)
+		res.Body.Close()
+		assert.Equal(http.StatusOK, res.StatusCode)
+		etags[part.PartNumber] = res.Header.Get("ETag")
+	}
+	assert.Equal(int64(5), parts[0].Size)
+
+	_, err = direct.PresignUploadParts(ctx, []int32{4}, time.Minute)
+	assert.Equal(models.ErrInvalidPartNumber, err)
+	assert.Equal(models.ErrInvalidUploadedPart, direct.CompleteUploadParts(ctx, []models.UploadedPart{{PartNumber: 1, ETag: "wrong"}}))
+
+	// The offset only advances over parts, which are preceded by completed parts.
+	assert.NoError(direct.CompleteUploadParts(ctx, []models.UploadedPart{{PartNumber: 2, ETag: etags[2]}}))
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.Equal(int64(0), info.Offset)
+
+	// Clients may strip the quotes from the ETags.
+	assert.NoError(direct.CompleteUploadParts(ctx, []models.UploadedPart{{PartNumber: 1, ETag: strings.Trim(etags[1], `"`)}}))
+	info, err = upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.Equal(int64(20), info.Offset)
+
+	// The state is persisted for the following requests.
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	assert.NoError(store.AsDirectUploadableUpload(upload).CompleteUploadParts(ctx, []models.UploadedPart{{PartNumber: 3, ETag: etags[3]}}))
+	info, err = upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.Equal(info.Size, info.Offset)
+	assert.NoError(upload.FinishUpload(ctx))
+
+	reader, err := upload.GetReader(ctx)
+	assert.NoError(err)
+	data, err := io.ReadAll(reader)
+	assert.NoError(err)
+	assert.Equal(content, string(data))
+}
+
+func TestDirectUploadUnavailable(t *testing.T) {
+	ctx := context.Background()
+	_, store := newFakeS3Store(t)
+
+	deferred, err := store.NewUpload(ctx, models.FileInfo{SizeIsDeferred: true})
+	assert.NoError(t, err)
+	_, err = store.AsDirectUploadableUpload(deferred).PresignUploadParts(ctx, []int32{1}, time.Minute)
+	assert.Equal(t, models.ErrDirectUploadUnavailable, err)
+
+	written, err := store.NewUpload(ctx, models.FileInfo{Size: 20})
+	assert.NoError(t, err)
+	_, err = written.WriteChunk(ctx, 0, bytes.NewReader([]byte("012")))
+	assert.NoError(t, err)
+	_, err = store.AsDirectUploadableUpload(written).PresignUploadParts(ctx, []int32{1}, time.Minute)
+	assert.Equal(t, models.ErrDirectUploadUnavailable, err)
+}
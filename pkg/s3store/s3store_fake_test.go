@@ -0,0 +1,365 @@
+package s3store
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeS3 is a minimal in-memory S3 server, which implements the path-style requests
+// sent by S3Store. Conditional requests are supported using If-Match and
+// If-None-Match.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string]*fakeObject
+	uploads  map[string]*fakeMultipartUpload
+	uploadId int
+	// requests contains the method and URL of every received request.
+	requests []string
+}
+
+type fakeObject struct {
+	data     []byte
+	metadata map[string]string
+	tags     map[string]string
+	etag     string
+	modified time.Time
+}
+
+type fakeMultipartUpload struct {
+	key      string
+	metadata map[string]string
+	parts    map[int]*fakeObject
+}
+
+// newFakeS3Store starts a fake S3 server and returns a store using it. The part sizes
+// are reduced, so that small uploads consist of multiple parts.
+func newFakeS3Store(t *testing.T) (*fakeS3, S3Store) {
+	fake := &fakeS3{
+		objects: map[string]*fakeObject{},
+		uploads: map[string]*fakeMultipartUpload{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	})
+
+	store := New("bucket", client)
+	store.MinPartSize = 5
+	store.PreferredPartSize = 10
+
+	return fake, store
+}
+
+// object returns the object with the given key or nil.
+func (fake *fakeS3) object(key string) *fakeObject {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	return fake.objects[key]
+}
+
+// putObject stores an object, as if it had been uploaded by another client.
+func (fake *fakeS3) putObject(key string, data []byte) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.objects[key] = newFakeObject(data, nil)
+}
+
+// partNumbers returns the part numbers of the multipart upload for the given key.
+func (fake *fakeS3) partNumbers(key string) []int {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var numbers []int
+	for _, upload := range fake.uploads {
+		if upload.key == key {
+			for number := range upload.parts {
+				numbers = append(numbers, number)
+			}
+		}
+	}
+	sort.Ints(numbers)
+
+	return numbers
+}
+
+func newFakeObject(data []byte, metadata map[string]string) *fakeObject {
+	sum := md5.Sum(data)
+	return &fakeObject{
+		data:     data,
+		metadata: metadata,
+		etag:     `"` + hex.EncodeToString(sum[:]) + `"`,
+		modified: time.Now(),
+	}
+}
+
+func (fake *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	fake.requests = append(fake.requests, r.Method+" "+r.URL.String())
+
+	_, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+	body = decodeAwsChunked(r, body)
+
+	switch {
+	case r.Method == http.MethodPut && query.Has("tagging"):
+		object, ok := fake.objects[key]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		var tagging struct {
+			Tags []struct{ Key, Value string } `xml:"TagSet>Tag"`
+		}
+		xml.Unmarshal(body, &tagging)
+		object.tags = map[string]string{}
+		for _, tag := range tagging.Tags {
+			object.tags[tag.Key] = tag.Value
+		}
+	case r.Method == http.MethodGet && query.Has("tagging"):
+		object, ok := fake.objects[key]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		fmt.Fprint(w, "<Tagging><TagSet>")
+		for k, v := range object.tags {
+			fmt.Fprintf(w, "<Tag><Key>%s</Key><Value>%s</Value></Tag>", xmlEscape(k), xmlEscape(v))
+		}
+		fmt.Fprint(w, "</TagSet></Tagging>")
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		fake.uploadId += 1
+		id := "upload" + strconv.Itoa(fake.uploadId)
+		fake.uploads[id] = &fakeMultipartUpload{
+			key:      key,
+			metadata: fakeMetadata(r),
+			parts:    map[int]*fakeObject{},
+		}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><Key>%s</Key><UploadId>%s</UploadId></InitiateMultipartUploadResult>", xmlEscape(key), id)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		upload, ok := fake.uploads[query.Get("uploadId")]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		upload.parts[number] = newFakeObject(body, nil)
+		w.Header().Set("ETag", upload.parts[number].etag)
+	case r.Method == http.MethodGet && query.Has("uploadId"):
+		upload, ok := fake.uploads[query.Get("uploadId")]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var numbers []int
+		for number := range upload.parts {
+			numbers = append(numbers, number)
+		}
+		sort.Ints(numbers)
+		fmt.Fprint(w, "<ListPartsResult><IsTruncated>false</IsTruncated>")
+		for _, number := range numbers {
+			part := upload.parts[number]
+			fmt.Fprintf(w, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag><Size>%d</Size><LastModified>%s</LastModified></Part>",
+				number, xmlEscape(part.etag), len(part.data), part.modified.UTC().Format(time.RFC3339))
+		}
+		fmt.Fprint(w, "</ListPartsResult>")
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		upload, ok := fake.uploads[query.Get("uploadId")]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var completion struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		xml.Unmarshal(body, &completion)
+		var data []byte
+		for _, completed := range completion.Parts {
+			part, ok := upload.parts[completed.PartNumber]
+			if !ok || part.etag != completed.ETag {
+				writeFakeError(w, http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			data = append(data, part.data...)
+		}
+		fake.objects[upload.key] = newFakeObject(data, upload.metadata)
+		delete(fake.uploads, query.Get("uploadId"))
+		fmt.Fprintf(w, "<CompleteMultipartUploadResult><Key>%s</Key></CompleteMultipartUploadResult>", xmlEscape(key))
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		if _, ok := fake.uploads[query.Get("uploadId")]; !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		delete(fake.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && query.Has("delete"):
+		var deletion struct {
+			Objects []struct{ Key string } `xml:"Object"`
+		}
+		xml.Unmarshal(body, &deletion)
+		for _, object := range deletion.Objects {
+			delete(fake.objects, object.Key)
+		}
+		fmt.Fprint(w, "<DeleteResult></DeleteResult>")
+	case r.Method == http.MethodGet && query.Has("uploads"):
+		fmt.Fprint(w, "<ListMultipartUploadsResult><IsTruncated>false</IsTruncated>")
+		for id, upload := range fake.uploads {
+			if strings.HasPrefix(upload.key, query.Get("prefix")) {
+				fmt.Fprintf(w, "<Upload><Key>%s</Key><UploadId>%s</UploadId><Initiated>%s</Initiated></Upload>",
+					xmlEscape(upload.key), id, time.Now().UTC().Format(time.RFC3339))
+			}
+		}
+		fmt.Fprint(w, "</ListMultipartUploadsResult>")
+	case r.Method == http.MethodGet && query.Has("list-type"):
+		var keys []string
+		for k := range fake.objects {
+			if strings.HasPrefix(k, query.Get("prefix")) {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		fmt.Fprint(w, "<ListBucketResult><IsTruncated>false</IsTruncated>")
+		for _, k := range keys {
+			object := fake.objects[k]
+			fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>%s</LastModified><ETag>%s</ETag></Contents>",
+				xmlEscape(k), len(object.data), object.modified.UTC().Format(time.RFC3339), xmlEscape(object.etag))
+		}
+		fmt.Fprint(w, "</ListBucketResult>")
+	case r.Method == http.MethodPut:
+		if !fake.checkConditions(w, r, fake.objects[key]) {
+			return
+		}
+		fake.objects[key] = newFakeObject(body, fakeMetadata(r))
+		w.Header().Set("ETag", fake.objects[key].etag)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := fake.objects[key]
+		if !ok {
+			writeFakeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		if !fake.checkConditions(w, r, object) {
+			return
+		}
+		for k, v := range object.metadata {
+			w.Header().Set("X-Amz-Meta-"+k, v)
+		}
+		w.Header().Set("Last-Modified", object.modified.UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", object.etag)
+
+		data := object.data
+		status := http.StatusOK
+		if header := r.Header.Get("Range"); header != "" {
+			var start, end int
+			fmt.Sscanf(header, "bytes=%d-%d", &start, &end)
+			if start >= len(data) {
+				writeFakeError(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			data = data[start : end+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(fake.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeFakeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+// checkConditions evaluates the If-Match and If-None-Match headers against the
+// existing object and responds with PreconditionFailed if they are not satisfied.
+func (fake *fakeS3) checkConditions(w http.ResponseWriter, r *http.Request, object *fakeObject) bool {
+	if etag := r.Header.Get("If-Match"); etag != "" && (object == nil || object.etag != etag) {
+		writeFakeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+		return false
+	}
+	if r.Header.Get("If-None-Match") == "*" && object != nil {
+		writeFakeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+		return false
+	}
+
+	return true
+}
+
+func fakeMetadata(r *http.Request) map[string]string {
+	metadata := map[string]string{}
+	for k, v := range r.Header {
+		if name, ok := strings.CutPrefix(strings.ToLower(k), "x-amz-meta-"); ok {
+			metadata[name] = v[0]
+		}
+	}
+
+	return metadata
+}
+
+func writeFakeError(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// decodeAwsChunked removes the aws-chunked framing, which is used for streamed parts.
+func decodeAwsChunked(r *http.Request, body []byte) []byte {
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") && !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING") {
+		return body
+	}
+
+	var data []byte
+	rest := string(body)
+	for {
+		line, after, ok := strings.Cut(rest, "\r\n")
+		if !ok {
+			break
+		}
+		sizeStr, _, _ := strings.Cut(line, ";")
+		size, err := strconv.ParseInt(sizeStr, 16, 64)
+		if err != nil || size == 0 {
+			break
+		}
+		data = append(data, after[:size]...)
+		rest = after[size+2:]
+	}
+
+	return data
+}
@@ -0,0 +1,40 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestFinishedUploadMarker(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	fake, store := newFakeS3Store(t)
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: 5, MetaData: models.MetaData{"filename": "a.txt"}})
+	assert.NoError(err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(err)
+	_, err = upload.WriteChunk(ctx, 0, bytes.NewReader([]byte("hello")))
+	assert.NoError(err)
+	assert.NoError(upload.FinishUpload(ctx))
+
+	// The marker identifies the final object, but is not exposed as metadata.
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	info, err = upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.Equal(int64(5), info.Offset)
+	assert.Equal(models.MetaData{"filename": "a.txt"}, info.MetaData)
+
+	// Objects, which have not been created by tusd, are not served as uploads.
+	fake.putObject("secret.txt", []byte("secret"))
+	upload, err = store.GetUpload(ctx, "secret.txt+anything")
+	if err == nil {
+		_, err = upload.GetInfo(ctx)
+	}
+	assert.ErrorIs(err, models.ErrNotFound)
+}
@@ -0,0 +1,24 @@
+//go:build linux || darwin
+
+package s3store
+
+import (
+	"os"
+	"syscall"
+)
+
+// freeTemporarySpace returns the number of bytes available to unprivileged users in
+// the file system containing the directory. An empty directory refers to the operating
+// system's default temporary directory.
+func freeTemporarySpace(dir string) (int64, bool) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, false
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), true
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package s3store
+
+// freeTemporarySpace is not supported on this platform, so the free space is unknown.
+func freeTemporarySpace(dir string) (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,353 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// deleteObjectsBatchSize is the maximum number of keys S3 accepts in a single
+// DeleteObjects request.
+const deleteObjectsBatchSize = 1000
+
+// GarbageCollectionOptions controls the behavior of CollectGarbage.
+type GarbageCollectionOptions struct {
+	// MinAge is the minimum age of a multipart upload or object before it is
+	// considered orphaned. It protects uploads which are currently being created
+	// or finished, so it should be chosen well above the duration of a request,
+	// e.g. 24 hours. It must be positive.
+	MinAge time.Duration
+	// DryRun instructs the garbage collector to only report the orphans without
+	// aborting or deleting them.
+	DryRun bool
+}
+
+// GarbageCollectionReport describes the orphans found by CollectGarbage in a bucket.
+// Unless DryRun is set, all listed orphans have been removed, except for the ones
+// mentioned in the returned error.
+type GarbageCollectionReport struct {
+	// Bucket is the name of the bucket, which was inspected.
+	Bucket string
+	// DryRun is true if the orphans have only been reported but not removed.
+	DryRun bool
+	// OrphanedMultipartUploads contains the multipart uploads without an info object.
+	OrphanedMultipartUploads []OrphanedMultipartUpload
+	// OrphanedPartObjects contains the keys of .part objects without an info object.
+	OrphanedPartObjects []string
+	// StaleInfoObjects contains the keys of info objects without a multipart upload.
+	// They are left behind if an upload was finished, but its info object could not
+	// be deleted afterwards. If the information is not stored in info objects (see
+	// InfoLocation and S3Store.InfoStore), it contains the uploads' object IDs instead.
+	StaleInfoObjects []string
+}
+
+// OrphanedMultipartUpload identifies a multipart upload, which is not referenced by
+// an info object.
+type OrphanedMultipartUpload struct {
+	Key       string
+	UploadId  string
+	Initiated time.Time
+}
+
+// CollectGarbage removes the leftovers of uploads which have not been cleaned up, for
+// example because tusd crashed while creating or terminating an upload. It reconciles
+// the multipart uploads under ObjectPrefix and the .info and .part objects under
+// MetadataObjectPrefix:
+//
+//   - Multipart uploads without an info object are aborted, which releases the storage
+//     used by their parts.
+//   - Info objects without a multipart upload belong to finished uploads and are deleted.
+//   - .part objects without an info object, or with a deleted one, are deleted.
+//
+// If the information about uploads is not stored in info objects, the InfoStore is
+// inspected instead and stale information is removed from it.
+//
+// Only orphans older than options.MinAge are considered. Since all multipart uploads and
+// objects ending in .info or .part under these prefixes are assumed to belong to tusd, the
+// prefixes should not be shared with other applications. The s3:ListBucket and
+// s3:ListBucketMultipartUploads permissions are required in addition to the ones
+// mentioned in the package documentation.
+func (store S3Store) CollectGarbage(ctx context.Context, options GarbageCollectionOptions) (GarbageCollectionReport, error) {
+	report := GarbageCollectionReport{
+		Bucket: store.Bucket,
+		DryRun: options.DryRun,
+	}
+
+	if options.MinAge <= 0 {
+		return report, errors.New("s3store: MinAge for garbage collection must be positive")
+	}
+	threshold := time.Now().Add(-options.MinAge)
+
+	objectPrefix := *store.keyWithPrefix("")
+	metadataPrefix := *store.metadataKeyWithPrefix("")
+
+	// Collect the info and part objects, keyed by the object ID of their upload.
+	usesInfoObjects := store.InfoStore == nil && store.InfoLocation == InfoLocationSidecar
+	infoObjects := make(map[string]types.Object)
+	partObjects := make(map[string]types.Object)
+	err := store.listObjects(ctx, metadataPrefix, func(object types.Object) {
+		key := strings.TrimPrefix(*object.Key, metadataPrefix)
+		if objectId, ok := strings.CutSuffix(key, ".info"); ok && usesInfoObjects {
+			infoObjects[objectId] = object
+		} else if objectId, ok := strings.CutSuffix(key, ".part"); ok {
+			partObjects[objectId] = object
+		}
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if !usesInfoObjects {
+		// Represent the information in the InfoStore like info objects.
+		infoObjects, err = store.listInfosAsObjects(ctx)
+		if err != nil {
+			return report, err
+		}
+	}
+
+	multipartUploads, err := store.listMultipartUploads(ctx, objectPrefix)
+	if err != nil {
+		return report, err
+	}
+
+	var errs []error
+	uploadedObjectIds := make(map[string]bool, len(multipartUploads))
+	for _, multipartUpload := range multipartUploads {
+		objectId := strings.TrimPrefix(*multipartUpload.Key, objectPrefix)
+		uploadedObjectIds[objectId] = true
+
+		if _, ok := infoObjects[objectId]; ok || !olderThan(multipartUpload.Initiated, threshold) {
+			continue
+		}
+
+		report.OrphanedMultipartUploads = append(report.OrphanedMultipartUploads, OrphanedMultipartUpload{
+			Key:       *multipartUpload.Key,
+			UploadId:  *multipartUpload.UploadId,
+			Initiated: *multipartUpload.Initiated,
+		})
+
+		if !options.DryRun {
+			if err := store.abortMultipartUpload(ctx, *multipartUpload.Key, *multipartUpload.UploadId); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	staleObjectIds := make(map[string]bool)
+	for objectId, object := range infoObjects {
+		if uploadedObjectIds[objectId] || !olderThan(object.LastModified, threshold) {
+			continue
+		}
+
+		staleObjectIds[objectId] = true
+		if usesInfoObjects {
+			report.StaleInfoObjects = append(report.StaleInfoObjects, *object.Key)
+		} else {
+			report.StaleInfoObjects = append(report.StaleInfoObjects, objectId)
+		}
+	}
+
+	for objectId, object := range partObjects {
+		if _, ok := infoObjects[objectId]; ok && !staleObjectIds[objectId] {
+			continue
+		}
+		if !olderThan(object.LastModified, threshold) {
+			continue
+		}
+
+		report.OrphanedPartObjects = append(report.OrphanedPartObjects, *object.Key)
+	}
+
+	sort.Strings(report.StaleInfoObjects)
+	sort.Strings(report.OrphanedPartObjects)
+
+	if !options.DryRun {
+		keys := append([]string{}, report.OrphanedPartObjects...)
+		if usesInfoObjects {
+			keys = append(keys, report.StaleInfoObjects...)
+		} else {
+			infoStore := store.infoStore()
+			for _, objectId := range report.StaleInfoObjects {
+				if err := infoStore.DeleteInfo(ctx, objectId); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+
+		if err := store.deleteObjects(ctx, keys); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return report, errors.Join(errs...)
+}
+
+// CollectGarbageInStores runs CollectGarbage for each of the stores, which may use
+// different buckets and S3 endpoints. A failure in one store does not prevent the
+// others from being inspected. The reports are returned in the order of the stores.
+func CollectGarbageInStores(ctx context.Context, stores []S3Store, options GarbageCollectionOptions) ([]GarbageCollectionReport, error) {
+	reports := make([]GarbageCollectionReport, 0, len(stores))
+
+	var errs []error
+	for _, store := range stores {
+		report, err := store.CollectGarbage(ctx, options)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("s3store: garbage collection in bucket %s failed: %w", store.Bucket, err))
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, errors.Join(errs...)
+}
+
+// listInfosAsObjects returns the information about this bucket's uploads in the store's
+// InfoStore, keyed by their object IDs. Only the LastModified property of the objects
+// is set.
+func (store S3Store) listInfosAsObjects(ctx context.Context) (map[string]types.Object, error) {
+	infoStore := store.infoStore()
+	objectIds, err := infoStore.ListInfos(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[string]types.Object, len(objectIds))
+	for _, objectId := range objectIds {
+		info, _, err := infoStore.GetInfo(ctx, objectId)
+		if err != nil {
+			// The upload might have been finished or removed in the meantime.
+			if errors.Is(err, models.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		if !store.ownsInfo(info) {
+			continue
+		}
+
+		objects[objectId] = types.Object{
+			LastModified: aws.Time(info.ModifiedAt),
+		}
+	}
+
+	return objects, nil
+}
+
+// olderThan returns whether the timestamp is known and before the threshold.
+func olderThan(timestamp *time.Time, threshold time.Time) bool {
+	return timestamp != nil && timestamp.Before(threshold)
+}
+
+// listObjects invokes fn for every object whose key starts with the given prefix.
+func (store S3Store) listObjects(ctx context.Context, prefix string, fn func(object types.Object)) error {
+	var continuationToken *string
+	for {
+		t := time.Now()
+		res, err := store.Service.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+			Bucket:            aws.String(store.Bucket),
+			Prefix:            aws.String(prefix),
+			ContinuationToken: continuationToken,
+		})
+		store.observeRequestDuration(t, metricListObjects)
+		if err != nil {
+			return err
+		}
+
+		for _, object := range res.Contents {
+			fn(object)
+		}
+
+		if res.IsTruncated == nil || !*res.IsTruncated {
+			return nil
+		}
+		continuationToken = res.NextContinuationToken
+	}
+}
+
+// listMultipartUploads returns all in-progress multipart uploads whose key starts
+// with the given prefix.
+func (store S3Store) listMultipartUploads(ctx context.Context, prefix string) ([]types.MultipartUpload, error) {
+	var uploads []types.MultipartUpload
+	var keyMarker, uploadIdMarker *string
+	for {
+		t := time.Now()
+		res, err := store.Service.ListMultipartUploads(ctx, &s3.ListMultipartUploadsInput{
+			Bucket:         aws.String(store.Bucket),
+			Prefix:         aws.String(prefix),
+			KeyMarker:      keyMarker,
+			UploadIdMarker: uploadIdMarker,
+		})
+		store.observeRequestDuration(t, metricListMultipartUploads)
+		if err != nil {
+			return nil, err
+		}
+
+		uploads = append(uploads, res.Uploads...)
+
+		if res.IsTruncated == nil || !*res.IsTruncated {
+			return uploads, nil
+		}
+		keyMarker = res.NextKeyMarker
+		uploadIdMarker = res.NextUploadIdMarker
+	}
+}
+
+func (store S3Store) abortMultipartUpload(ctx context.Context, key string, uploadId string) error {
+	t := time.Now()
+	_, err := store.Service.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(store.Bucket),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	})
+	store.observeRequestDuration(t, metricAbortMultipartUpload)
+	if err != nil && !isAwsError[*types.NoSuchUpload](err) {
+		return err
+	}
+
+	return nil
+}
+
+// deleteObjects removes the objects with the given keys in batches.
+func (store S3Store) deleteObjects(ctx context.Context, keys []string) error {
+	var errs []error
+	for start := 0; start < len(keys); start += deleteObjectsBatchSize {
+		end := start + deleteObjectsBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		objects := make([]types.ObjectIdentifier, 0, end-start)
+		for _, key := range keys[start:end] {
+			objects = append(objects, types.ObjectIdentifier{Key: aws.String(key)})
+		}
+
+		t := time.Now()
+		res, err := store.Service.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(store.Bucket),
+			Delete: &types.Delete{
+				Objects: objects,
+				Quiet:   aws.Bool(true),
+			},
+		})
+		store.observeRequestDuration(t, metricDeleteObjects)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, s3Err := range res.Errors {
+			if *s3Err.Code != "NoSuchKey" {
+				errs = append(errs, fmt.Errorf("AWS S3 Error (%s) for object %s: %s", *s3Err.Code, *s3Err.Key, *s3Err.Message))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestConcurrentInfoWrites(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	_, store := newFakeS3Store(t)
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{SizeIsDeferred: true})
+	assert.NoError(err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(err)
+
+	// Both writers read the same version of the information.
+	first, err := store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	_, err = first.GetInfo(ctx)
+	assert.NoError(err)
+	second, err := store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	_, err = second.GetInfo(ctx)
+	assert.NoError(err)
+
+	assert.NoError(store.AsLengthDeclarableUpload(first).DeclareLength(ctx, 10))
+	err = store.AsLengthDeclarableUpload(second).DeclareLength(ctx, 20)
+	assert.True(errors.Is(err, models.ErrInfoConflict))
+
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	info, err = upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.Equal(int64(10), info.Size)
+	assert.False(info.SizeIsDeferred)
+}
+
+func TestNewUploadWithExistingID(t *testing.T) {
+	ctx := context.Background()
+	_, store := newFakeS3Store(t)
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: 10})
+	assert.NoError(t, err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(t, err)
+	objectId, _ := splitIds(info.ID)
+
+	_, err = store.NewUpload(ctx, models.FileInfo{ID: objectId, Size: 10})
+	assert.True(t, errors.Is(err, models.ErrInfoConflict), err)
+}
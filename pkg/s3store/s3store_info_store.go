@@ -0,0 +1,323 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/susufqx/dynamic-bucket-tusd/internal/uid"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// InfoLocation controls where S3Store keeps the information about uploads, unless
+// S3Store.InfoStore is set.
+type InfoLocation int
+
+const (
+	// InfoLocationSidecar stores the information as JSON in an object next to the
+	// upload, whose key is the upload's object ID with the suffix ".info" under
+	// MetadataObjectPrefix.
+	InfoLocationSidecar InfoLocation = iota
+	// InfoLocationMetadata stores the information in the metadata of a small
+	// placeholder object under the upload's final key, which is replaced by the
+	// final object once the upload is finished. No other objects are created. Since
+	// S3 limits an object's metadata to 2KB, uploads with large metadata or many
+	// directly uploaded parts cannot be stored this way.
+	InfoLocationMetadata
+)
+
+// infoMetadataKey is the metadata key, under which InfoLocationMetadata stores the
+// Base64-encoded information.
+const infoMetadataKey = "tusd-info"
+
+// maxInfoMetadataSize is the maximum size of the encoded information, which fits into
+// an object's metadata including the key.
+const maxInfoMetadataSize = 2048 - len(infoMetadataKey)
+
+// maxPlaceholderSize is the maximum size of placeholder objects created by
+// InfoLocationMetadata.
+const maxPlaceholderSize = 64
+
+// NewInfoStore returns an InfoStore, which keeps the information about uploads in this
+// store's bucket at the given location. It can be used with models.MigrateInfos to move
+// the information of existing uploads between locations or to S3Store.InfoStore.
+func (store S3Store) NewInfoStore(location InfoLocation) models.InfoStore {
+	if location == InfoLocationMetadata {
+		return metadataInfoStore{store}
+	}
+	return sidecarInfoStore{store}
+}
+
+// infoStore returns the InfoStore used for the uploads of this store.
+func (store S3Store) infoStore() models.InfoStore {
+	if store.InfoStore != nil {
+		return store.InfoStore
+	}
+	return store.NewInfoStore(store.InfoLocation)
+}
+
+// ownsInfo returns whether the information belongs to an upload in this store's bucket.
+// This is not the case if S3Store.InfoStore is shared by stores for different buckets.
+func (store S3Store) ownsInfo(info models.FileInfo) bool {
+	bucket, ok := info.Storage["Bucket"]
+	return !ok || bucket == store.Bucket
+}
+
+// conditionalPut returns the options for a PutObject request, which only succeeds if
+// the object's ETag equals the given version, or if the object does not exist for an
+// empty version. The SDK does not expose the conditional headers for PutObject yet,
+// so they are added to the request directly.
+func (store S3Store) conditionalPut(version string) []func(*s3.Options) {
+	if store.DisableConditionalInfoWrites {
+		return nil
+	}
+
+	condition := smithyhttp.SetHeaderValue("If-None-Match", "*")
+	if version != "" {
+		condition = smithyhttp.SetHeaderValue("If-Match", version)
+	}
+	return []func(*s3.Options){s3.WithAPIOptions(condition)}
+}
+
+// isConditionFailed returns whether a conditional PutObject request was rejected.
+// AWS S3 responds with 409 ConditionalRequestConflict if a conflicting write is
+// still in progress.
+func isConditionFailed(err error) bool {
+	return isAwsErrorCode(err, "PreconditionFailed") || isAwsErrorCode(err, "ConditionalRequestConflict")
+}
+
+// sidecarInfoStore implements InfoLocationSidecar.
+type sidecarInfoStore struct {
+	store S3Store
+}
+
+func (infoStore sidecarInfoStore) key(id string) *string {
+	return infoStore.store.metadataKeyWithPrefix(id + ".info")
+}
+
+func (infoStore sidecarInfoStore) GetInfo(ctx context.Context, id string) (info models.FileInfo, version string, err error) {
+	store := infoStore.store
+
+	t := time.Now()
+	res, err := store.Service.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    infoStore.key(id),
+	})
+	store.observeRequestDuration(t, metricGetInfoObject)
+	if err != nil {
+		if isAwsError[*types.NoSuchKey](err) {
+			err = models.ErrNotFound
+		}
+		return info, "", err
+	}
+	defer res.Body.Close()
+
+	if err := json.NewDecoder(res.Body).Decode(&info); err != nil {
+		return info, "", err
+	}
+	info.ModifiedAt = aws.ToTime(res.LastModified)
+
+	return info, aws.ToString(res.ETag), nil
+}
+
+func (infoStore sidecarInfoStore) PutInfo(ctx context.Context, id string, info models.FileInfo, version string) (string, error) {
+	store := infoStore.store
+
+	infoJson, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+
+	t := time.Now()
+	res, err := store.Service.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(store.Bucket),
+		Key:           infoStore.key(id),
+		Body:          bytes.NewReader(infoJson),
+		ContentLength: aws.Int64(int64(len(infoJson))),
+	}, store.conditionalPut(version)...)
+	store.observeRequestDuration(t, metricPutInfoObject)
+	if err != nil {
+		if isConditionFailed(err) {
+			err = models.ErrInfoConflict
+		}
+		return "", err
+	}
+
+	return aws.ToString(res.ETag), nil
+}
+
+func (infoStore sidecarInfoStore) DeleteInfo(ctx context.Context, id string) error {
+	store := infoStore.store
+
+	_, err := store.Service.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    infoStore.key(id),
+	})
+	if err != nil && !isAwsError[*types.NoSuchKey](err) {
+		return err
+	}
+
+	return nil
+}
+
+func (infoStore sidecarInfoStore) ListInfos(ctx context.Context) ([]string, error) {
+	prefix := *infoStore.store.metadataKeyWithPrefix("")
+
+	var ids []string
+	err := infoStore.store.listObjects(ctx, prefix, func(object types.Object) {
+		if id, ok := strings.CutSuffix(strings.TrimPrefix(*object.Key, prefix), ".info"); ok {
+			ids = append(ids, id)
+		}
+	})
+
+	return ids, err
+}
+
+// metadataInfoStore implements InfoLocationMetadata. The placeholder object contains a
+// random value, so that its ETag changes with every write and can be used as version.
+type metadataInfoStore struct {
+	store S3Store
+}
+
+// headPlaceholder returns the encoded information and the ETag of the placeholder
+// object. ErrNotFound is returned if the object does not exist or is not a placeholder,
+// for example because the upload has been finished.
+func (infoStore metadataInfoStore) headPlaceholder(ctx context.Context, id string) (encoded string, res *s3.HeadObjectOutput, err error) {
+	store := infoStore.store
+
+	t := time.Now()
+	res, err = store.Service.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    store.keyWithPrefix(id),
+	})
+	store.observeRequestDuration(t, metricGetInfoObject)
+	if err != nil {
+		if isAwsError[*types.NoSuchKey](err) || isAwsError[*types.NotFound](err) {
+			err = models.ErrNotFound
+		}
+		return "", nil, err
+	}
+
+	encoded, ok := res.Metadata[infoMetadataKey]
+	if !ok {
+		return "", nil, models.ErrNotFound
+	}
+
+	return encoded, res, nil
+}
+
+func (infoStore metadataInfoStore) GetInfo(ctx context.Context, id string) (info models.FileInfo, version string, err error) {
+	encoded, res, err := infoStore.headPlaceholder(ctx, id)
+	if err != nil {
+		return info, "", err
+	}
+
+	infoJson, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return info, "", fmt.Errorf("s3store: invalid upload information in object metadata: %w", err)
+	}
+	if err := json.Unmarshal(infoJson, &info); err != nil {
+		return info, "", err
+	}
+	info.ModifiedAt = aws.ToTime(res.LastModified)
+
+	return info, aws.ToString(res.ETag), nil
+}
+
+func (infoStore metadataInfoStore) PutInfo(ctx context.Context, id string, info models.FileInfo, version string) (string, error) {
+	store := infoStore.store
+
+	infoJson, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(infoJson)
+	if len(encoded) > maxInfoMetadataSize {
+		return "", fmt.Errorf("s3store: upload information of %d bytes exceeds the size limit of object metadata", len(infoJson))
+	}
+
+	body := []byte(uid.Uid())
+
+	t := time.Now()
+	res, err := store.Service.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(store.Bucket),
+		Key:           store.keyWithPrefix(id),
+		Body:          bytes.NewReader(body),
+		ContentLength: aws.Int64(int64(len(body))),
+		Metadata: map[string]string{
+			infoMetadataKey: encoded,
+		},
+	}, store.conditionalPut(version)...)
+	store.observeRequestDuration(t, metricPutInfoObject)
+	if err != nil {
+		if isConditionFailed(err) {
+			err = models.ErrInfoConflict
+		}
+		return "", err
+	}
+
+	return aws.ToString(res.ETag), nil
+}
+
+// DeleteInfo removes the placeholder object. The final object of a finished upload, which
+// has replaced the placeholder, is kept.
+func (infoStore metadataInfoStore) DeleteInfo(ctx context.Context, id string) error {
+	store := infoStore.store
+
+	_, _, err := infoStore.headPlaceholder(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	_, err = store.Service.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(store.Bucket),
+		Key:    store.keyWithPrefix(id),
+	})
+	if err != nil && !isAwsError[*types.NoSuchKey](err) {
+		return err
+	}
+
+	return nil
+}
+
+// ListInfos inspects the metadata of all objects under ObjectPrefix, which are small
+// enough to be placeholder objects.
+func (infoStore metadataInfoStore) ListInfos(ctx context.Context) ([]string, error) {
+	prefix := *infoStore.store.keyWithPrefix("")
+
+	var candidates []string
+	err := infoStore.store.listObjects(ctx, prefix, func(object types.Object) {
+		if aws.ToInt64(object.Size) <= maxPlaceholderSize {
+			candidates = append(candidates, strings.TrimPrefix(*object.Key, prefix))
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(candidates))
+	for _, id := range candidates {
+		if _, _, err := infoStore.headPlaceholder(ctx, id); err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
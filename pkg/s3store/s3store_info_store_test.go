@@ -0,0 +1,151 @@
+package s3store
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestInfoStores(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	for _, location := range []InfoLocation{InfoLocationSidecar, InfoLocationMetadata} {
+		_, store := newFakeS3Store(t)
+		infoStore := store.NewInfoStore(location)
+
+		_, _, err := infoStore.GetInfo(ctx, "a")
+		assert.ErrorIs(err, models.ErrNotFound, "location %d", location)
+
+		version, err := infoStore.PutInfo(ctx, "a", models.FileInfo{ID: "a", Size: 10}, "")
+		assert.NoError(err)
+		assert.NotEmpty(version)
+		_, err = infoStore.PutInfo(ctx, "a", models.FileInfo{ID: "a", Size: 20}, "")
+		assert.ErrorIs(err, models.ErrInfoConflict, "location %d", location)
+
+		info, current, err := infoStore.GetInfo(ctx, "a")
+		assert.NoError(err)
+		assert.Equal(version, current)
+		assert.EqualValues(10, info.Size)
+		assert.False(info.ModifiedAt.IsZero())
+
+		newVersion, err := infoStore.PutInfo(ctx, "a", models.FileInfo{ID: "a", Size: 20}, version)
+		assert.NoError(err)
+		assert.NotEqual(version, newVersion)
+		_, err = infoStore.PutInfo(ctx, "a", models.FileInfo{ID: "a", Size: 30}, version)
+		assert.ErrorIs(err, models.ErrInfoConflict, "location %d", location)
+
+		_, err = infoStore.PutInfo(ctx, "b", models.FileInfo{ID: "b"}, "")
+		assert.NoError(err)
+		ids, err := infoStore.ListInfos(ctx)
+		assert.NoError(err)
+		sort.Strings(ids)
+		assert.Equal([]string{"a", "b"}, ids)
+
+		assert.NoError(infoStore.DeleteInfo(ctx, "b"))
+		assert.NoError(infoStore.DeleteInfo(ctx, "b"))
+		_, _, err = infoStore.GetInfo(ctx, "b")
+		assert.ErrorIs(err, models.ErrNotFound, "location %d", location)
+		ids, err = infoStore.ListInfos(ctx)
+		assert.NoError(err)
+		assert.Equal([]string{"a"}, ids)
+	}
+}
+
+func TestMetadataInfoStoreSizeLimit(t *testing.T) {
+	ctx := context.Background()
+	_, store := newFakeS3Store(t)
+	infoStore := store.NewInfoStore(InfoLocationMetadata)
+
+	info := models.FileInfo{ID: "a", MetaData: models.MetaData{"filename": strings.Repeat("a", 2048)}}
+	_, err := infoStore.PutInfo(ctx, "a", info, "")
+	assert.ErrorContains(t, err, "exceeds the size limit")
+}
+
+func TestMetadataInfoLocation(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	fake, store := newFakeS3Store(t)
+	store.InfoLocation = InfoLocationMetadata
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{SizeIsDeferred: true, MetaData: models.MetaData{"filename": "hello.txt"}})
+	assert.NoError(err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(err)
+	objectId, _ := splitIds(info.ID)
+
+	// No .info object is created next to the upload.
+	assert.Nil(fake.object(objectId + ".info"))
+	assert.NotNil(fake.object(objectId))
+
+	_, err = upload.WriteChunk(ctx, 0, strings.NewReader("hello "))
+	assert.NoError(err)
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	assert.NoError(store.AsLengthDeclarableUpload(upload).DeclareLength(ctx, 12))
+	_, err = upload.WriteChunk(ctx, 6, strings.NewReader("world!"))
+	assert.NoError(err)
+
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	info, err = upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.EqualValues(12, info.Offset)
+	assert.EqualValues(12, info.Size)
+	assert.Equal("hello.txt", info.MetaData["filename"])
+	assert.NoError(upload.FinishUpload(ctx))
+
+	// The final object replaces the placeholder.
+	assert.Equal("hello world!", string(fake.object(objectId).data))
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	reader, err := upload.GetReader(ctx)
+	assert.NoError(err)
+	content, err := io.ReadAll(reader)
+	assert.NoError(err)
+	reader.Close()
+	assert.Equal("hello world!", string(content))
+}
+
+func TestMigrateToMetadataInfoLocation(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	fake, store := newFakeS3Store(t)
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: 12})
+	assert.NoError(err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(err)
+	objectId, _ := splitIds(info.ID)
+	_, err = upload.WriteChunk(ctx, 0, strings.NewReader("hello "))
+	assert.NoError(err)
+
+	report, err := models.MigrateInfos(ctx, store.NewInfoStore(InfoLocationSidecar), store.NewInfoStore(InfoLocationMetadata), models.InfoMigrationOptions{DeleteSource: true})
+	assert.NoError(err)
+	assert.Equal([]string{objectId}, report.Migrated)
+	assert.Empty(report.Skipped)
+	assert.Nil(fake.object(objectId + ".info"))
+
+	// The upload can be resumed using the new location.
+	store.InfoLocation = InfoLocationMetadata
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	info, err = upload.GetInfo(ctx)
+	assert.NoError(err)
+	assert.EqualValues(6, info.Offset)
+	_, err = upload.WriteChunk(ctx, 6, strings.NewReader("world!"))
+	assert.NoError(err)
+	assert.NoError(upload.FinishUpload(ctx))
+	assert.Equal("hello world!", string(fake.object(objectId).data))
+
+	// Repeating the migration does not change anything.
+	report, err = models.MigrateInfos(ctx, store.NewInfoStore(InfoLocationSidecar), store.NewInfoStore(InfoLocationMetadata), models.InfoMigrationOptions{DeleteSource: true})
+	assert.NoError(err)
+	assert.Empty(report.Migrated)
+	assert.Empty(report.Skipped)
+}
@@ -0,0 +1,132 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// ListUploads returns the unfinished uploads in this bucket matching the options. The
+// uploads are found by combining the InfoStore, i.e. the info objects by default, with
+// the multipart uploads under ObjectPrefix. Since the information is removed once an
+// upload is finished, finished uploads are not listed. For uploads created before their
+// creation time was recorded, the initiation time of the multipart upload is used.
+//
+// Every listed upload requires several requests to S3, so the limit should be chosen
+// accordingly. The s3:ListBucket and s3:ListBucketMultipartUploads permissions are
+// required in addition to the ones mentioned in the package documentation.
+func (store S3Store) ListUploads(ctx context.Context, options models.ListUploadsOptions) (models.ListUploadsResult, error) {
+	infoStore := store.infoStore()
+	objectIds, err := infoStore.ListInfos(ctx)
+	if err != nil {
+		return models.ListUploadsResult{}, err
+	}
+	hasInfo := make(map[string]bool, len(objectIds))
+	for _, objectId := range objectIds {
+		hasInfo[objectId] = true
+	}
+
+	objectPrefix := *store.keyWithPrefix("")
+	multipartUploads, err := store.listMultipartUploads(ctx, objectPrefix)
+	if err != nil {
+		return models.ListUploadsResult{}, err
+	}
+
+	// Multipart uploads without information are orphaned and cannot be resumed.
+	initiated := make(map[string]time.Time, len(multipartUploads))
+	ids := make([]string, 0, len(multipartUploads))
+	for _, multipartUpload := range multipartUploads {
+		objectId := strings.TrimPrefix(*multipartUpload.Key, objectPrefix)
+		if !hasInfo[objectId] {
+			continue
+		}
+
+		id := objectId + "+" + *multipartUpload.UploadId
+		initiated[id] = aws.ToTime(multipartUpload.Initiated)
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	now := time.Now()
+	limit := options.PageLimit()
+	result := models.ListUploadsResult{
+		Uploads: make([]models.FileInfo, 0),
+	}
+	for _, id := range ids {
+		if (options.Cursor != "" && id <= options.Cursor) || !strings.HasPrefix(id, options.Prefix) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		info, err := store.listedInfo(ctx, id, initiated[id], options, now)
+		if err != nil {
+			// The upload might have been finished or removed in the meantime.
+			if errors.Is(err, models.ErrNotFound) {
+				continue
+			}
+			return result, err
+		}
+		if info == nil {
+			continue
+		}
+
+		if len(result.Uploads) == limit {
+			result.NextCursor = result.Uploads[limit-1].ID
+			break
+		}
+		result.Uploads = append(result.Uploads, *info)
+	}
+
+	return result, nil
+}
+
+// listedInfo returns the information about the upload, or nil if it does not match
+// the options. The stored information is checked first, so that the parts only have
+// to be fetched for uploads, which may match.
+func (store S3Store) listedInfo(ctx context.Context, id string, initiated time.Time, options models.ListUploadsOptions, now time.Time) (*models.FileInfo, error) {
+	objectId, _ := splitIds(id)
+	storedInfo, _, err := store.infoStore().GetInfo(ctx, objectId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Another multipart upload for the same key does not belong to the upload.
+	if storedInfo.ID != id || !store.ownsInfo(storedInfo) {
+		return nil, nil
+	}
+	if storedInfo.CreatedAt.IsZero() {
+		storedInfo.CreatedAt = initiated
+	}
+
+	// The state depends on the offset, which is not known yet.
+	storedOptions := options
+	storedOptions.State = ""
+	if !storedOptions.Matches(storedInfo, now) {
+		return nil, nil
+	}
+
+	upload, err := store.GetUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	info, err := upload.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if info.CreatedAt.IsZero() {
+		info.CreatedAt = initiated
+	}
+
+	if !options.Matches(info, now) {
+		return nil, nil
+	}
+
+	return &info, nil
+}
@@ -0,0 +1,70 @@
+package s3store
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestListUploads(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	_, store := newFakeS3Store(t)
+
+	var ids []string
+	for _, kind := range []string{"a", "b", "a"} {
+		upload, err := store.NewUpload(ctx, models.FileInfo{Size: 10, MetaData: models.MetaData{"kind": kind}})
+		assert.NoError(err)
+		info, err := upload.GetInfo(ctx)
+		assert.NoError(err)
+		ids = append(ids, info.ID)
+	}
+
+	// Finished uploads are not listed.
+	finished, err := store.GetUpload(ctx, ids[2])
+	assert.NoError(err)
+	_, err = finished.WriteChunk(ctx, 0, strings.NewReader("0123456789"))
+	assert.NoError(err)
+	assert.NoError(finished.FinishUpload(ctx))
+
+	// Multipart uploads without information cannot be resumed and are not listed.
+	orphan, err := store.NewUpload(ctx, models.FileInfo{Size: 10})
+	assert.NoError(err)
+	info, err := orphan.GetInfo(ctx)
+	assert.NoError(err)
+	objectId, _ := splitIds(info.ID)
+	assert.NoError(store.NewInfoStore(InfoLocationSidecar).DeleteInfo(ctx, objectId))
+
+	result, err := store.ListUploads(ctx, models.ListUploadsOptions{})
+	assert.NoError(err)
+	listed := make([]string, 0)
+	for _, info := range result.Uploads {
+		listed = append(listed, info.ID)
+		assert.False(info.CreatedAt.IsZero())
+	}
+	assert.ElementsMatch(ids[:2], listed)
+	assert.Empty(result.NextCursor)
+
+	result, err = store.ListUploads(ctx, models.ListUploadsOptions{MetaDataKey: "kind", MetaDataValue: "a"})
+	assert.NoError(err)
+	assert.Len(result.Uploads, 1)
+	assert.Equal(ids[0], result.Uploads[0].ID)
+
+	result, err = store.ListUploads(ctx, models.ListUploadsOptions{State: models.UploadStateFinished})
+	assert.NoError(err)
+	assert.Empty(result.Uploads)
+
+	// The uploads can be retrieved page by page.
+	result, err = store.ListUploads(ctx, models.ListUploadsOptions{Limit: 1})
+	assert.NoError(err)
+	assert.Len(result.Uploads, 1)
+	assert.Equal(result.Uploads[0].ID, result.NextCursor)
+	next, err := store.ListUploads(ctx, models.ListUploadsOptions{Limit: 1, Cursor: result.NextCursor})
+	assert.NoError(err)
+	assert.Len(next.Uploads, 1)
+	assert.Less(result.Uploads[0].ID, next.Uploads[0].ID)
+	assert.Empty(next.NextCursor)
+}
@@ -0,0 +1,217 @@
+package s3store
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// memoryArenaBlockSize is the size of the blocks in which a MemoryArena hands out memory.
+const memoryArenaBlockSize = 1024 * 1024
+
+// MemoryArena is a bounded pool of memory which S3Store can use to buffer parts
+// instead of writing them to temporary files. Memory is handed out in blocks of
+// 1MB, which are reused once a part has been uploaded. If the limit is reached,
+// reading further parts blocks until enough memory is released, which slows down
+// the incoming uploads. A single arena can be shared between multiple stores to
+// bound their combined memory usage.
+type MemoryArena struct {
+	limit int64
+
+	mu   sync.Mutex
+	used int64
+	// released is closed and replaced whenever memory is released, waking up all
+	// goroutines waiting for memory.
+	released chan struct{}
+
+	blocks sync.Pool
+}
+
+// NewMemoryArena creates an arena which buffers at most limit bytes. The limit is
+// rounded down to a multiple of the block size, but is at least one block.
+func NewMemoryArena(limit int64) *MemoryArena {
+	limit = limit / memoryArenaBlockSize * memoryArenaBlockSize
+	if limit < memoryArenaBlockSize {
+		limit = memoryArenaBlockSize
+	}
+
+	return &MemoryArena{
+		limit:    limit,
+		released: make(chan struct{}),
+		blocks: sync.Pool{
+			New: func() any {
+				block := make([]byte, memoryArenaBlockSize)
+				return &block
+			},
+		},
+	}
+}
+
+// Limit returns the maximum number of bytes the arena buffers.
+func (arena *MemoryArena) Limit() int64 {
+	return arena.limit
+}
+
+// Used returns the number of bytes which are currently reserved in the arena.
+func (arena *MemoryArena) Used() int64 {
+	arena.mu.Lock()
+	defer arena.mu.Unlock()
+
+	return arena.used
+}
+
+// fits returns whether a part of the given size can be buffered in the arena at all.
+func (arena *MemoryArena) fits(size int64) bool {
+	return roundUpToBlockSize(size) <= arena.limit
+}
+
+// reserve blocks until the given amount of memory is available and reserves it.
+// The whole amount is reserved at once, so that two parts waiting for each other's
+// memory can never deadlock.
+func (arena *MemoryArena) reserve(ctx context.Context, size int64) error {
+	for {
+		arena.mu.Lock()
+		if arena.used+size <= arena.limit {
+			arena.used += size
+			arena.mu.Unlock()
+			return nil
+		}
+		released := arena.released
+		arena.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (arena *MemoryArena) release(size int64) {
+	if size == 0 {
+		return
+	}
+
+	arena.mu.Lock()
+	arena.used -= size
+	close(arena.released)
+	arena.released = make(chan struct{})
+	arena.mu.Unlock()
+}
+
+// readPart reads up to size bytes from the reader into memory from the arena. If
+// the reader is already exhausted, a part with a size of 0 is returned.
+func (arena *MemoryArena) readPart(ctx context.Context, r io.Reader, size int64) (*memoryPart, error) {
+	reserved := roundUpToBlockSize(size)
+	if err := arena.reserve(ctx, reserved); err != nil {
+		return nil, err
+	}
+
+	part := &memoryPart{
+		arena:    arena,
+		reserved: reserved,
+	}
+
+	for part.size < size {
+		block := arena.blocks.Get().(*[]byte)
+		want := size - part.size
+		if want > memoryArenaBlockSize {
+			want = memoryArenaBlockSize
+		}
+
+		n, err := io.ReadFull(r, (*block)[:want])
+		if n > 0 {
+			part.blocks = append(part.blocks, block)
+			part.size += int64(n)
+		} else {
+			arena.blocks.Put(block)
+		}
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			part.Close()
+			return nil, err
+		}
+	}
+
+	// Give back the memory which was reserved but not needed since the reader
+	// did not provide enough data.
+	used := roundUpToBlockSize(part.size)
+	arena.release(part.reserved - used)
+	part.reserved = used
+
+	return part, nil
+}
+
+// memoryPart is a part buffered in blocks from a MemoryArena. Only the last block
+// may be partially filled.
+type memoryPart struct {
+	arena    *MemoryArena
+	blocks   []*[]byte
+	size     int64
+	reserved int64
+	offset   int64
+
+	closeOnce sync.Once
+}
+
+func (part *memoryPart) Read(p []byte) (int, error) {
+	if part.offset >= part.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && part.offset < part.size {
+		block := *part.blocks[part.offset/memoryArenaBlockSize]
+		start := part.offset % memoryArenaBlockSize
+		end := int64(memoryArenaBlockSize)
+		if remaining := part.size - part.offset; start+remaining < end {
+			end = start + remaining
+		}
+
+		copied := copy(p[n:], block[start:end])
+		n += copied
+		part.offset += int64(copied)
+	}
+
+	return n, nil
+}
+
+func (part *memoryPart) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += part.offset
+	case io.SeekEnd:
+		offset += part.size
+	default:
+		return 0, errors.New("s3store: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("s3store: negative position")
+	}
+
+	part.offset = offset
+	return offset, nil
+}
+
+// Close returns the blocks to the arena. The part must not be read afterwards.
+func (part *memoryPart) Close() error {
+	part.closeOnce.Do(func() {
+		for _, block := range part.blocks {
+			part.arena.blocks.Put(block)
+		}
+		part.blocks = nil
+		part.arena.release(part.reserved)
+	})
+
+	return nil
+}
+
+func roundUpToBlockSize(size int64) int64 {
+	return (size + memoryArenaBlockSize - 1) / memoryArenaBlockSize * memoryArenaBlockSize
+}
@@ -0,0 +1,117 @@
+package s3store
+
+import (
+	"encoding/base64"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// MetadataEncoding controls how metadata values, which cannot be represented in S3 object
+// metadata, are stored on the multipart upload and thereby on the final object. S3 only
+// accepts printable ASCII characters in metadata values.
+type MetadataEncoding int
+
+const (
+	// MetadataEncodingReplace replaces every character, which is not printable ASCII,
+	// with a question mark, e.g. "Menü" becomes "Men?". This cannot be reversed.
+	MetadataEncodingReplace MetadataEncoding = iota
+	// MetadataEncodingRFC2047 stores values containing such characters as RFC 2047
+	// encoded-words using UTF-8 and Base64, e.g. "=?UTF-8?b?TWVuw7w=?=" for "Menü".
+	// Many mail and HTTP libraries, such as mime.WordDecoder, can decode them.
+	MetadataEncodingRFC2047
+	// MetadataEncodingBase64 stores values containing such characters Base64-encoded
+	// with the MetadataBase64Prefix, e.g. "b64:TWVuw7w=" for "Menü".
+	MetadataEncodingBase64
+)
+
+// MetadataBase64Prefix marks metadata values encoded using MetadataEncodingBase64.
+const MetadataBase64Prefix = "b64:"
+
+// maxEncodedWordBytes is the number of bytes encoded in a single RFC 2047 encoded-word,
+// so that the encoded-word does not exceed the limit of 75 characters.
+const maxEncodedWordBytes = 45
+
+// EncodeMetadataValue encodes a metadata value for storing it in S3 object metadata.
+// Values, which only consist of printable ASCII characters, are stored unchanged,
+// unless they could be mistaken for an encoded value.
+func EncodeMetadataValue(encoding MetadataEncoding, value string) string {
+	switch encoding {
+	case MetadataEncodingRFC2047:
+		if nonPrintableRegexp.MatchString(value) || strings.Contains(value, "=?") {
+			return encodeWords(value)
+		}
+	case MetadataEncodingBase64:
+		if nonPrintableRegexp.MatchString(value) || strings.HasPrefix(value, MetadataBase64Prefix) {
+			return MetadataBase64Prefix + base64.StdEncoding.EncodeToString([]byte(value))
+		}
+	default:
+		return nonPrintableRegexp.ReplaceAllString(value, "?")
+	}
+
+	return value
+}
+
+// DecodeMetadataValue reverses EncodeMetadataValue. Values, which are not encoded or
+// cannot be decoded, are returned unchanged.
+func DecodeMetadataValue(encoding MetadataEncoding, value string) string {
+	switch encoding {
+	case MetadataEncodingRFC2047:
+		decoded, err := new(mime.WordDecoder).DecodeHeader(value)
+		if err == nil {
+			return decoded
+		}
+	case MetadataEncodingBase64:
+		if encoded, ok := strings.CutPrefix(value, MetadataBase64Prefix); ok {
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err == nil {
+				return string(decoded)
+			}
+		}
+	}
+
+	return value
+}
+
+// encodeMetadata encodes all values of the metadata using the store's MetadataEncoding.
+func (store S3Store) encodeMetadata(metadata map[string]string) map[string]string {
+	encoded := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		encoded[key] = EncodeMetadataValue(store.MetadataEncoding, value)
+	}
+	return encoded
+}
+
+// decodeMetadata decodes all values of the metadata using the store's MetadataEncoding.
+func (store S3Store) decodeMetadata(metadata map[string]string) map[string]string {
+	decoded := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		decoded[key] = DecodeMetadataValue(store.MetadataEncoding, value)
+	}
+	return decoded
+}
+
+// encodeWords encodes the entire value as a sequence of RFC 2047 encoded-words. Unlike
+// mime.BEncoding, it also encodes ASCII-only values. Multi-byte characters are never
+// split across words.
+func encodeWords(value string) string {
+	var words []string
+	for len(value) > 0 {
+		n := len(value)
+		if n > maxEncodedWordBytes {
+			n = maxEncodedWordBytes
+			for n > 0 && !utf8.RuneStart(value[n]) {
+				n--
+			}
+			if n == 0 {
+				// The value is not valid UTF-8, so there is no character to keep intact.
+				n = maxEncodedWordBytes
+			}
+		}
+
+		words = append(words, "=?UTF-8?b?"+base64.StdEncoding.EncodeToString([]byte(value[:n]))+"?=")
+		value = value[n:]
+	}
+
+	return strings.Join(words, " ")
+}
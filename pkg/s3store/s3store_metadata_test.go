@@ -0,0 +1,53 @@
+package s3store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataEncodingRoundTrip(t *testing.T) {
+	values := []string{
+		"",
+		"hello.txt",
+		"Menü",
+		"=?UTF-8?b?TWVuw7w=?=",
+		"b64:aGVsbG8=",
+		strings.Repeat("ü", 100),
+		strings.Repeat("a", 44) + "€" + strings.Repeat("b", 60),
+		"valid \xff\xfe invalid",
+		strings.Repeat("\x80", 50),
+		strings.Repeat("\x80", 200) + "ü",
+	}
+
+	for _, encoding := range []MetadataEncoding{MetadataEncodingRFC2047, MetadataEncodingBase64} {
+		for _, value := range values {
+			encoded := EncodeMetadataValue(encoding, value)
+			assert.False(t, nonPrintableRegexp.MatchString(encoded), "encoded value %q is not printable", encoded)
+			assert.Equal(t, value, DecodeMetadataValue(encoding, encoded), "encoding %d of %q", encoding, value)
+		}
+	}
+}
+
+func TestMetadataEncodingReplace(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("hello.txt", EncodeMetadataValue(MetadataEncodingReplace, "hello.txt"))
+	assert.Equal("Men?", EncodeMetadataValue(MetadataEncodingReplace, "Menü"))
+	assert.Equal("Men?", DecodeMetadataValue(MetadataEncodingReplace, "Men?"))
+}
+
+func TestEncodeWords(t *testing.T) {
+	assert := assert.New(t)
+
+	words := strings.Split(encodeWords(strings.Repeat("ü", 100)), " ")
+	assert.Len(words, 5)
+	for _, word := range words {
+		assert.LessOrEqual(len(word), 75)
+	}
+
+	// Values, which are not valid UTF-8, are split at the byte limit.
+	words = strings.Split(encodeWords(strings.Repeat("\x80", 100)), " ")
+	assert.Len(words, 3)
+}
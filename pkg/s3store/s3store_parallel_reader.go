@@ -0,0 +1,161 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// rangeResult is the outcome of downloading a single range of an object.
+type rangeResult struct {
+	data []byte
+	err  error
+}
+
+// parallelReader downloads an object using multiple concurrent ranged GetObject
+// requests and reassembles the ranges in order. At most DownloadConcurrency ranges
+// are downloaded ahead of the reader, which bounds the memory usage.
+type parallelReader struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// ranges contains the pending results in the order in which they must be read.
+	ranges  <-chan chan rangeResult
+	current *bytes.Reader
+	err     error
+}
+
+// newParallelReader continues the download of the object with the given key, whose
+// first range has already been requested in first. If the response contains the entire
+// object, its body is returned directly. If the object is modified during the download,
+// reading fails.
+func (store S3Store) newParallelReader(ctx context.Context, key *string, first *s3.GetObjectOutput) (io.ReadCloser, error) {
+	size, ok := parseContentRangeSize(first.ContentRange)
+	if !ok || first.ContentLength == nil || *first.ContentLength >= size {
+		return first.Body, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ranges := make(chan chan rangeResult, store.DownloadConcurrency)
+	reader := &parallelReader{
+		ctx:    ctx,
+		cancel: cancel,
+		ranges: ranges,
+	}
+
+	go func() {
+		defer close(ranges)
+
+		for start := int64(0); start < size; start += store.DownloadPartSize {
+			end := start + store.DownloadPartSize - 1
+			if end >= size {
+				end = size - 1
+			}
+
+			result := make(chan rangeResult, 1)
+			select {
+			case ranges <- result:
+			case <-ctx.Done():
+				if start == 0 {
+					first.Body.Close()
+				}
+				return
+			}
+
+			go func(start, end int64, result chan<- rangeResult) {
+				var body io.ReadCloser
+				if start == 0 {
+					body = first.Body
+				} else {
+					// The ranges must come from the same version of the object as the
+					// first one. Otherwise, data from different versions would be mixed,
+					// if the object is overwritten during the download.
+					res, err := store.Service.GetObject(ctx, &s3.GetObjectInput{
+						Bucket:  aws.String(store.Bucket),
+						Key:     key,
+						Range:   aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+						IfMatch: first.ETag,
+					})
+					if isAwsErrorCode(err, "PreconditionFailed") {
+						err = fmt.Errorf("s3store: object %s changed during download: %w", *key, err)
+					}
+					if err != nil {
+						result <- rangeResult{err: err}
+						return
+					}
+					body = res.Body
+				}
+				defer body.Close()
+
+				data, err := io.ReadAll(body)
+				if err == nil && int64(len(data)) != end-start+1 {
+					err = fmt.Errorf("s3store: expected %d bytes for range %d-%d but received %d", end-start+1, start, end, len(data))
+				}
+				result <- rangeResult{data: data, err: err}
+			}(start, end, result)
+		}
+	}()
+
+	return reader, nil
+}
+
+func (reader *parallelReader) Read(p []byte) (int, error) {
+	for {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+
+		if reader.current != nil && reader.current.Len() > 0 {
+			return reader.current.Read(p)
+		}
+
+		result, ok := <-reader.ranges
+		if !ok {
+			reader.err = io.EOF
+			continue
+		}
+
+		select {
+		case res := <-result:
+			if res.err != nil {
+				reader.err = res.err
+				continue
+			}
+			reader.current = bytes.NewReader(res.data)
+		case <-reader.ctx.Done():
+			reader.err = reader.ctx.Err()
+		}
+	}
+}
+
+// Close stops all pending downloads.
+func (reader *parallelReader) Close() error {
+	reader.cancel()
+	return nil
+}
+
+// parseContentRangeSize extracts the complete length from a Content-Range header,
+// e.g. 1234 from "bytes 0-99/1234".
+func parseContentRangeSize(contentRange *string) (int64, bool) {
+	if contentRange == nil {
+		return 0, false
+	}
+
+	_, sizeStr, ok := strings.Cut(*contentRange, "/")
+	if !ok {
+		return 0, false
+	}
+
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return size, true
+}
@@ -0,0 +1,100 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// newFinishedUpload creates an upload with the given content and finishes it.
+func newFinishedUpload(t *testing.T, store S3Store, content string) models.Upload {
+	ctx := context.Background()
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: int64(len(content))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := upload.WriteChunk(ctx, 0, bytes.NewReader([]byte(content))); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload.FinishUpload(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return upload
+}
+
+func TestParallelReader(t *testing.T) {
+	_, store := newFakeS3Store(t)
+	store.DownloadConcurrency = 3
+	store.DownloadPartSize = 4
+
+	for _, content := range []string{"", "abc", "abcd", "0123456789abcdefghijABCDEFGHIJ"} {
+		upload := newFinishedUpload(t, store, content)
+
+		reader, err := upload.GetReader(context.Background())
+		assert.NoError(t, err)
+		data, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(data))
+		assert.NoError(t, reader.Close())
+	}
+}
+
+func TestParallelReaderObjectChanged(t *testing.T) {
+	assert := assert.New(t)
+	fake, store := newFakeS3Store(t)
+	store.DownloadConcurrency = 2
+	store.DownloadPartSize = 2
+
+	content := "0123456789abcdefghijABCDEFGHIJ"
+	upload := newFinishedUpload(t, store, content)
+	info, err := upload.GetInfo(context.Background())
+	assert.NoError(err)
+	objectId, _ := splitIds(info.ID)
+
+	reader, err := upload.GetReader(context.Background())
+	assert.NoError(err)
+	defer reader.Close()
+
+	// The object is overwritten after the first range has been requested.
+	fake.putObject(objectId, []byte("ZYXWVUTSRQPONMLKJIHGFEDCBA9876"))
+
+	data, err := io.ReadAll(reader)
+	assert.ErrorContains(err, "changed during download")
+	// Only data from the original object is returned.
+	assert.True(strings.HasPrefix(content, string(data)))
+}
+
+func TestGetReaderUnfinishedUpload(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	_, store := newFakeS3Store(t)
+	store.DownloadConcurrency = 2
+	store.DownloadPartSize = 4
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: 30})
+	assert.NoError(err)
+
+	// As long as all data is in the incomplete part object, it can be read.
+	_, err = upload.WriteChunk(ctx, 0, bytes.NewReader([]byte("012")))
+	assert.NoError(err)
+	reader, err := upload.GetReader(ctx)
+	assert.NoError(err)
+	data, err := io.ReadAll(reader)
+	assert.NoError(err)
+	assert.Equal("012", string(data))
+
+	// The parts of a multipart upload cannot be downloaded before it is completed.
+	_, err = upload.WriteChunk(ctx, 3, bytes.NewReader([]byte("3456789abc")))
+	assert.NoError(err)
+	_, err = upload.GetReader(ctx)
+	if assert.IsType(models.Error{}, err) {
+		assert.Equal("ERR_INCOMPLETE_UPLOAD", err.(models.Error).ErrorCode)
+	}
+}
@@ -14,12 +14,15 @@ import (
 const TEMP_DIR_USE_MEMORY = "_memory"
 
 // s3PartProducer converts a stream of bytes from the reader into a stream of files on disk
+// or, if an arena is provided, into a stream of parts in memory.
 type s3PartProducer struct {
-	tmpDir                  string
-	files                   chan fileChunk
-	err                     error
-	r                       io.Reader
-	diskWriteDurationMetric prometheus.Summary
+	tmpDir                    string
+	arena                     *MemoryArena
+	files                     chan fileChunk
+	err                       error
+	r                         io.Reader
+	diskWriteDurationMetric   prometheus.Summary
+	memoryWriteDurationMetric prometheus.Summary
 }
 
 type fileChunk struct {
@@ -28,7 +31,7 @@ type fileChunk struct {
 	size        int64
 }
 
-func newS3PartProducer(source io.Reader, backlog int64, tmpDir string, diskWriteDurationMetric prometheus.Summary) (s3PartProducer, <-chan fileChunk) {
+func newS3PartProducer(source io.Reader, backlog int64, tmpDir string, arena *MemoryArena, diskWriteDurationMetric prometheus.Summary, memoryWriteDurationMetric prometheus.Summary) (s3PartProducer, <-chan fileChunk) {
 	fileChan := make(chan fileChunk, backlog)
 
 	if os.Getenv("TUSD_S3STORE_TEMP_MEMORY") == "1" {
@@ -36,10 +39,12 @@ func newS3PartProducer(source io.Reader, backlog int64, tmpDir string, diskWrite
 	}
 
 	partProducer := s3PartProducer{
-		tmpDir:                  tmpDir,
-		files:                   fileChan,
-		r:                       source,
-		diskWriteDurationMetric: diskWriteDurationMetric,
+		tmpDir:                    tmpDir,
+		arena:                     arena,
+		files:                     fileChan,
+		r:                         source,
+		diskWriteDurationMetric:   diskWriteDurationMetric,
+		memoryWriteDurationMetric: memoryWriteDurationMetric,
 	}
 
 	return partProducer, fileChan
@@ -55,10 +60,13 @@ func (spp *s3PartProducer) closeUnreadFiles() {
 	}
 }
 
-func (spp *s3PartProducer) produce(ctx context.Context, partSize int64) {
+// produce reads parts from the source until it is exhausted. The size of each part is
+// determined by the sizer, which is informed about the time it took to read each part.
+func (spp *s3PartProducer) produce(ctx context.Context, sizer *partSizer) {
 outerloop:
 	for {
-		file, ok, err := spp.nextPart(partSize)
+		start := time.Now()
+		file, ok, err := spp.nextPart(ctx, sizer.next())
 		if err != nil {
 			// An error occured. Stop producing.
 			spp.err = err
@@ -68,6 +76,8 @@ outerloop:
 			// The source was fully read. Stop producing.
 			break
 		}
+		sizer.observe(file.size, time.Since(start))
+
 		select {
 		case spp.files <- file:
 		case <-ctx.Done():
@@ -79,8 +89,33 @@ outerloop:
 	close(spp.files)
 }
 
-func (spp *s3PartProducer) nextPart(size int64) (fileChunk, bool, error) {
-	if spp.tmpDir != TEMP_DIR_USE_MEMORY {
+func (spp *s3PartProducer) nextPart(ctx context.Context, size int64) (fileChunk, bool, error) {
+	if spp.arena != nil && spp.arena.fits(size) {
+		start := time.Now()
+
+		// readPart waits until enough memory is available in the arena.
+		part, err := spp.arena.readPart(ctx, spp.r, size)
+		if err != nil {
+			return fileChunk{}, false, err
+		}
+
+		// If the entire request body is read and no more data is available,
+		// the part is empty. In that case, we can close the s3PartProducer.
+		if part.size == 0 {
+			part.Close()
+			return fileChunk{}, false, nil
+		}
+
+		elapsed := time.Since(start)
+		ms := float64(elapsed.Nanoseconds() / int64(time.Millisecond))
+		spp.memoryWriteDurationMetric.Observe(ms)
+
+		return fileChunk{
+			reader:      part,
+			closeReader: part.Close,
+			size:        part.size,
+		}, true, nil
+	} else if spp.tmpDir != TEMP_DIR_USE_MEMORY {
 		// Create a temporary file to store the part
 		file, err := os.CreateTemp(spp.tmpDir, "tusd-s3-tmp-")
 		if err != nil {
@@ -147,7 +182,7 @@ func (spp *s3PartProducer) nextPart(size int64) (fileChunk, bool, error) {
 
 		elapsed := time.Since(start)
 		ms := float64(elapsed.Nanoseconds() / int64(time.Millisecond))
-		spp.diskWriteDurationMetric.Observe(ms)
+		spp.memoryWriteDurationMetric.Observe(ms)
 
 		return fileChunk{
 			// buf does not get written to anymore, so we can turn it into a reader
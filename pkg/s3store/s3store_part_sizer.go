@@ -0,0 +1,171 @@
+package s3store
+
+import (
+	"math"
+	"time"
+
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// partSizer chooses the size of each part, while the data of a PATCH request is split
+// into parts. Unless adaptive part sizing is disabled, the size is based on:
+//
+//   - the throughput of the client, so that a part takes about TargetPartDuration to
+//     be received, while each part is at most twice as large as the previous one,
+//   - the data uploaded so far, so that part sizes grow geometrically for large uploads,
+//   - the free memory in the MemoryArena or the free space in TemporaryDirectory, and
+//   - the remaining part numbers, so that the upload never runs out of them.
+//
+// The last criterion takes precedence over the others. For uploads with a deferred
+// length, it assumes that the upload may grow up to MaxObjectSize.
+type partSizer struct {
+	store *S3Store
+
+	// fixedSize is the size of all parts, if adaptive part sizing is disabled.
+	fixedSize int64
+
+	size           int64
+	sizeIsDeferred bool
+	// offset is the number of bytes stored in parts so far.
+	offset int64
+	// numParts is the number of part numbers used so far.
+	numParts int64
+	// lastSize is the size of the previous part, or 0 for the first part.
+	lastSize int64
+
+	// readBytes and readDuration measure the throughput of the client.
+	readBytes    int64
+	readDuration time.Duration
+}
+
+// newPartSizer creates a partSizer for an upload, which already consists of numParts
+// parts containing offset bytes.
+func (store *S3Store) newPartSizer(info models.FileInfo, offset int64, numParts int) (*partSizer, error) {
+	optimalPartSize, err := store.calcOptimalPartSize(info.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	sizer := &partSizer{
+		store:          store,
+		size:           info.Size,
+		sizeIsDeferred: info.SizeIsDeferred,
+		offset:         offset,
+		numParts:       int64(numParts),
+	}
+	if store.DisableAdaptivePartSizing {
+		sizer.fixedSize = optimalPartSize
+	}
+
+	return sizer, nil
+}
+
+// next returns the size for the next part.
+func (sizer *partSizer) next() int64 {
+	if sizer.fixedSize > 0 {
+		return sizer.fixedSize
+	}
+
+	size := sizer.desired()
+	if limit := sizer.upperLimit(); size > limit {
+		size = limit
+	}
+	if minimum := sizer.minimum(); size < minimum {
+		size = minimum
+	}
+
+	return size
+}
+
+// observe records that a part of the given size has been received in the given time.
+func (sizer *partSizer) observe(size int64, duration time.Duration) {
+	sizer.offset += size
+	sizer.numParts += 1
+	sizer.lastSize = size
+	sizer.readBytes += size
+	sizer.readDuration += duration
+}
+
+// minimum returns the smallest part size, which ensures that the rest of the upload
+// fits into the remaining part numbers.
+func (sizer *partSizer) minimum() int64 {
+	store := sizer.store
+
+	remainingParts := store.MaxMultipartParts - sizer.numParts
+	if remainingParts < 1 {
+		remainingParts = 1
+	}
+
+	var required int64
+	if !sizer.sizeIsDeferred {
+		// Spreading the remaining data evenly over the remaining parts is sufficient.
+		remaining := sizer.size - sizer.offset
+		required = (remaining + remainingParts - 1) / remainingParts
+	} else {
+		// The upload might grow up to MaxObjectSize, so the parts after this one must
+		// be able to hold the rest of it, even if they reach MaxPartSize.
+		remaining := store.MaxObjectSize - sizer.offset
+		required = remaining - (remainingParts-1)*store.MaxPartSize
+	}
+
+	if required < store.MinPartSize {
+		return store.MinPartSize
+	}
+	return required
+}
+
+// desired returns the part size based on the client's throughput and the data
+// uploaded so far.
+func (sizer *partSizer) desired() int64 {
+	store := sizer.store
+
+	desired := store.PreferredPartSize
+	if store.TargetPartDuration > 0 && sizer.readBytes > 0 && sizer.readDuration > 0 {
+		throughput := float64(sizer.readBytes) / sizer.readDuration.Seconds()
+		desired = int64(throughput * store.TargetPartDuration.Seconds())
+	}
+
+	// Growing each part by a fixed share of the data uploaded so far lets the part
+	// sizes increase geometrically, so that an upload starting with PreferredPartSize
+	// reaches MaxObjectSize within MaxMultipartParts.
+	growthRate := math.Pow(float64(store.MaxObjectSize)/float64(store.PreferredPartSize), 1/float64(store.MaxMultipartParts)) - 1
+	if grown := int64(float64(sizer.offset) * growthRate); grown > desired {
+		desired = grown
+	}
+
+	// Part sizes change gradually, so that a short burst does not lead to huge parts.
+	if sizer.lastSize > 0 && desired > 2*sizer.lastSize {
+		desired = 2 * sizer.lastSize
+	}
+
+	return desired
+}
+
+// upperLimit returns the largest part size, which can be buffered using the currently
+// free memory or temporary disk space.
+func (sizer *partSizer) upperLimit() int64 {
+	store := sizer.store
+	limit := store.MaxPartSize
+
+	// Parts, which fit into the arena's free memory, are buffered without waiting.
+	if arena := store.MemoryArena; arena != nil {
+		if free := arena.Limit() - arena.Used(); free >= store.MinPartSize {
+			if free < limit {
+				limit = free
+			}
+			return limit
+		}
+	}
+
+	if store.TemporaryDirectory != TEMP_DIR_USE_MEMORY {
+		// Besides the part being uploaded, up to MaxBufferedParts parts are stored
+		// on disk at the same time.
+		if free, ok := freeTemporarySpace(store.TemporaryDirectory); ok {
+			if perPart := free / (store.MaxBufferedParts + 1); perPart < limit {
+				limit = perPart
+			}
+		}
+	}
+
+	return limit
+}
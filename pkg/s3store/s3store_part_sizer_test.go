@@ -0,0 +1,116 @@
+package s3store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+const MiB = 1024 * 1024
+
+func newTestPartSizerStore() S3Store {
+	store := New("bucket", nil)
+	store.TemporaryDirectory = TEMP_DIR_USE_MEMORY
+	return store
+}
+
+func TestPartSizerFixedSize(t *testing.T) {
+	assert := assert.New(t)
+
+	store := newTestPartSizerStore()
+	store.DisableAdaptivePartSizing = true
+	info := models.FileInfo{Size: 1000 * MiB}
+
+	optimalPartSize, err := store.calcOptimalPartSize(info.Size)
+	assert.NoError(err)
+
+	sizer, err := store.newPartSizer(info, 0, 0)
+	assert.NoError(err)
+	assert.Equal(optimalPartSize, sizer.next())
+
+	sizer.observe(optimalPartSize, time.Millisecond)
+	assert.Equal(optimalPartSize, sizer.next())
+}
+
+func TestPartSizerThroughput(t *testing.T) {
+	assert := assert.New(t)
+
+	store := newTestPartSizerStore()
+	sizer, err := store.newPartSizer(models.FileInfo{Size: 5000 * MiB}, 0, 0)
+	assert.NoError(err)
+
+	// Without measurements, the preferred size is used.
+	assert.Equal(store.PreferredPartSize, sizer.next())
+
+	// 10 MiB/s would fill 300 MiB in TargetPartDuration, but the size may at most
+	// double from one part to the next.
+	sizer.observe(10*MiB, time.Second)
+	assert.Equal(int64(20*MiB), sizer.next())
+
+	// A slow client gets smaller parts, but not below MinPartSize.
+	sizer.observe(20*MiB, 1000*time.Second)
+	assert.Equal(store.MinPartSize, sizer.next())
+}
+
+func TestPartSizerGrowsWithOffset(t *testing.T) {
+	store := newTestPartSizerStore()
+	store.TargetPartDuration = 0
+	sizer, err := store.newPartSizer(models.FileInfo{SizeIsDeferred: true}, 100*1024*MiB, 1000)
+	assert.NoError(t, err)
+
+	assert.Greater(t, sizer.next(), store.PreferredPartSize)
+}
+
+func TestPartSizerMaxPartSize(t *testing.T) {
+	store := newTestPartSizerStore()
+	store.MaxPartSize = 100 * MiB
+	sizer, err := store.newPartSizer(models.FileInfo{Size: 5000 * MiB}, 0, 0)
+	assert.NoError(t, err)
+
+	sizer.observe(80*MiB, time.Second)
+	assert.Equal(t, int64(100*MiB), sizer.next())
+}
+
+func TestPartSizerRemainingParts(t *testing.T) {
+	assert := assert.New(t)
+
+	store := newTestPartSizerStore()
+	store.MaxMultipartParts = 10
+
+	// The last part number must hold the rest of the upload.
+	sizer, err := store.newPartSizer(models.FileInfo{Size: 1000 * MiB}, 100*MiB, 9)
+	assert.NoError(err)
+	assert.Equal(int64(900*MiB), sizer.next())
+
+	// Even if no part numbers are left, the rest is requested in a single part.
+	sizer, err = store.newPartSizer(models.FileInfo{Size: 1000 * MiB}, 100*MiB, 12)
+	assert.NoError(err)
+	assert.Equal(int64(900*MiB), sizer.next())
+
+	// The rest of the upload is spread over the remaining parts.
+	sizer, err = store.newPartSizer(models.FileInfo{Size: 1000 * MiB}, 0, 0)
+	assert.NoError(err)
+	assert.Equal(int64(100*MiB), sizer.next())
+}
+
+func TestPartSizerDeferredLength(t *testing.T) {
+	assert := assert.New(t)
+
+	store := newTestPartSizerStore()
+	store.MaxMultipartParts = 10
+	store.MaxPartSize = 1024 * MiB
+	store.MaxObjectSize = 10 * 1024 * MiB
+
+	// The following parts must be able to hold the rest of MaxObjectSize.
+	sizer, err := store.newPartSizer(models.FileInfo{SizeIsDeferred: true}, 0, 0)
+	assert.NoError(err)
+	assert.Equal(int64(1024*MiB), sizer.minimum())
+	assert.Equal(int64(1024*MiB), sizer.next())
+
+	store.MaxObjectSize = 5 * 1024 * MiB
+	sizer, err = store.newPartSizer(models.FileInfo{SizeIsDeferred: true}, 0, 0)
+	assert.NoError(err)
+	assert.Equal(store.MinPartSize, sizer.minimum())
+}
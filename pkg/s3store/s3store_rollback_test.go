@@ -0,0 +1,77 @@
+package s3store
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+func TestRollbackChunkReusesPartNumbers(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	fake, store := newFakeS3Store(t)
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: 30})
+	assert.NoError(err)
+	info, err := upload.GetInfo(ctx)
+	assert.NoError(err)
+	objectId, _ := splitIds(info.ID)
+
+	// Every rolled back chunk overwrites the parts of the previous one.
+	for i := 0; i < 3; i++ {
+		n, err := upload.WriteChunk(ctx, 0, bytes.NewReader([]byte("xxxxxxxxxxyyyyyyyyyy")))
+		assert.NoError(err)
+		assert.Equal(int64(20), n)
+		assert.NoError(store.AsRollbackableUpload(upload).RollbackChunk(ctx, 0))
+
+		info, err := upload.GetInfo(ctx)
+		assert.NoError(err)
+		assert.Equal(int64(0), info.Offset)
+	}
+	assert.Equal([]int{1, 2}, fake.partNumbers(objectId))
+
+	// The upload is continued in a new request.
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	n, err := upload.WriteChunk(ctx, 0, bytes.NewReader([]byte("0123456789abcdefghijABCDEFGHIJ")))
+	assert.NoError(err)
+	assert.Equal(int64(30), n)
+	assert.NoError(upload.FinishUpload(ctx))
+
+	upload, err = store.GetUpload(ctx, info.ID)
+	assert.NoError(err)
+	reader, err := upload.GetReader(ctx)
+	assert.NoError(err)
+	data, err := io.ReadAll(reader)
+	assert.NoError(err)
+	assert.Equal("0123456789abcdefghijABCDEFGHIJ", string(data))
+}
+
+func TestRollbackChunkRestoresIncompletePart(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	_, store := newFakeS3Store(t)
+
+	upload, err := store.NewUpload(ctx, models.FileInfo{Size: 30})
+	assert.NoError(err)
+	_, err = upload.WriteChunk(ctx, 0, bytes.NewReader([]byte("012")))
+	assert.NoError(err)
+
+	_, err = upload.WriteChunk(ctx, 3, bytes.NewReader([]byte("xxxxxxxxxxyyyyy")))
+	assert.NoError(err)
+	assert.NoError(store.AsRollbackableUpload(upload).RollbackChunk(ctx, 3))
+
+	_, err = upload.WriteChunk(ctx, 3, bytes.NewReader([]byte("3456789abcdefghijABCDEFGHIJ")))
+	assert.NoError(err)
+	assert.NoError(upload.FinishUpload(ctx))
+
+	reader, err := upload.GetReader(ctx)
+	assert.NoError(err)
+	data, err := io.ReadAll(reader)
+	assert.NoError(err)
+	assert.Equal("0123456789abcdefghijABCDEFGHIJ", string(data))
+}
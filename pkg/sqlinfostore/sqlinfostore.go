@@ -0,0 +1,148 @@
+// Package sqlinfostore provides a models.InfoStore backed by a SQL database.
+//
+// SQLInfoStore can be assigned to S3Store.InfoStore or FileStore.InfoStore, so that
+// the information about uploads is kept in a database instead of next to the uploads'
+// data. It uses the database/sql package and works with any driver for a database
+// supporting standard SQL, such as SQLite, PostgreSQL or MySQL. The driver must be
+// imported by the application.
+//
+// The information is stored as JSON in a table with the following columns, which can
+// be created using CreateTable:
+//
+//	id        VARCHAR(255) PRIMARY KEY
+//	revision  BIGINT NOT NULL
+//	info      TEXT NOT NULL
+//
+// The revision is incremented on every write and serves as the version of the
+// information, so that concurrent modifications are detected.
+//
+// Existing uploads can be moved to or from the database using models.MigrateInfos.
+package sqlinfostore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/susufqx/dynamic-bucket-tusd/internal/sqlquery"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// See the models.InfoStore interface for documentation about the different methods.
+type SQLInfoStore struct {
+	// DB is the database, in which the information is stored.
+	DB *sql.DB
+	// Table is the name of the table, in which the information is stored. It is
+	// inserted into the statements without quoting.
+	Table string
+	// NumberedPlaceholders uses placeholders like $1 instead of ? in the statements,
+	// as required by PostgreSQL.
+	NumberedPlaceholders bool
+}
+
+// New creates a new SQL based info store, which stores the information in the given
+// table. The table is not created automatically, use CreateTable in this case.
+func New(db *sql.DB, table string) SQLInfoStore {
+	return SQLInfoStore{
+		DB:    db,
+		Table: table,
+	}
+}
+
+// CreateTable creates the table for storing the information, if it does not exist yet.
+func (store SQLInfoStore) CreateTable(ctx context.Context) error {
+	_, err := store.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+store.Table+" (id VARCHAR(255) PRIMARY KEY, revision BIGINT NOT NULL, info TEXT NOT NULL)")
+	return err
+}
+
+func (store SQLInfoStore) GetInfo(ctx context.Context, id string) (info models.FileInfo, version string, err error) {
+	var revision int64
+	var data string
+	row := store.DB.QueryRowContext(ctx, store.query("SELECT revision, info FROM "+store.Table+" WHERE id = ?"), id)
+	if err := row.Scan(&revision, &data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = models.ErrNotFound
+		}
+		return info, "", err
+	}
+
+	if err := json.Unmarshal([]byte(data), &info); err != nil {
+		return info, "", err
+	}
+
+	return info, strconv.FormatInt(revision, 10), nil
+}
+
+// PutInfo stores the information with the current time as ModifiedAt.
+func (store SQLInfoStore) PutInfo(ctx context.Context, id string, info models.FileInfo, version string) (string, error) {
+	info.ModifiedAt = time.Now()
+	data, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+
+	if version == "" {
+		_, err := store.DB.ExecContext(ctx, store.query("INSERT INTO "+store.Table+" (id, revision, info) VALUES (?, 1, ?)"), id, string(data))
+		if err != nil {
+			// The error for a duplicate primary key differs between drivers, so check
+			// whether the information has been inserted by someone else.
+			if _, _, getErr := store.GetInfo(ctx, id); getErr == nil {
+				return "", models.ErrInfoConflict
+			}
+			return "", err
+		}
+		return "1", nil
+	}
+
+	revision, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("sqlinfostore: invalid version %q: %w", version, err)
+	}
+
+	res, err := store.DB.ExecContext(ctx, store.query("UPDATE "+store.Table+" SET revision = ?, info = ? WHERE id = ? AND revision = ?"), revision+1, string(data), id, revision)
+	if err != nil {
+		return "", err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if rows == 0 {
+		return "", models.ErrInfoConflict
+	}
+
+	return strconv.FormatInt(revision+1, 10), nil
+}
+
+func (store SQLInfoStore) DeleteInfo(ctx context.Context, id string) error {
+	_, err := store.DB.ExecContext(ctx, store.query("DELETE FROM "+store.Table+" WHERE id = ?"), id)
+	return err
+}
+
+func (store SQLInfoStore) ListInfos(ctx context.Context) ([]string, error) {
+	rows, err := store.DB.QueryContext(ctx, "SELECT id FROM "+store.Table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// query adapts the placeholders in the statement to the database.
+func (store SQLInfoStore) query(statement string) string {
+	return sqlquery.Rewrite(statement, store.NumberedPlaceholders)
+}
@@ -0,0 +1,190 @@
+package sqlinfostore
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/susufqx/dynamic-bucket-tusd/pkg/models"
+)
+
+// newTestStore creates a store using a new SQLite database.
+func newTestStore(t *testing.T, table string) SQLInfoStore {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "infos.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Skipf("SQLite is not available: %s", err)
+	}
+
+	store := New(db, table)
+	if err := store.CreateTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestPutAndGetInfo(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store := newTestStore(t, "infos")
+
+	_, _, err := store.GetInfo(ctx, "upload")
+	assert.ErrorIs(err, models.ErrNotFound)
+
+	version, err := store.PutInfo(ctx, "upload", models.FileInfo{
+		ID:       "upload",
+		Size:     100,
+		MetaData: map[string]string{"filename": "Menü.txt"},
+	}, "")
+	assert.NoError(err)
+	assert.Equal("1", version)
+
+	info, version, err := store.GetInfo(ctx, "upload")
+	assert.NoError(err)
+	assert.Equal("1", version)
+	assert.Equal("upload", info.ID)
+	assert.EqualValues(100, info.Size)
+	assert.Equal("Menü.txt", info.MetaData["filename"])
+	assert.False(info.ModifiedAt.IsZero())
+
+	info.Offset = 50
+	version, err = store.PutInfo(ctx, "upload", info, version)
+	assert.NoError(err)
+	assert.Equal("2", version)
+
+	info, version, err = store.GetInfo(ctx, "upload")
+	assert.NoError(err)
+	assert.Equal("2", version)
+	assert.EqualValues(50, info.Offset)
+}
+
+func TestPutInfoConflicts(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store := newTestStore(t, "infos")
+
+	_, err := store.PutInfo(ctx, "upload", models.FileInfo{ID: "upload"}, "")
+	assert.NoError(err)
+
+	// The information cannot be created twice.
+	_, err = store.PutInfo(ctx, "upload", models.FileInfo{ID: "upload"}, "")
+	assert.ErrorIs(err, models.ErrInfoConflict)
+
+	// Two instances read the same version, but only the first one can write.
+	_, version, err := store.GetInfo(ctx, "upload")
+	assert.NoError(err)
+
+	_, err = store.PutInfo(ctx, "upload", models.FileInfo{ID: "upload", Offset: 10}, version)
+	assert.NoError(err)
+	_, err = store.PutInfo(ctx, "upload", models.FileInfo{ID: "upload", Offset: 20}, version)
+	assert.ErrorIs(err, models.ErrInfoConflict)
+
+	info, _, err := store.GetInfo(ctx, "upload")
+	assert.NoError(err)
+	assert.EqualValues(10, info.Offset)
+
+	// Updating information, which does not exist, is a conflict as well.
+	_, err = store.PutInfo(ctx, "other", models.FileInfo{ID: "other"}, "1")
+	assert.ErrorIs(err, models.ErrInfoConflict)
+
+	_, err = store.PutInfo(ctx, "upload", info, "invalid")
+	assert.Error(err)
+}
+
+func TestDeleteInfo(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store := newTestStore(t, "infos")
+
+	_, err := store.PutInfo(ctx, "upload", models.FileInfo{ID: "upload"}, "")
+	assert.NoError(err)
+
+	assert.NoError(store.DeleteInfo(ctx, "upload"))
+	_, _, err = store.GetInfo(ctx, "upload")
+	assert.ErrorIs(err, models.ErrNotFound)
+
+	// Deleting missing information is not an error.
+	assert.NoError(store.DeleteInfo(ctx, "upload"))
+
+	// The information can be created again afterwards.
+	version, err := store.PutInfo(ctx, "upload", models.FileInfo{ID: "upload"}, "")
+	assert.NoError(err)
+	assert.Equal("1", version)
+}
+
+func TestListInfos(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	store := newTestStore(t, "infos")
+
+	ids, err := store.ListInfos(ctx)
+	assert.NoError(err)
+	assert.Empty(ids)
+
+	for _, id := range []string{"c", "a", "b"} {
+		_, err := store.PutInfo(ctx, id, models.FileInfo{ID: id}, "")
+		assert.NoError(err)
+	}
+	assert.NoError(store.DeleteInfo(ctx, "b"))
+
+	ids, err = store.ListInfos(ctx)
+	assert.NoError(err)
+	sort.Strings(ids)
+	assert.Equal([]string{"a", "c"}, ids)
+}
+
+func TestMigrateInfos(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+	from := newTestStore(t, "old_infos")
+	to := newTestStore(t, "new_infos")
+
+	for _, id := range []string{"a", "b", "c"} {
+		_, err := from.PutInfo(ctx, id, models.FileInfo{ID: id, Size: 10}, "")
+		assert.NoError(err)
+	}
+	// b has already been migrated before.
+	_, err := to.PutInfo(ctx, "b", models.FileInfo{ID: "b", Size: 20}, "")
+	assert.NoError(err)
+
+	report, err := models.MigrateInfos(ctx, from, to, models.InfoMigrationOptions{DryRun: true})
+	assert.NoError(err)
+	sort.Strings(report.Migrated)
+	assert.Equal([]string{"a", "c"}, report.Migrated)
+	assert.Equal([]string{"b"}, report.Skipped)
+	_, _, err = to.GetInfo(ctx, "a")
+	assert.ErrorIs(err, models.ErrNotFound)
+
+	report, err = models.MigrateInfos(ctx, from, to, models.InfoMigrationOptions{DeleteSource: true})
+	assert.NoError(err)
+	sort.Strings(report.Migrated)
+	assert.Equal([]string{"a", "c"}, report.Migrated)
+	assert.Equal([]string{"b"}, report.Skipped)
+
+	info, _, err := to.GetInfo(ctx, "a")
+	assert.NoError(err)
+	assert.EqualValues(10, info.Size)
+	info, _, err = to.GetInfo(ctx, "b")
+	assert.NoError(err)
+	assert.EqualValues(20, info.Size)
+
+	// Skipped uploads are kept in the source.
+	ids, err := from.ListInfos(ctx)
+	assert.NoError(err)
+	assert.Equal([]string{"b"}, ids)
+}
+
+func TestNumberedPlaceholders(t *testing.T) {
+	store := SQLInfoStore{NumberedPlaceholders: true}
+	assert.Equal(t, "UPDATE t SET a = $1 WHERE b = $2", store.query("UPDATE t SET a = ? WHERE b = ?"))
+
+	store.NumberedPlaceholders = false
+	assert.Equal(t, "UPDATE t SET a = ? WHERE b = ?", store.query("UPDATE t SET a = ? WHERE b = ?"))
+}